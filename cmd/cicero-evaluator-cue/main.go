@@ -0,0 +1,302 @@
+// Evaluates actions written in CUE.
+//
+// The source is a directory with a CUE package whose `actions` field
+// holds the actions by name. It may only import CUE's standard library.
+// Before evaluating an action its `#name`, `#id` and `#inputs` are filled in.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
+	cueformat "cuelang.org/go/cue/format"
+	"github.com/pkg/errors"
+)
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, `Usage: cicero-evaluator-cue [capabilities] [list] [eval <attrs...>]
+
+The following env vars must be set:
+	- CICERO_ACTION_SRC
+
+For eval, the following env vars must be set:
+	- CICERO_ACTION_NAME
+	- CICERO_ACTION_ID
+	- CICERO_ACTION_INPUTS
+
+The following env vars are optional:
+	- CICERO_EVALUATOR_PROTOCOL`)
+}
+
+// Speaks version 1 of the evaluator protocol.
+type capabilities struct {
+	Protocols []int `json:"protocols"`
+}
+
+// What is printed on failure if CICERO_EVALUATOR_PROTOCOL is at least 1.
+type evaluatorError struct {
+	Message   string    `json:"message"`
+	Position  *position `json:"position,omitempty"`
+	Retryable bool      `json:"retryable"`
+}
+
+type position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+
+	if protocol, _ := strconv.Atoi(os.Getenv("CICERO_EVALUATOR_PROTOCOL")); protocol >= 1 {
+		if err := json.NewEncoder(os.Stdout).Encode(toEvaluatorError(err)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	os.Exit(1)
+}
+
+func toEvaluatorError(err error) evaluatorError {
+	evalErr := evaluatorError{Message: err.Error()}
+	for _, pos := range cueerrors.Positions(err) {
+		if pos.IsValid() {
+			evalErr.Position = &position{
+				File:   relativeToSource(pos.Filename()),
+				Line:   pos.Line(),
+				Column: pos.Column(),
+			}
+			break
+		}
+	}
+	return evalErr
+}
+
+func relativeToSource(file string) string {
+	if rel, err := filepath.Rel(os.Getenv("CICERO_ACTION_SRC"), file); err == nil {
+		return rel
+	}
+	return file
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "No command given")
+		fmt.Fprintln(os.Stderr)
+		usage(os.Stderr)
+		os.Exit(1)
+	}
+
+	var result interface{}
+	var err error
+	switch os.Args[1] {
+	case "capabilities":
+		result = capabilities{Protocols: []int{1}}
+	case "list":
+		result, err = list(os.Getenv("CICERO_ACTION_SRC"))
+	case "eval":
+		var inputs map[string]interface{}
+		if str := os.Getenv("CICERO_ACTION_INPUTS"); str != "" {
+			if err := json.Unmarshal([]byte(str), &inputs); err != nil {
+				fail(errors.WithMessage(err, "Could not parse CICERO_ACTION_INPUTS"))
+			}
+		}
+		result, err = eval(
+			os.Getenv("CICERO_ACTION_SRC"),
+			os.Getenv("CICERO_ACTION_NAME"),
+			os.Getenv("CICERO_ACTION_ID"),
+			inputs,
+			os.Args[2:],
+		)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fail(err)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+		fail(err)
+	}
+}
+
+func loadActions(src string) (cue.Value, error) {
+	if src == "" {
+		return cue.Value{}, errors.New("CICERO_ACTION_SRC is not set")
+	}
+
+	files, err := filepath.Glob(filepath.Join(src, "*.cue"))
+	if err != nil {
+		return cue.Value{}, err
+	}
+	if len(files) == 0 {
+		return cue.Value{}, fmt.Errorf("No CUE files in %s", src)
+	}
+
+	instance := build.NewContext().NewInstance(src, nil)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return cue.Value{}, err
+		}
+		if err := instance.AddFile(file, content); err != nil {
+			return cue.Value{}, errors.WithMessagef(err, "Could not parse %s", file)
+		}
+	}
+
+	value := cuecontext.New().BuildInstance(instance)
+	if err := value.Err(); err != nil {
+		return value, errors.WithMessage(err, "Could not build CUE package")
+	}
+
+	actions := value.LookupPath(cue.ParsePath("actions"))
+	if !actions.Exists() {
+		return actions, errors.New("The CUE package has no `actions` field")
+	}
+
+	return actions, nil
+}
+
+func list(src string) ([]string, error) {
+	actions, err := loadActions(src)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := actions.Fields()
+	if err != nil {
+		return nil, errors.WithMessage(err, "`actions` is not a struct")
+	}
+
+	names := []string{}
+	for iter.Next() {
+		names = append(names, iter.Label())
+	}
+	return names, nil
+}
+
+// Returns those of the given attributes that the action has.
+func eval(src, name, id string, inputs map[string]interface{}, attrs []string) (map[string]interface{}, error) {
+	actions, err := loadActions(src)
+	if err != nil {
+		return nil, err
+	}
+
+	action := actions.LookupPath(cue.MakePath(cue.Str(name)))
+	if !action.Exists() {
+		return nil, fmt.Errorf("No action named %q", name)
+	}
+
+	action = action.
+		FillPath(cue.MakePath(cue.Def("#name")), name).
+		FillPath(cue.MakePath(cue.Def("#id")), id)
+	if inputs != nil {
+		action = action.FillPath(cue.MakePath(cue.Def("#inputs")), inputs)
+	}
+	if err := action.Err(); err != nil {
+		return nil, errors.WithMessagef(err, "Could not evaluate action %q", name)
+	}
+
+	result := map[string]interface{}{}
+	for _, attr := range attrs {
+		value := action.LookupPath(cue.MakePath(cue.Str(attr)))
+		if !value.Exists() {
+			continue
+		}
+
+		var err error
+		switch attr {
+		case "inputs":
+			result[attr], err = evalInputs(value)
+		default:
+			result[attr], err = concrete(value)
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "Could not evaluate `%s` of action %q", attr, name)
+		}
+	}
+
+	return result, nil
+}
+
+func concrete(value cue.Value) (json.RawMessage, error) {
+	if err := value.Validate(cue.Concrete(true)); err != nil {
+		return nil, err
+	}
+	return value.MarshalJSON()
+}
+
+type inputDefinition struct {
+	Select   string `json:"select"`
+	Not      bool   `json:"not"`
+	Optional bool   `json:"optional"`
+	Match    string `json:"match"`
+	Schema   string `json:"schema,omitempty"`
+}
+
+// Returns the string or else the syntax of the value
+// with references like to definitions resolved.
+func cueSource(value cue.Value) (string, error) {
+	if str, err := value.String(); err == nil {
+		return str, nil
+	} else if syntax, err := cueformat.Node(value.Eval().Syntax(cue.Optional(true)), cueformat.Simplify()); err != nil {
+		return "", err
+	} else {
+		return string(syntax), nil
+	}
+}
+
+// Inputs are either a match or a struct with one.
+// A match is either CUE in a string or a CUE value, and so is a schema.
+func evalInputs(value cue.Value) (map[string]inputDefinition, error) {
+	iter, err := value.Fields()
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := map[string]inputDefinition{}
+	for iter.Next() {
+		input := inputDefinition{Select: "latest"}
+
+		match := iter.Value()
+		if match.IncompleteKind() == cue.StructKind && match.LookupPath(cue.ParsePath("match")).Exists() {
+			for field, dst := range map[string]interface{}{
+				"select":   &input.Select,
+				"not":      &input.Not,
+				"optional": &input.Optional,
+			} {
+				if v := match.LookupPath(cue.ParsePath(field)); v.Exists() {
+					if err := v.Decode(dst); err != nil {
+						return nil, errors.WithMessagef(err, "Invalid `%s` of input %q", field, iter.Label())
+					}
+				}
+			}
+			if v := match.LookupPath(cue.ParsePath("schema")); v.Exists() {
+				if input.Schema, err = cueSource(v); err != nil {
+					return nil, errors.WithMessagef(err, "Invalid schema of input %q", iter.Label())
+				}
+			}
+			match = match.LookupPath(cue.ParsePath("match"))
+		}
+
+		if input.Match, err = cueSource(match); err != nil {
+			return nil, errors.WithMessagef(err, "Invalid match of input %q", iter.Label())
+		}
+
+		inputs[iter.Label()] = input
+	}
+
+	return inputs, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testActions = `
+package actions
+
+actions: ping: {
+	#name: string
+	#id:   string
+	#inputs: start: value: start: string
+
+	meta: description: "Runs \(#name)"
+
+	inputs: start: "start: string"
+
+	output: success: ping: #inputs.start.value.start
+
+	job: (#name): group: ping: task: ping: {
+		driver: "exec"
+		config: command: "/bin/true"
+	}
+}
+
+#Ping: {
+	ping:   string
+	count?: int
+}
+
+actions: pong: {
+	inputs: {
+		ping: {
+			select: "all"
+			match: ping: string
+			schema: #Ping
+		}
+		stop: {
+			not:   true
+			match: "stop: true"
+		}
+	}
+
+	output: success: pong: true
+}
+`
+
+func writeActions(t *testing.T) string {
+	src := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "actions.cue"), []byte(testActions), 0o644))
+	return src
+}
+
+func TestShouldListActions(t *testing.T) {
+	t.Parallel()
+
+	names, err := list(writeActions(t))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ping", "pong"}, names)
+}
+
+func TestShouldEvalMetaAndInputs(t *testing.T) {
+	t.Parallel()
+
+	result, err := eval(writeActions(t), "pong", "id", nil, []string{"meta", "inputs"})
+	assert.NoError(t, err)
+	assert.NotContains(t, result, "meta")
+
+	resultJson, err := json.Marshal(result)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"inputs": {
+		"ping": {"select": "all", "not": false, "optional": false, "match": "{\n\tping: string\n}", "schema": "{\n\t_#def\n\t_#def: {\n\t\tping:   string\n\t\tcount?: int\n\t}\n}"},
+		"stop": {"select": "latest", "not": true, "optional": false, "match": "stop: true"}
+	}}`, string(resultJson))
+}
+
+func TestShouldEvalOutputAndJob(t *testing.T) {
+	t.Parallel()
+
+	inputs := map[string]interface{}{
+		"start": map[string]interface{}{"value": map[string]interface{}{"start": "now"}},
+	}
+	result, err := eval(writeActions(t), "ping", "id", inputs, []string{"meta", "output", "job"})
+	assert.NoError(t, err)
+
+	resultJson, err := json.Marshal(result)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"meta": {"description": "Runs ping"},
+		"output": {"success": {"ping": "now"}},
+		"job": {"ping": {"group": {"ping": {"task": {"ping": {"driver": "exec", "config": {"command": "/bin/true"}}}}}}}
+	}`, string(resultJson))
+}
+
+func TestShouldFailOnIncompleteOutput(t *testing.T) {
+	t.Parallel()
+
+	_, err := eval(writeActions(t), "ping", "id", nil, []string{"output"})
+	assert.Error(t, err)
+
+	_, err = eval(writeActions(t), "missing", "id", nil, []string{"output"})
+	assert.EqualError(t, err, `No action named "missing"`)
+}
+
+func TestShouldReportErrorPosition(t *testing.T) {
+	src := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "actions.cue"), []byte(`package actions
+
+actions: conflict: output: success: {
+	ping: 1 & 2
+}
+`), 0o644))
+	t.Setenv("CICERO_ACTION_SRC", src)
+
+	_, err := eval(src, "conflict", "id", nil, []string{"output"})
+	if assert.Error(t, err) {
+		evalErr := toEvaluatorError(err)
+		assert.Equal(t, err.Error(), evalErr.Message)
+		assert.Equal(t, &position{File: "actions.cue", Line: 4, Column: 8}, evalErr.Position)
+		assert.False(t, evalErr.Retryable)
+	}
+}
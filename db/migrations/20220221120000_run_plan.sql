@@ -0,0 +1,13 @@
+-- migrate:up
+
+CREATE TABLE run_plan (
+	run_id uuid PRIMARY KEY,
+	diff jsonb,
+	failed_tg_allocs jsonb,
+	warnings text NOT NULL DEFAULT '',
+	FOREIGN KEY (run_id) REFERENCES run (nomad_job_id) ON DELETE CASCADE
+);
+
+-- migrate:down
+
+DROP TABLE run_plan;
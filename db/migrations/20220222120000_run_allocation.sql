@@ -0,0 +1,40 @@
+-- migrate:up
+
+CREATE TABLE run_allocation (
+	id uuid PRIMARY KEY,
+	run_id uuid NOT NULL,
+	modify_index bigint NOT NULL DEFAULT 0,
+	client_status text NOT NULL,
+	payload jsonb NOT NULL,
+	FOREIGN KEY (run_id) REFERENCES run (nomad_job_id) ON DELETE CASCADE
+);
+
+CREATE INDEX run_allocation_run_id ON run_allocation (run_id);
+
+INSERT INTO run_allocation (id, run_id, modify_index, client_status, payload)
+SELECT DISTINCT ON (payload#>>'{Allocation,ID}')
+	(payload#>>'{Allocation,ID}')::uuid,
+	run.nomad_job_id,
+	COALESCE((payload#>>'{Allocation,ModifyIndex}')::bigint, 0),
+	payload#>>'{Allocation,ClientStatus}',
+	payload->'Allocation'
+FROM nomad_event
+JOIN run ON run.nomad_job_id::text = nomad_event.payload#>>'{Allocation,JobID}'
+WHERE topic = 'Allocation' AND type = 'AllocationUpdated'
+ORDER BY payload#>>'{Allocation,ID}', "index" DESC;
+
+ALTER TABLE nomad_event
+ADD created_at timestamp NOT NULL DEFAULT STATEMENT_TIMESTAMP();
+
+CREATE INDEX nomad_event_index ON nomad_event ("index");
+CREATE INDEX nomad_event_created_at ON nomad_event (created_at);
+
+-- migrate:down
+
+DROP INDEX nomad_event_created_at;
+DROP INDEX nomad_event_index;
+
+ALTER TABLE nomad_event
+DROP created_at;
+
+DROP TABLE run_allocation;
@@ -0,0 +1,22 @@
+-- migrate:up
+
+-- Events in the same batch share an index
+-- so it is only unique together with what the event is about.
+DELETE FROM nomad_event a
+USING nomad_event b
+WHERE a.ctid < b.ctid
+	AND a."index" = b."index"
+	AND a.topic = b.topic
+	AND a.type = b.type
+	AND a.key = b.key;
+
+CREATE UNIQUE INDEX nomad_event_unique ON nomad_event ("index", topic, type, key);
+
+-- Lookups by index alone are served by the unique index.
+DROP INDEX nomad_event_index;
+
+-- migrate:down
+
+CREATE INDEX nomad_event_index ON nomad_event ("index");
+
+DROP INDEX nomad_event_unique;
@@ -0,0 +1,14 @@
+-- migrate:up
+
+CREATE TABLE api_token (
+	id uuid PRIMARY KEY DEFAULT public.gen_random_uuid(),
+	name text NOT NULL UNIQUE,
+	hash bytea NOT NULL UNIQUE,
+	role text NOT NULL CHECK (role IN ('viewer', 'publisher', 'operator', 'admin')),
+	created_at timestamp NOT NULL DEFAULT STATEMENT_TIMESTAMP(),
+	last_used_at timestamp
+);
+
+-- migrate:down
+
+DROP TABLE api_token;
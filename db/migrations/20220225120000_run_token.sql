@@ -0,0 +1,12 @@
+-- migrate:up
+
+CREATE TABLE run_token (
+	run_id uuid PRIMARY KEY,
+	hash bytea NOT NULL UNIQUE,
+	expires_at timestamp NOT NULL,
+	FOREIGN KEY (run_id) REFERENCES run (nomad_job_id) ON DELETE CASCADE
+);
+
+-- migrate:down
+
+DROP TABLE run_token;
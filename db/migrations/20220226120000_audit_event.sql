@@ -0,0 +1,22 @@
+-- migrate:up
+
+CREATE TABLE audit_event (
+	id uuid PRIMARY KEY DEFAULT public.gen_random_uuid(),
+	created_at timestamp NOT NULL DEFAULT STATEMENT_TIMESTAMP(),
+	type text NOT NULL,
+	subject_id uuid NOT NULL,
+	actor text NOT NULL,
+	actor_role text NOT NULL DEFAULT '',
+	request jsonb,
+	before jsonb,
+	after jsonb
+);
+
+-- Subjects are not foreign keys so that the log outlives them.
+CREATE INDEX audit_event_created_at ON audit_event (created_at);
+CREATE INDEX audit_event_subject_id ON audit_event (subject_id);
+CREATE INDEX audit_event_actor ON audit_event (actor);
+
+-- migrate:down
+
+DROP TABLE audit_event;
@@ -0,0 +1,33 @@
+-- migrate:up
+
+CREATE TABLE notification (
+	id uuid PRIMARY KEY DEFAULT public.gen_random_uuid(),
+	created_at timestamp NOT NULL DEFAULT STATEMENT_TIMESTAMP(),
+	run_id uuid NOT NULL,
+	sink text NOT NULL,
+	target text NOT NULL,
+	payload jsonb NOT NULL,
+	state text NOT NULL DEFAULT 'pending',
+	attempts integer NOT NULL DEFAULT 0,
+	next_attempt_at timestamp NOT NULL DEFAULT STATEMENT_TIMESTAMP(),
+	delivered_at timestamp,
+	FOREIGN KEY (run_id) REFERENCES run (nomad_job_id) ON DELETE CASCADE
+);
+
+CREATE INDEX notification_pending ON notification (next_attempt_at) WHERE state = 'pending';
+CREATE INDEX notification_run_id ON notification (run_id);
+
+CREATE TABLE notification_attempt (
+	id uuid PRIMARY KEY DEFAULT public.gen_random_uuid(),
+	notification_id uuid NOT NULL,
+	created_at timestamp NOT NULL DEFAULT STATEMENT_TIMESTAMP(),
+	error text,
+	FOREIGN KEY (notification_id) REFERENCES notification (id) ON DELETE CASCADE
+);
+
+CREATE INDEX notification_attempt_notification_id ON notification_attempt (notification_id);
+
+-- migrate:down
+
+DROP TABLE notification_attempt;
+DROP TABLE notification;
@@ -0,0 +1,15 @@
+-- migrate:up
+
+CREATE TABLE evaluation_cache (
+	key bytea PRIMARY KEY,
+	source text NOT NULL,
+	revision text NOT NULL,
+	output bytea NOT NULL,
+	created_at timestamp NOT NULL DEFAULT STATEMENT_TIMESTAMP()
+);
+
+CREATE INDEX evaluation_cache_source ON evaluation_cache (source);
+
+-- migrate:down
+
+DROP TABLE evaluation_cache;
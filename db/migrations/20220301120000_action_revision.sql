@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE action ADD COLUMN revision text NOT NULL DEFAULT '';
+
+-- migrate:down
+
+ALTER TABLE action DROP COLUMN revision;
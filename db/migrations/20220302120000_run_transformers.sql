@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE run ADD COLUMN transformers jsonb NOT NULL DEFAULT '[]';
+
+-- migrate:down
+
+ALTER TABLE run DROP COLUMN transformers;
@@ -0,0 +1,19 @@
+-- migrate:up
+
+ALTER TABLE evaluation_cache ADD COLUMN evaluator text NOT NULL DEFAULT '';
+
+CREATE TABLE run_definition (
+	run_id uuid PRIMARY KEY,
+	evaluator text NOT NULL,
+	revision text NOT NULL,
+	inputs jsonb NOT NULL,
+	output jsonb NOT NULL,
+	job jsonb,
+	FOREIGN KEY (run_id) REFERENCES run (nomad_job_id) ON DELETE CASCADE
+);
+
+-- migrate:down
+
+DROP TABLE run_definition;
+
+ALTER TABLE evaluation_cache DROP COLUMN evaluator;
@@ -0,0 +1,27 @@
+-- migrate:up
+
+ALTER TABLE run
+	ADD COLUMN deadline timestamp,
+	ADD COLUMN outcome text;
+
+CREATE INDEX run_deadline ON run (deadline) WHERE finished_at IS NULL AND outcome IS NULL;
+
+ALTER TABLE run_output
+	ADD COLUMN canceled jsonb,
+	ADD COLUMN timed_out jsonb,
+	ADD COLUMN lost jsonb,
+	ADD COLUMN groups jsonb;
+
+-- migrate:down
+
+ALTER TABLE run_output
+	DROP COLUMN canceled,
+	DROP COLUMN timed_out,
+	DROP COLUMN lost,
+	DROP COLUMN groups;
+
+DROP INDEX run_deadline;
+
+ALTER TABLE run
+	DROP COLUMN deadline,
+	DROP COLUMN outcome;
@@ -0,0 +1,13 @@
+-- migrate:up
+
+-- Outputs are evicted by age instead of by revision of their source.
+DROP INDEX evaluation_cache_source;
+CREATE INDEX evaluation_cache_created_at ON evaluation_cache (created_at);
+
+-- Outputs of `eval output job` are no longer cached.
+DELETE FROM evaluation_cache;
+
+-- migrate:down
+
+DROP INDEX evaluation_cache_created_at;
+CREATE INDEX evaluation_cache_source ON evaluation_cache (source);
@@ -0,0 +1,8 @@
+-- migrate:up
+
+-- Outputs are only rendered as templates if they say so.
+ALTER TABLE run_output ADD COLUMN template boolean NOT NULL DEFAULT false;
+
+-- migrate:down
+
+ALTER TABLE run_output DROP COLUMN template;
@@ -30,8 +30,9 @@ func main() {
 }
 
 type CLI struct {
-	Debug bool             `arg:"--debug" help:"debugging output"`
-	Start *cicero.StartCmd `arg:"subcommand:start"`
+	Debug          bool                      `arg:"--debug" help:"debugging output"`
+	Start          *cicero.StartCmd          `arg:"subcommand:start"`
+	CheckEvaluator *cicero.CheckEvaluatorCmd `arg:"subcommand:check-evaluator" help:"check that an evaluator conforms to the evaluator protocol"`
 }
 
 func Version() string {
@@ -72,6 +73,8 @@ func Run(parser *arg.Parser, args *CLI, logger *zerolog.Logger) error {
 	switch {
 	case args.Start != nil:
 		return args.Start.Run(logger)
+	case args.CheckEvaluator != nil:
+		return args.CheckEvaluator.Run(logger)
 	default:
 		parser.WriteHelp(os.Stderr)
 	}
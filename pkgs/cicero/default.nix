@@ -10,6 +10,7 @@ buildGoModule rec {
     ../../go.mod
     ../../go.sum
     ../../main.go
+    ../../cmd
     ../../src
   ];
 
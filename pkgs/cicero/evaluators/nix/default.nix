@@ -1,11 +1,11 @@
-{ flake, writers, coreutils }:
+{ flake, writers, coreutils, jq }:
 
 writers.writeBashBin "cicero-evaluator-nix" ''
-  PATH="$PATH:"${coreutils}/bin
+  PATH="$PATH:"${coreutils}/bin:${jq}/bin
 
   function usage {
       {
-          echo    "Usage: $(basename "$0") [list] [eval <attrs...>]"
+          echo    "Usage: $(basename "$0") [capabilities] [list] [eval <attrs...>]"
           echo
           echo    'The following env vars must be set:'
           echo -e '\t- CICERO_ACTION_SRC'
@@ -17,16 +17,52 @@ writers.writeBashBin "cicero-evaluator-nix" ''
           echo
           echo    'The following env vars are optional:'
           echo -e '\t- CICERO_EVALUATOR_NIX_STACKTRACE'
+          echo -e '\t- CICERO_EVALUATOR_PROTOCOL'
       } >&2
   }
 
+  # Since version 1 of the evaluator protocol
+  # failures are described by a JSON object on stdout.
+  function fail {
+      if [[ "''${CICERO_EVALUATOR_PROTOCOL:-0}" -ge 1 ]]; then
+          jq --null-input --arg message "$1" --arg src "$CICERO_ACTION_SRC/" '{
+            message: $message,
+            position: (
+              $message
+              | [scan("at ([^\\s:]+):([0-9]+):([0-9]+):")]
+              | first
+              | if . == null then null else {
+                  file: (.[0] | ltrimstr($src) | sub("^/nix/store/[^/]+-source/"; "")),
+                  line: (.[1] | tonumber),
+                  column: (.[2] | tonumber)
+                } end
+            ),
+            retryable: ($message | test("unable to download|Could not resolve host|Connection refused|timed out"; "i"))
+          } | del(.position | nulls)'
+      fi
+      exit 1
+  }
+
   function evaluate {
-      nix eval --no-write-lock-file --json \
+      stderr=$(mktemp)
+      trap 'rm -f "$stderr"' EXIT
+
+      if nix eval --no-write-lock-file --json \
         ''${CICERO_EVALUATOR_NIX_STACKTRACE:+--show-trace} \
-        "$CICERO_ACTION_SRC"#ciceroActions "$@"
+        "$CICERO_ACTION_SRC"#ciceroActions "$@" \
+        2> "$stderr"
+      then
+          >&2 cat "$stderr"
+      else
+          >&2 cat "$stderr"
+          fail "$(< "$stderr")"
+      fi
   }
 
   case "''${1:-}" in
+    capabilities )
+        echo '{"protocols": [1]}'
+        ;;
     list )
         evaluate --apply builtins.attrNames
         ;;
@@ -0,0 +1,61 @@
+package application
+
+import (
+	"context"
+	"sync"
+)
+
+type afterCommitKey struct{}
+
+type afterCommit struct {
+	mutex sync.Mutex
+	funcs []func()
+	done  bool
+}
+
+// Defers work that is done with the returned context, like starting jobs,
+// until the returned function is called,
+// which should happen once the transaction that belongs to it has committed
+// so that nothing is done for changes that are rolled back.
+// Nested calls defer it until the outermost one does it.
+func WithAfterCommit(ctx context.Context) (context.Context, func()) {
+	if pending, ok := ctx.Value(afterCommitKey{}).(*afterCommit); ok {
+		pending.mutex.Lock()
+		done := pending.done
+		pending.mutex.Unlock()
+		if !done {
+			return ctx, func() {}
+		}
+	}
+
+	pending := &afterCommit{}
+	return context.WithValue(ctx, afterCommitKey{}, pending), pending.run
+}
+
+func (self *afterCommit) run() {
+	self.mutex.Lock()
+	funcs := self.funcs
+	self.funcs = nil
+	self.done = true
+	self.mutex.Unlock()
+
+	// Not holding the lock so that these can defer work themselves.
+	for _, f := range funcs {
+		f()
+	}
+}
+
+// Calls the function once the context's transaction has committed
+// or right away if the context does not defer work or already did it.
+func AfterCommit(ctx context.Context, f func()) {
+	if pending, ok := ctx.Value(afterCommitKey{}).(*afterCommit); ok {
+		pending.mutex.Lock()
+		if !pending.done {
+			pending.funcs = append(pending.funcs, f)
+			pending.mutex.Unlock()
+			return
+		}
+		pending.mutex.Unlock()
+	}
+	f()
+}
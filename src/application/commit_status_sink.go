@@ -0,0 +1,98 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Reports the status of a Run as the status of the commit it was run for.
+// The target is the URL to post the status to as found by `domain.FindCommitStatusUrls()`.
+type CommitStatusSink interface {
+	NotificationSink
+	// Whether a token is configured for the forge the URL belongs to.
+	Accepts(target string) bool
+}
+
+type commitStatusSink struct {
+	client *http.Client
+	// By API base URL like `https://api.github.com`.
+	tokens map[string]string
+}
+
+// GitHub and Gitea have the same API for commit statuses
+// so this works with both given the base URL of their API.
+func NewCommitStatusSink(client *http.Client, tokens map[string]string) CommitStatusSink {
+	return &commitStatusSink{client, tokens}
+}
+
+// Uses the most specific base URL in case one forge is served below another.
+func (self *commitStatusSink) token(target string) (token string, ok bool) {
+	longest := 0
+	for base, t := range self.tokens {
+		base = strings.TrimSuffix(base, "/") + "/"
+		if strings.HasPrefix(target, base) && len(base) > longest {
+			token, ok, longest = t, true, len(base)
+		}
+	}
+	return
+}
+
+func (self *commitStatusSink) Accepts(target string) bool {
+	_, ok := self.token(target)
+	return ok
+}
+
+type commitStatus struct {
+	State       domain.RunStatus `json:"state"`
+	TargetUrl   string           `json:"target_url,omitempty"`
+	Description string           `json:"description"`
+	Context     string           `json:"context"`
+}
+
+func (self *commitStatusSink) Send(ctx context.Context, target string, payload domain.NotificationPayload) error {
+	token, ok := self.token(target)
+	if !ok {
+		return errors.Errorf("No token configured for %s", target)
+	}
+
+	description := "Running"
+	switch payload.Status {
+	case domain.RunSucceeded:
+		description = "Succeeded"
+	case domain.RunFailed:
+		description = "Failed"
+	}
+
+	return postJson(ctx, &http.Client{
+		Transport: tokenTransport{token, self.client.Transport},
+		Timeout:   self.client.Timeout,
+	}, target, commitStatus{
+		// The forges call these the same as we do.
+		State:       payload.Status,
+		TargetUrl:   payload.Url,
+		Description: description,
+		Context:     "cicero/" + payload.ActionName,
+	})
+}
+
+// Adds the token to every request.
+type tokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (self tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := self.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+self.token)
+	return next.RoundTrip(req)
+}
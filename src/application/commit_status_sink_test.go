@@ -0,0 +1,72 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldReportCommitStatus(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		path, authorization string
+		body                map[string]interface{}
+	}
+	requests := make(chan request, 1)
+
+	// Stands in for both GitHub and Gitea, which differ only in the base URL.
+	forge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body := map[string]interface{}{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		requests <- request{req.URL.Path, req.Header.Get("Authorization"), body}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer forge.Close()
+
+	sink := application.NewCommitStatusSink(forge.Client(), map[string]string{
+		forge.URL:             "github-token",
+		forge.URL + "/api/v1": "gitea-token",
+	})
+
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	payload := domain.NotificationPayload{
+		RunId:      uuid.New(),
+		ActionName: "r/ci",
+		Status:     domain.RunPending,
+		Url:        "https://cicero.example.com/run/x",
+	}
+
+	for _, tc := range []struct {
+		target, token string
+	}{
+		{forge.URL + "/repos/o/r/statuses/" + sha, "github-token"},
+		{forge.URL + "/api/v1/repos/o/r/statuses/" + sha, "gitea-token"},
+	} {
+		assert.True(t, sink.Accepts(tc.target))
+		if !assert.NoError(t, sink.Send(context.Background(), tc.target, payload)) {
+			continue
+		}
+
+		req := <-requests
+		assert.Equal(t, "token "+tc.token, req.authorization)
+		assert.Equal(t, map[string]interface{}{
+			"state":       "pending",
+			"target_url":  payload.Url,
+			"description": "Running",
+			"context":     "cicero/r/ci",
+		}, req.body)
+	}
+
+	other := "https://api.github.com/repos/o/r/statuses/" + sha
+	assert.False(t, sink.Accepts(other))
+	assert.Error(t, sink.Send(context.Background(), other, payload))
+}
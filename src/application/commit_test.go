@@ -0,0 +1,46 @@
+package application_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+)
+
+func TestShouldDeferWorkUntilCommitted(t *testing.T) {
+	t.Parallel()
+
+	done := []string{}
+
+	// given
+	ctx, commit := application.WithAfterCommit(context.Background())
+	nestedCtx, commitNested := application.WithAfterCommit(ctx)
+
+	// when
+	application.AfterCommit(nestedCtx, func() {
+		done = append(done, "first")
+		// Work done after the commit defers its own work again.
+		laterCtx, commitLater := application.WithAfterCommit(nestedCtx)
+		application.AfterCommit(laterCtx, func() { done = append(done, "later") })
+		assert.Equal(t, []string{"first"}, done)
+		commitLater()
+	})
+	commitNested()
+
+	// then
+	assert.Empty(t, done, "only the outermost does the work")
+
+	// when
+	commit()
+
+	// then
+	assert.Equal(t, []string{"first", "later"}, done)
+
+	// when
+	application.AfterCommit(ctx, func() { done = append(done, "now") })
+
+	// then
+	assert.Equal(t, []string{"first", "later", "now"}, done, "done right away once committed")
+}
@@ -0,0 +1,39 @@
+package component
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/application/service"
+)
+
+// Periodically deletes cached evaluator outputs older than the max age.
+type EvaluationCachePruner struct {
+	Logger            zerolog.Logger
+	EvaluationService service.EvaluationService
+	MaxAge            time.Duration
+	Interval          time.Duration
+}
+
+func (self *EvaluationCachePruner) Start(ctx context.Context) error {
+	self.Logger.Info().Dur("max-age", self.MaxAge).Msg("Starting")
+
+	ticker := time.NewTicker(self.Interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := self.EvaluationService.PruneCache(time.Now().UTC().Add(-self.MaxAge)); err != nil {
+			self.Logger.Err(err).Msg("Could not prune evaluation cache")
+		} else if deleted > 0 {
+			self.Logger.Info().Int64("deleted", deleted).Msg("Pruned evaluation cache")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
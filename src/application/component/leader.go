@@ -0,0 +1,84 @@
+package component
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Makes sure only one instance runs a component at a time
+// by holding a Postgres session-level advisory lock.
+// Other instances stand by until the lock is released,
+// which also happens if the leader's database session dies.
+type LeaderElection struct {
+	Logger zerolog.Logger
+	Db     *pgxpool.Pool
+	// Name of the lock, instances with the same name compete.
+	Name string
+	// How often to check that the lock is still held.
+	Interval time.Duration
+}
+
+func (self *LeaderElection) Run(ctx context.Context, lead func(context.Context) error) error {
+	conn, err := self.Db.Acquire(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "Could not acquire connection for leader election")
+	}
+	defer conn.Release()
+
+	self.Logger.Info().Str("lock", self.Name).Msg("Standing by for leadership")
+
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, self.Name).Scan(&locked); err != nil {
+			return errors.WithMessage(err, "Could not try to take advisory lock")
+		} else if locked {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(self.Interval):
+		}
+	}
+
+	defer func() {
+		// Use a fresh context as ours may be cancelled already.
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, self.Name); err != nil {
+			self.Logger.Err(err).Msg("Could not release advisory lock")
+		}
+	}()
+
+	self.Logger.Info().Str("lock", self.Name).Msg("Took over leadership")
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- lead(leadCtx) }()
+
+	ticker := time.NewTicker(self.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			if err := conn.Ping(ctx); err != nil {
+				cancel()
+				leadErr := <-done
+				if ctx.Err() != nil {
+					// The ping was aborted because we are stopping.
+					return leadErr
+				}
+				// The lock is gone with the session so someone else may lead now.
+				return errors.WithMessage(err, "Lost database session holding the leadership lock")
+			}
+		}
+	}
+}
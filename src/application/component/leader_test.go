@@ -0,0 +1,101 @@
+package component
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/config/mocks"
+)
+
+func TestShouldOnlyLetOneLead(t *testing.T) {
+	t.Parallel()
+
+	// given
+	db := mocks.BuildDatabase(t)
+	newElection := func() *LeaderElection {
+		return &LeaderElection{
+			Logger:   zerolog.Nop(),
+			Db:       db,
+			Name:     t.Name(),
+			Interval: 10 * time.Millisecond,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leading := make(chan int, 2)
+	stepDown := make(chan struct{})
+	lead := func(i int) func(context.Context) error {
+		return func(context.Context) error {
+			leading <- i
+			<-stepDown
+			return nil
+		}
+	}
+
+	// when
+	go func() { _ = newElection().Run(ctx, lead(1)) }()
+	first := <-leading
+	go func() { _ = newElection().Run(ctx, lead(2)) }()
+
+	// then
+	select {
+	case <-leading:
+		t.Fatal("second instance must not lead while the first does")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// when
+	stepDown <- struct{}{}
+
+	// then
+	select {
+	case second := <-leading:
+		assert.NotEqual(t, first, second)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second instance did not take over")
+	}
+	close(stepDown)
+}
+
+func TestShouldNotReportLossWhenStopped(t *testing.T) {
+	t.Parallel()
+
+	// given
+	election := &LeaderElection{
+		Logger:   zerolog.Nop(),
+		Db:       mocks.BuildDatabase(t),
+		Name:     t.Name(),
+		Interval: time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leading := make(chan struct{})
+
+	// when
+	result := make(chan error, 1)
+	go func() {
+		result <- election.Run(ctx, func(leadCtx context.Context) error {
+			close(leading)
+			// Keep leading while the ticker pings with the cancelled context.
+			time.Sleep(20 * time.Millisecond)
+			<-leadCtx.Done()
+			return nil
+		})
+	}()
+	<-leading
+	cancel()
+
+	// then
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("election did not stop")
+	}
+}
@@ -19,27 +19,38 @@ import (
 
 type NomadEventConsumer struct {
 	Logger            zerolog.Logger
-	FactService       service.FactService
+	ActionService     service.ActionService
 	NomadEventService service.NomadEventService
 	RunService        service.RunService
 	Db                config.PgxIface
-	NomadClient       application.NomadClient
+	Executor          application.Executor
+	StreamHealth      *NomadEventStreamHealth
+	// Processing events is aborted when this is done
+	// instead of when the consumer is stopped,
+	// so that it stops after the events it is processing.
+	// Defaults to never.
+	WorkContext context.Context
 }
 
 func (self *NomadEventConsumer) WithQuerier(querier config.PgxIface) *NomadEventConsumer {
 	return &NomadEventConsumer{
 		Logger:            self.Logger,
-		FactService:       self.FactService.WithQuerier(querier),
+		ActionService:     self.ActionService.WithQuerier(querier),
 		NomadEventService: self.NomadEventService.WithQuerier(querier),
 		RunService:        self.RunService.WithQuerier(querier),
 		Db:                querier,
-		NomadClient:       self.NomadClient,
+		Executor:          self.Executor,
+		StreamHealth:      self.StreamHealth,
+		WorkContext:       self.WorkContext,
 	}
 }
 
-func (self *NomadEventConsumer) Start(ctx context.Context) error {
+func (self *NomadEventConsumer) Start(ctx context.Context) (err error) {
 	self.Logger.Info().Msg("Starting")
 
+	self.StreamHealth.started()
+	defer func() { self.StreamHealth.stopped(err) }()
+
 	index, err := self.NomadEventService.GetLastNomadEvent()
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return errors.WithMessage(err, "Could not get last Nomad event index")
@@ -48,17 +59,33 @@ func (self *NomadEventConsumer) Start(ctx context.Context) error {
 
 	self.Logger.Debug().Uint64("index", index).Msg("Listening to Nomad events")
 
-	stream, err := self.NomadClient.EventStream(ctx, index)
+	// Stop listening when we return so that a restart does not leak streams.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workCtx := self.WorkContext
+	if workCtx == nil {
+		workCtx = context.Background()
+	}
+
+	stream, err := self.Executor.EventStream(ctx, index)
 	if err != nil {
 		return errors.WithMessage(err, "Could not listen to Nomad events")
 	}
+	self.StreamHealth.connect(index - 1)
 
 	for {
-		events := <-stream
+		events, ok := <-stream
+		if !ok {
+			return nil
+		}
 		if events.Err != nil {
 			return errors.WithMessage(events.Err, "Error getting next events from Nomad event stream")
 		}
 
+		application.MetricNomadEventStreamIndex.Set(float64(events.Index))
+		self.StreamHealth.received(events.Index)
+
 		if events.Index < index {
 			// We always get the last event even if we start at
 			// an index greater than the last so we have to ignore it.
@@ -67,45 +94,69 @@ func (self *NomadEventConsumer) Start(ctx context.Context) error {
 		}
 
 		for _, event := range events.Events {
-			if err := self.Db.BeginFunc(ctx, func(tx pgx.Tx) error {
+			eventCtx, applyMetrics := application.WithPendingMetrics(workCtx)
+			if err := self.Db.BeginFunc(eventCtx, func(tx pgx.Tx) error {
 				self.Logger.Debug().Uint64("index", event.Index).Msg("Processing Nomad Event")
-				return self.WithQuerier(tx).processNomadEvent(&event)
+				return self.WithQuerier(tx).processNomadEvent(eventCtx, &event)
 			}); err != nil {
 				return errors.WithMessagef(err, "Error processing Nomad event with index: %d", event.Index)
 			}
+			applyMetrics()
+			application.MetricNomadEventProcessedIndex.Set(float64(event.Index))
+			self.StreamHealth.processed(event.Index)
 		}
 
 		index = events.Index
+
+		select {
+		case <-ctx.Done():
+			self.Logger.Info().Uint64("index", index).Msg("Stopped after processing Nomad events")
+			return nil
+		default:
+		}
 	}
 }
 
-func (self *NomadEventConsumer) processNomadEvent(event *nomad.Event) error {
-	if err := self.handleNomadEvent(event); err != nil {
-		return errors.WithMessage(err, "Error handling Nomad event")
-	}
-	if err := self.NomadEventService.Save(event); err != nil {
+func (self *NomadEventConsumer) processNomadEvent(ctx context.Context, event *nomad.Event) error {
+	if created, err := self.NomadEventService.Save(event); err != nil {
 		return errors.WithMessage(err, "Error to save Nomad event")
+	} else if !created {
+		self.Logger.Debug().Uint64("index", event.Index).Msg("Ignoring Nomad event that was already processed")
+		return nil
+	}
+	if err := self.handleNomadEvent(ctx, event); err != nil {
+		return errors.WithMessage(err, "Error handling Nomad event")
 	}
 	return nil
 }
 
-func (self *NomadEventConsumer) handleNomadEvent(event *nomad.Event) error {
+func (self *NomadEventConsumer) handleNomadEvent(ctx context.Context, event *nomad.Event) error {
 	if event.Topic == "Allocation" && event.Type == "AllocationUpdated" {
 		allocation, err := event.Allocation()
 		if err != nil {
 			return errors.WithMessage(err, "Error getting Nomad event's allocation")
 		}
-		return self.handleNomadAllocationEvent(allocation)
+		return self.handleNomadAllocationEvent(ctx, allocation)
 	}
 	return nil
 }
 
-func (self *NomadEventConsumer) handleNomadAllocationEvent(allocation *nomad.Allocation) error {
-	if !allocation.ClientTerminalStatus() {
-		self.Logger.Debug().Str("ClientStatus", allocation.ClientStatus).Msg("Ignoring allocation event with non-terminal client status")
-		return nil
+// Returns the trace context that was given to the job's tasks, if any.
+func jobTraceparent(job *nomad.Job) string {
+	if job == nil {
+		return ""
+	}
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			if traceparent, ok := task.Env[application.TraceparentEnv]; ok {
+				return traceparent
+			}
+		}
 	}
+	return ""
+}
 
+func (self *NomadEventConsumer) handleNomadAllocationEvent(ctx context.Context, allocation *nomad.Allocation) (err error) {
 	id, err := uuid.Parse(allocation.JobID)
 	if err != nil {
 		return nil
@@ -120,27 +171,51 @@ func (self *NomadEventConsumer) handleNomadAllocationEvent(allocation *nomad.All
 		return err
 	}
 
-	if output, err := self.RunService.GetOutputByNomadJobId(id); err != nil && !pgxscan.NotFound(err) {
+	// Nomad leaves the job out of allocation events
+	// so look at the one that was submitted instead.
+	runDef, err := self.RunService.GetDefinitionByNomadJobId(id)
+	if err != nil && !pgxscan.NotFound(err) {
 		return err
-	} else {
-		var factValue *interface{}
-		for _, state := range allocation.TaskStates {
-			if state.Failed {
-				factValue = output.Failure
-			} else {
-				factValue = output.Success
-			}
-		}
-		if factValue != nil {
-			fact := domain.Fact{
-				RunId: &run.NomadJobID,
-				Value: factValue,
-			}
-			if err := self.FactService.Save(&fact, nil); err != nil {
-				return errors.WithMessage(err, "Could not publish Fact")
-			}
+	}
+
+	// Continue the trace in which the Run was started.
+	ctx, span := application.StartSpan(application.WithTraceparent(ctx, jobTraceparent(runDef.Job)), "NomadEventConsumer.handleNomadAllocationEvent", application.SpanKindConsumer)
+	span.SetAttribute("nomad.job.id", allocation.JobID)
+	span.SetAttribute("nomad.alloc.id", allocation.ID)
+	span.SetAttribute("nomad.alloc.client_status", allocation.ClientStatus)
+	defer func() { span.End(err) }()
+
+	if err := self.NomadEventService.SaveAllocation(run.NomadJobID, allocation); err != nil {
+		return err
+	}
+
+	if !allocation.ClientTerminalStatus() {
+		self.Logger.Debug().Str("ClientStatus", allocation.ClientStatus).Msg("Ignoring allocation event with non-terminal client status")
+		return nil
+	}
+
+	if run.FinishedAt != nil {
+		self.Logger.Debug().Str("nomad-job-id", allocation.JobID).Msg("Ignoring allocation event for Run that already ended")
+		return nil
+	}
+
+	// Without any task states the allocation never really ran.
+	ran := len(allocation.TaskStates) > 0
+	outcome := allocationOutcome(allocation)
+	if run.Outcome != nil {
+		// The Run was stopped by Cicero.
+		outcome = *run.Outcome
+	} else if outcome == domain.RunOutcomeSuccess {
+		// Only succeed once all task groups are done.
+		if allocs, err := self.NomadEventService.GetAllocationsByNomadJobId(id); err != nil {
+			return err
+		} else if !allGroupsComplete(runDef.Job, allocs) {
+			self.Logger.Debug().Str("nomad-job-id", allocation.JobID).Str("group", allocation.TaskGroup).Msg("Waiting for other allocations to complete")
+			return nil
 		}
 	}
+	run.Outcome = &outcome
+	span.SetAttribute("cicero.run.outcome", string(outcome))
 
 	modifyTime := time.Unix(
 		allocation.ModifyTime/int64(time.Second),
@@ -148,13 +223,74 @@ func (self *NomadEventConsumer) handleNomadAllocationEvent(allocation *nomad.All
 	).UTC()
 	run.FinishedAt = &modifyTime
 
-	if err := self.RunService.End(&run); err != nil {
-		return errors.WithMessagef(err, "Failed to end Run with ID %q", run.NomadJobID)
+	if ended, err := self.ActionService.WithContext(ctx).EndRun(&run, allocation.TaskGroup, allocationExitCode(allocation), ran || outcome != domain.RunOutcomeFailure); err != nil {
+		return err
+	} else if !ended {
+		self.Logger.Debug().Str("nomad-job-id", allocation.JobID).Msg("Run was ended concurrently")
+		return nil
 	}
 
-	if _, _, err := self.NomadClient.JobsDeregister(run.NomadJobID.String(), false, &nomad.WriteOptions{}); err != nil {
-		return errors.WithMessagef(err, "Failed to deregister Nomad job with ID %q", run.NomadJobID)
+	if err := self.Executor.Cancel(run.NomadJobID.String()); err != nil {
+		return errors.WithMessagef(err, "Failed to cancel job with ID %q", run.NomadJobID)
 	}
 
 	return nil
 }
+
+// Tells how the allocation ended if Cicero did not stop it.
+func allocationOutcome(allocation *nomad.Allocation) domain.RunOutcome {
+	if allocation.ClientStatus == nomad.AllocClientStatusLost {
+		return domain.RunOutcomeLost
+	} else if len(allocation.TaskStates) == 0 {
+		return domain.RunOutcomeFailure
+	}
+	for _, state := range allocation.TaskStates {
+		if state.Failed {
+			return domain.RunOutcomeFailure
+		}
+	}
+	return domain.RunOutcomeSuccess
+}
+
+// Tells whether every task group of the job has
+// as many complete allocations as it asks for.
+// Without the job, like for Runs that have no definition,
+// only the allocation at hand is known so it is assumed to be the last.
+func allGroupsComplete(job *nomad.Job, allocs []*nomad.Allocation) bool {
+	if job == nil {
+		return true
+	}
+
+	complete := map[string]int{}
+	for _, alloc := range allocs {
+		if alloc.ClientStatus == nomad.AllocClientStatusComplete {
+			complete[alloc.TaskGroup]++
+		}
+	}
+
+	for _, group := range job.TaskGroups {
+		count := 1
+		if group.Count != nil {
+			count = *group.Count
+		}
+		if group.Name != nil && complete[*group.Name] < count {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the highest exit code of the allocation's tasks
+// or nil if none of them terminated.
+func allocationExitCode(allocation *nomad.Allocation) (exitCode *int) {
+	for _, state := range allocation.TaskStates {
+		for _, event := range state.Events {
+			if event.Type == nomad.TaskTerminated && (exitCode == nil || event.ExitCode > *exitCode) {
+				code := event.ExitCode
+				exitCode = &code
+			}
+		}
+	}
+	return
+}
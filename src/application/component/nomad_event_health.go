@@ -0,0 +1,140 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/input-output-hk/cicero/src/application"
+)
+
+// Tracks the NomadEventConsumer for health checks.
+// The nil NomadEventStreamHealth tracks nothing.
+type NomadEventStreamHealth struct {
+	// How long the stream may be down or stuck before it is unhealthy.
+	// Keeps restarts by the supervisor from failing the check.
+	Grace time.Duration
+
+	mutex          sync.Mutex
+	leading        bool
+	leadingSince   time.Time
+	connected      bool
+	streamIndex    uint64
+	processedIndex uint64
+	processedAt    time.Time
+	failures       int
+	lastError      string
+	lastErrorAt    time.Time
+}
+
+func (self *NomadEventStreamHealth) started() {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.leading = true
+	self.leadingSince = time.Now()
+	self.connected = false
+}
+
+// Takes the index of the last event processed before.
+func (self *NomadEventStreamHealth) connect(index uint64) {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.connected = true
+	self.streamIndex = index
+	self.processedIndex = index
+	// Nothing is stuck before the first event arrives.
+	self.processedAt = time.Now()
+}
+
+func (self *NomadEventStreamHealth) received(index uint64) {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.streamIndex = index
+	self.failures = 0
+}
+
+func (self *NomadEventStreamHealth) processed(index uint64) {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.processedIndex = index
+	self.processedAt = time.Now()
+}
+
+func (self *NomadEventStreamHealth) stopped(err error) {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.leading = false
+	self.connected = false
+	if err != nil {
+		self.failures++
+		self.lastError = err.Error()
+		self.lastErrorAt = time.Now()
+	}
+}
+
+func (self *NomadEventStreamHealth) HealthCheck() application.HealthCheck {
+	return application.HealthCheck{
+		Name: "nomad-events",
+		// Not for liveness as restarting does not help while Nomad is down.
+		Check: self.check,
+	}
+}
+
+func (self *NomadEventStreamHealth) check(context.Context) (interface{}, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	now := time.Now()
+
+	detail := map[string]interface{}{
+		"stream-index":    self.streamIndex,
+		"processed-index": self.processedIndex,
+		"lag":             uint64(0),
+		"failures":        self.failures,
+	}
+	if self.streamIndex > self.processedIndex {
+		detail["lag"] = self.streamIndex - self.processedIndex
+	}
+	if self.lastError != "" {
+		detail["last-error"] = self.lastError
+		detail["last-error-at"] = self.lastErrorAt
+	}
+
+	switch {
+	case self.connected:
+		detail["state"] = "streaming"
+		if detail["lag"] != uint64(0) && now.Sub(self.processedAt) > self.Grace {
+			return detail, fmt.Errorf("No event processed since %s", self.processedAt.Format(time.RFC3339))
+		}
+	case self.leading:
+		detail["state"] = "connecting"
+		if now.Sub(self.leadingSince) > self.Grace {
+			return detail, fmt.Errorf("Not connected since %s", self.leadingSince.Format(time.RFC3339))
+		}
+	default:
+		// Another instance may be the leader.
+		detail["state"] = "standing by"
+	}
+
+	if self.lastError != "" && !self.connected && now.Sub(self.lastErrorAt) <= self.Grace {
+		return detail, fmt.Errorf("Failed %d times, last: %s", self.failures, self.lastError)
+	}
+
+	return detail, nil
+}
@@ -0,0 +1,60 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldReportNomadEventStreamHealth(t *testing.T) {
+	t.Parallel()
+
+	health := &NomadEventStreamHealth{Grace: time.Minute}
+	check := func() (map[string]interface{}, error) {
+		detail, err := health.check(context.Background())
+		return detail.(map[string]interface{}), err
+	}
+
+	detail, err := check()
+	assert.NoError(t, err)
+	assert.Equal(t, "standing by", detail["state"])
+
+	// Restarted by the supervisor, for example because Nomad is unreachable.
+	health.started()
+	health.stopped(errors.New("connection refused"))
+	health.started()
+	health.stopped(errors.New("connection refused"))
+	detail, err = check()
+	assert.EqualError(t, err, "Failed 2 times, last: connection refused")
+	assert.Equal(t, 2, detail["failures"])
+
+	health.started()
+	health.connect(10)
+	health.received(12)
+	detail, err = check()
+	assert.NoError(t, err)
+	assert.Equal(t, "streaming", detail["state"])
+	assert.Equal(t, uint64(2), detail["lag"])
+	assert.Equal(t, 0, detail["failures"])
+
+	// Stuck processing events.
+	health.processedAt = time.Now().Add(-2 * time.Minute)
+	_, err = check()
+	assert.Error(t, err)
+
+	health.processed(12)
+	detail, err = check()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), detail["lag"])
+
+	// Leading but unable to connect.
+	health.stopped(nil)
+	health.started()
+	health.leadingSince = time.Now().Add(-2 * time.Minute)
+	detail, err = check()
+	assert.Error(t, err)
+	assert.Equal(t, "connecting", detail["state"])
+}
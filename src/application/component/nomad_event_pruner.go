@@ -0,0 +1,39 @@
+package component
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/application/service"
+)
+
+// Periodically deletes raw Nomad events older than the retention period.
+type NomadEventPruner struct {
+	Logger            zerolog.Logger
+	NomadEventService service.NomadEventService
+	Retention         time.Duration
+	Interval          time.Duration
+}
+
+func (self *NomadEventPruner) Start(ctx context.Context) error {
+	self.Logger.Info().Dur("retention", self.Retention).Msg("Starting")
+
+	ticker := time.NewTicker(self.Interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := self.NomadEventService.Prune(time.Now().UTC().Add(-self.Retention)); err != nil {
+			self.Logger.Err(err).Msg("Could not prune Nomad events")
+		} else if deleted > 0 {
+			self.Logger.Info().Int64("deleted", deleted).Msg("Pruned Nomad events")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
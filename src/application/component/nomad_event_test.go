@@ -0,0 +1,456 @@
+package component
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	appmocks "github.com/input-output-hk/cicero/src/application/mocks"
+	"github.com/input-output-hk/cicero/src/application/service"
+	"github.com/input-output-hk/cicero/src/config/mocks"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Returns the same definitions for every action.
+type staticEvaluationService struct {
+	action domain.ActionDefinition
+	run    domain.RunDefinition
+}
+
+func (self *staticEvaluationService) WithContext(context.Context) service.EvaluationService {
+	return self
+}
+
+func (self *staticEvaluationService) ResolveSource(string) (string, error) {
+	return "static", nil
+}
+
+func (self *staticEvaluationService) PruneCache(time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (self *staticEvaluationService) PruneSources(time.Time) (int, error) {
+	return 0, nil
+}
+
+func (self *staticEvaluationService) ListActions(string, string) ([]string, error) {
+	return []string{"test"}, nil
+}
+
+func (self *staticEvaluationService) EvaluateAction(string, string, string, uuid.UUID) (domain.ActionDefinition, error) {
+	return self.action, nil
+}
+
+func (self *staticEvaluationService) EvaluateRun(string, string, string, uuid.UUID, map[string]interface{}) (domain.RunDefinition, error) {
+	job := *self.run.Job
+	return domain.RunDefinition{Output: self.run.Output, Job: &job}, nil
+}
+
+func TestShouldPublishOutputWhenRunEnds(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+
+	// given
+	db := mocks.BuildDatabase(t)
+	nomadClient := appmocks.NewNomadClient()
+	executor := application.NewNomadExecutor(nomadClient, &logger)
+
+	var success interface{} = map[string]interface{}{"done": true, "run": "{{.RunId}}"}
+	evaluationService := &staticEvaluationService{
+		action: domain.ActionDefinition{
+			Inputs: map[string]domain.InputDefinition{
+				"start": {Match: "start: string"},
+			},
+		},
+		run: domain.RunDefinition{
+			Output: domain.RunOutput{Success: &success, Template: true},
+			Job:    &nomad.Job{},
+		},
+	}
+
+	notified := make(chan domain.NotificationPayload, 1)
+	notificationServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		payload := domain.NotificationPayload{}
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&payload))
+		notified <- payload
+	}))
+	defer notificationServer.Close()
+
+	notificationService := service.NewNotificationService(
+		db,
+		map[string]application.NotificationSink{"webhook": application.NewWebhookNotificationSink(notificationServer.Client())},
+		[]domain.NotificationRule{{Sink: "webhook", Target: notificationServer.URL, On: []domain.RunStatus{domain.RunSucceeded}}},
+		"", 1, &logger,
+	)
+
+	runService := service.NewRunService(db, "http://127.0.0.1:3100", executor, time.Hour, &logger)
+	actionService := service.NewActionService(db, executor, runService, evaluationService, notificationService, nil, &logger)
+	factService := service.NewFactService(db, actionService, &logger)
+	nomadEventService := service.NewNomadEventService(db, runService, &logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	consumer := NomadEventConsumer{
+		Logger:            logger,
+		ActionService:     actionService,
+		NomadEventService: nomadEventService,
+		RunService:        runService,
+		Db:                db,
+		Executor:          executor,
+	}
+	consumerErr := make(chan error, 1)
+	go func() { consumerErr <- consumer.Start(ctx) }()
+
+	// when
+	action, err := actionService.Create("static", "test")
+	assert.Nil(t, err)
+	assert.Empty(t, nomadClient.Jobs(), "action must not run without input")
+
+	assert.Nil(t, factService.Save(&domain.Fact{Value: map[string]interface{}{"start": "now"}}, nil))
+
+	// then
+	jobs := nomadClient.Jobs()
+	assert.Len(t, jobs, 1)
+
+	run, err := runService.GetLatestByActionId(action.ID)
+	assert.Nil(t, err)
+	assert.Contains(t, jobs, run.NomadJobID.String())
+
+	// when
+	nomadClient.EmitAllocation(&nomad.Allocation{
+		ID:           uuid.New().String(),
+		JobID:        run.NomadJobID.String(),
+		ClientStatus: nomad.AllocClientStatusComplete,
+		TaskStates:   map[string]*nomad.TaskState{"task": {State: "dead"}},
+		ModifyTime:   time.Now().UnixNano(),
+	})
+
+	// then
+	var facts []*domain.Fact
+	for deadline := time.Now().Add(10 * time.Second); len(facts) == 0 && time.Now().Before(deadline); {
+		time.Sleep(50 * time.Millisecond)
+		facts, err = factService.GetByRunId(run.NomadJobID)
+		assert.Nil(t, err)
+	}
+	if assert.Len(t, facts, 1) {
+		assert.Equal(t, map[string]interface{}{"done": true, "run": run.NomadJobID.String()}, facts[0].Value)
+	}
+
+	run, err = runService.GetByNomadJobId(run.NomadJobID)
+	assert.Nil(t, err)
+	assert.NotNil(t, run.FinishedAt)
+	if assert.NotNil(t, run.Outcome) {
+		assert.Equal(t, domain.RunOutcomeSuccess, *run.Outcome)
+	}
+	assert.Equal(t, []string{run.NomadJobID.String()}, nomadClient.Deregistered())
+
+	attempted, err := notificationService.Deliver(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attempted)
+	select {
+	case payload := <-notified:
+		assert.Equal(t, run.NomadJobID, payload.RunId)
+		assert.Equal(t, domain.RunSucceeded, payload.Status)
+	default:
+		t.Error("notification was not delivered")
+	}
+
+	cancel()
+	assert.Nil(t, <-consumerErr)
+}
+
+func TestShouldTellAllocationOutcome(t *testing.T) {
+	t.Parallel()
+
+	terminated := func(exitCode int) *nomad.TaskEvent {
+		return &nomad.TaskEvent{Type: nomad.TaskTerminated, ExitCode: exitCode}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		allocation nomad.Allocation
+		outcome    domain.RunOutcome
+		exitCode   *int
+	}{
+		{"never ran", nomad.Allocation{ClientStatus: nomad.AllocClientStatusFailed}, domain.RunOutcomeFailure, nil},
+		{"lost", nomad.Allocation{
+			ClientStatus: nomad.AllocClientStatusLost,
+			TaskStates:   map[string]*nomad.TaskState{"a": {State: "dead"}},
+		}, domain.RunOutcomeLost, nil},
+		{"failed", nomad.Allocation{
+			ClientStatus: nomad.AllocClientStatusFailed,
+			TaskStates: map[string]*nomad.TaskState{
+				"a": {State: "dead", Events: []*nomad.TaskEvent{terminated(0)}},
+				"b": {State: "dead", Failed: true, Events: []*nomad.TaskEvent{terminated(1), terminated(3)}},
+			},
+		}, domain.RunOutcomeFailure, func() *int { i := 3; return &i }()},
+		{"succeeded", nomad.Allocation{
+			ClientStatus: nomad.AllocClientStatusComplete,
+			TaskStates:   map[string]*nomad.TaskState{"a": {State: "dead", Events: []*nomad.TaskEvent{terminated(0)}}},
+		}, domain.RunOutcomeSuccess, func() *int { i := 0; return &i }()},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.outcome, allocationOutcome(&tc.allocation))
+			assert.Equal(t, tc.exitCode, allocationExitCode(&tc.allocation))
+		})
+	}
+}
+
+func TestShouldGiveRunTokenWithoutCiceroEnv(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+
+	// given
+	db := mocks.BuildDatabase(t)
+	nomadClient := appmocks.NewNomadClient()
+	executor := application.NewNomadExecutor(nomadClient, &logger)
+
+	groupName := "group"
+	evaluationService := &staticEvaluationService{
+		action: domain.ActionDefinition{
+			Inputs: map[string]domain.InputDefinition{
+				"start": {Match: "start: string"},
+			},
+		},
+		run: domain.RunDefinition{
+			Job: &nomad.Job{TaskGroups: []*nomad.TaskGroup{{
+				Name:  &groupName,
+				Tasks: []*nomad.Task{{Name: "task"}},
+			}}},
+		},
+	}
+
+	notificationService := service.NewNotificationService(db, nil, nil, "", 1, &logger)
+	runService := service.NewRunService(db, "http://127.0.0.1:3100", executor, time.Hour, &logger)
+	// Without any transformers, not even cicero-env.
+	actionService := service.NewActionService(db, executor, runService, evaluationService, notificationService, nil, &logger)
+	factService := service.NewFactService(db, actionService, &logger)
+
+	// when
+	action, err := actionService.Create("static", "test")
+	assert.Nil(t, err)
+	traceId := "0af7651916cd43dd8448eb211c80319c"
+	ctx := application.WithTraceparent(context.Background(), "00-"+traceId+"-b7ad6b7169203331-01")
+	assert.Nil(t, factService.WithContext(ctx).Save(&domain.Fact{Value: map[string]interface{}{"start": "now"}}, nil))
+
+	// then
+	run, err := runService.GetLatestByActionId(action.ID)
+	assert.Nil(t, err)
+
+	job := nomadClient.Jobs()[run.NomadJobID.String()]
+	if assert.NotNil(t, job) {
+		env := job.TaskGroups[0].Tasks[0].Env
+		assert.NotEmpty(t, env[service.RunTokenEnv])
+		assert.Equal(t, run.NomadJobID.String(), env["CICERO_RUN_ID"])
+		assert.Equal(t, action.ID.String(), env["CICERO_ACTION_ID"])
+		assert.Equal(t, action.Name, env["CICERO_ACTION_NAME"])
+	}
+
+	// The consumer continues the trace from the saved definition.
+	runDef, err := runService.GetDefinitionByNomadJobId(run.NomadJobID)
+	assert.Nil(t, err)
+	assert.Contains(t, jobTraceparent(runDef.Job), traceId)
+}
+
+func TestShouldTellWhetherAllGroupsComplete(t *testing.T) {
+	t.Parallel()
+
+	a, b, two := "a", "b", 2
+	job := &nomad.Job{TaskGroups: []*nomad.TaskGroup{{Name: &a}, {Name: &b, Count: &two}}}
+	alloc := func(group, status string) *nomad.Allocation {
+		return &nomad.Allocation{TaskGroup: group, ClientStatus: status}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		job      *nomad.Job
+		allocs   []*nomad.Allocation
+		complete bool
+	}{
+		{"unknown job", nil, nil, true},
+		{"one group missing", job, []*nomad.Allocation{
+			alloc(a, nomad.AllocClientStatusComplete),
+		}, false},
+		{"one of two allocations complete", job, []*nomad.Allocation{
+			alloc(a, nomad.AllocClientStatusComplete),
+			alloc(b, nomad.AllocClientStatusComplete),
+			alloc(b, nomad.AllocClientStatusRunning),
+		}, false},
+		{"all complete", job, []*nomad.Allocation{
+			alloc(a, nomad.AllocClientStatusComplete),
+			alloc(b, nomad.AllocClientStatusComplete),
+			alloc(b, nomad.AllocClientStatusComplete),
+		}, true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.complete, allGroupsComplete(tc.job, tc.allocs))
+		})
+	}
+}
+
+// Starts a consumer with services on a fresh database
+// that runs the action "test" whenever a fact with "start" is published.
+func startConsumer(t *testing.T, run domain.RunDefinition) (*appmocks.NomadClient, service.ActionService, service.FactService, service.RunService) {
+	t.Helper()
+	logger := zerolog.Nop()
+
+	db := mocks.BuildDatabase(t)
+	nomadClient := appmocks.NewNomadClient()
+	executor := application.NewNomadExecutor(nomadClient, &logger)
+
+	evaluationService := &staticEvaluationService{
+		action: domain.ActionDefinition{
+			Inputs: map[string]domain.InputDefinition{
+				"start": {Match: "start: string"},
+			},
+		},
+		run: run,
+	}
+
+	notificationService := service.NewNotificationService(db, nil, nil, "", 1, &logger)
+	runService := service.NewRunService(db, "http://127.0.0.1:3100", executor, time.Hour, &logger)
+	actionService := service.NewActionService(db, executor, runService, evaluationService, notificationService, nil, &logger)
+	factService := service.NewFactService(db, actionService, &logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := NomadEventConsumer{
+		Logger:            logger,
+		ActionService:     actionService,
+		NomadEventService: service.NewNomadEventService(db, runService, &logger),
+		RunService:        runService,
+		Db:                db,
+		Executor:          executor,
+	}
+	consumerErr := make(chan error, 1)
+	go func() { consumerErr <- consumer.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.Nil(t, <-consumerErr)
+	})
+
+	return nomadClient, actionService, factService, runService
+}
+
+// Returns the Run once it ended or the last state it was seen in.
+func waitForRunEnd(t *testing.T, runService service.RunService, id uuid.UUID) domain.Run {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		run, err := runService.GetByNomadJobId(id)
+		assert.Nil(t, err)
+		if run.FinishedAt != nil || time.Now().After(deadline) {
+			return run
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestShouldEndRunOnceAllGroupsComplete(t *testing.T) {
+	t.Parallel()
+
+	// given
+	a, b := "a", "b"
+	// Only the output of the Run is a template, not that of the group.
+	var success, successB interface{} = map[string]interface{}{"done": "{{.Group}}"}, map[string]interface{}{"done": "{{.Group}}"}
+	nomadClient, actionService, factService, runService := startConsumer(t, domain.RunDefinition{
+		Output: domain.RunOutput{
+			Success:  &success,
+			Groups:   map[string]domain.RunGroupOutput{b: {Success: &successB}},
+			Template: true,
+		},
+		Job: &nomad.Job{TaskGroups: []*nomad.TaskGroup{
+			{Name: &a, Tasks: []*nomad.Task{{Name: "task"}}},
+			{Name: &b, Tasks: []*nomad.Task{{Name: "task"}}},
+		}},
+	})
+
+	action, err := actionService.Create("static", "test")
+	assert.Nil(t, err)
+	assert.Nil(t, factService.Save(&domain.Fact{Value: map[string]interface{}{"start": "now"}}, nil))
+
+	run, err := runService.GetLatestByActionId(action.ID)
+	assert.Nil(t, err)
+	complete := func(group string) {
+		// Like Nomad's event stream, without the job.
+		nomadClient.EmitAllocation(&nomad.Allocation{
+			ID:           uuid.New().String(),
+			JobID:        run.NomadJobID.String(),
+			TaskGroup:    group,
+			ClientStatus: nomad.AllocClientStatusComplete,
+			TaskStates:   map[string]*nomad.TaskState{"task": {State: "dead"}},
+			ModifyTime:   time.Now().UnixNano(),
+		})
+	}
+
+	// when
+	complete(a)
+
+	// then
+	time.Sleep(500 * time.Millisecond)
+	run, err = runService.GetByNomadJobId(run.NomadJobID)
+	assert.Nil(t, err)
+	assert.Nil(t, run.FinishedAt, "Run must not end before all groups completed")
+
+	// when
+	complete(b)
+
+	// then
+	run = waitForRunEnd(t, runService, run.NomadJobID)
+	if assert.NotNil(t, run.Outcome) {
+		assert.Equal(t, domain.RunOutcomeSuccess, *run.Outcome)
+	}
+
+	facts, err := factService.GetByRunId(run.NomadJobID)
+	assert.Nil(t, err)
+	if assert.Len(t, facts, 1) {
+		assert.Equal(t, map[string]interface{}{"done": "{{.Group}}"}, facts[0].Value)
+	}
+}
+
+func TestShouldEndCanceledRunWithoutAllocations(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var canceled interface{} = map[string]interface{}{"canceled": "{{.RunId}}"}
+	_, actionService, factService, runService := startConsumer(t, domain.RunDefinition{
+		Output: domain.RunOutput{Canceled: &canceled, Template: true},
+		Job:    &nomad.Job{},
+	})
+
+	action, err := actionService.Create("static", "test")
+	assert.Nil(t, err)
+	assert.Nil(t, factService.Save(&domain.Fact{Value: map[string]interface{}{"start": "now"}}, nil))
+
+	run, err := runService.GetLatestByActionId(action.ID)
+	assert.Nil(t, err)
+
+	// when
+	assert.Nil(t, actionService.CancelRun(&run))
+
+	// then
+	run, err = runService.GetByNomadJobId(run.NomadJobID)
+	assert.Nil(t, err)
+	assert.NotNil(t, run.FinishedAt)
+	if assert.NotNil(t, run.Outcome) {
+		assert.Equal(t, domain.RunOutcomeCanceled, *run.Outcome)
+	}
+
+	facts, err := factService.GetByRunId(run.NomadJobID)
+	assert.Nil(t, err)
+	if assert.Len(t, facts, 1) {
+		assert.Equal(t, map[string]interface{}{"canceled": run.NomadJobID.String()}, facts[0].Value)
+	}
+}
@@ -0,0 +1,42 @@
+package component
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/application/service"
+)
+
+// Periodically delivers queued notifications.
+type NotificationDispatcher struct {
+	Logger              zerolog.Logger
+	NotificationService service.NotificationService
+	Interval            time.Duration
+}
+
+func (self *NotificationDispatcher) Start(ctx context.Context) error {
+	self.Logger.Info().Msg("Starting")
+
+	ticker := time.NewTicker(self.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while there is a backlog.
+		for {
+			if attempted, err := self.NotificationService.Deliver(ctx); err != nil {
+				self.Logger.Err(err).Msg("Could not deliver notifications")
+				break
+			} else if attempted == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
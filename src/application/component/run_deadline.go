@@ -0,0 +1,52 @@
+package component
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/application/service"
+)
+
+// Periodically stops Runs that are still running after their deadline
+// so that they end as timed out.
+type RunDeadlineWatcher struct {
+	Logger        zerolog.Logger
+	RunService    service.RunService
+	ActionService service.ActionService
+	Interval      time.Duration
+}
+
+func (self *RunDeadlineWatcher) Start(ctx context.Context) error {
+	self.Logger.Info().Msg("Starting")
+
+	ticker := time.NewTicker(self.Interval)
+	defer ticker.Stop()
+
+	for {
+		self.timeOut()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (self *RunDeadlineWatcher) timeOut() {
+	runs, err := self.RunService.GetPastDeadline()
+	if err != nil {
+		self.Logger.Err(err).Msg("Could not get Runs past their deadline")
+		return
+	}
+
+	for _, run := range runs {
+		if stopped, err := self.ActionService.TimeOutRun(run); err != nil {
+			self.Logger.Err(err).Str("id", run.NomadJobID.String()).Msg("Could not time out Run")
+		} else if stopped {
+			self.Logger.Info().Str("id", run.NomadJobID.String()).Msg("Timed out Run")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package component
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/application/service"
+)
+
+// Periodically deletes downloaded sources of actions that were not evaluated for a while.
+type SourcePruner struct {
+	Logger            zerolog.Logger
+	EvaluationService service.EvaluationService
+	MaxAge            time.Duration
+	Interval          time.Duration
+}
+
+func (self *SourcePruner) Start(ctx context.Context) error {
+	self.Logger.Info().Dur("max-age", self.MaxAge).Msg("Starting")
+
+	ticker := time.NewTicker(self.Interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := self.EvaluationService.PruneSources(time.Now().Add(-self.MaxAge)); err != nil {
+			self.Logger.Err(err).Msg("Could not prune sources")
+		} else if deleted > 0 {
+			self.Logger.Info().Int("deleted", deleted).Msg("Pruned sources")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
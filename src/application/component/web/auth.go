@@ -0,0 +1,343 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/input-output-hk/cicero/src/application/service"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+const (
+	sessionCookie = "cicero_session"
+	sessionTtl    = 12 * time.Hour
+
+	loginCookie = "cicero_login"
+	loginTtl    = 10 * time.Minute
+
+	// Form field and header that carry the CSRF token.
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+type identityContextKey struct{}
+
+type csrfContextKey struct{}
+
+// Returns the CSRF token to put in forms, if logged in with a session.
+func csrfTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(csrfContextKey{}).(string)
+	return token
+}
+
+func identityFromContext(ctx context.Context) (domain.Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(domain.Identity)
+	return identity, ok
+}
+
+func withIdentity(req *http.Request, identity domain.Identity) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), identityContextKey{}, identity))
+}
+
+// Finds out who makes the request, either from an API token
+// or from the session cookie set after logging in to the web UI.
+// Does not reject anonymous requests, that is up to `authorize()`.
+func (self *Web) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Already done if the request was dispatched to another route.
+		if _, ok := identityFromContext(req.Context()); ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if header := req.Header.Get("Authorization"); header != "" {
+			str := strings.TrimPrefix(header, "Bearer ")
+			if str == header {
+				self.unauthenticated(w, req, errors.New("Unsupported authorization scheme"))
+				return
+			}
+
+			if strings.HasPrefix(str, service.RunTokenPrefix) {
+				runId, err := self.RunService.AuthenticateToken(str)
+				if err != nil {
+					self.unauthenticated(w, req, err)
+					return
+				}
+
+				req = withIdentity(req, domain.Identity{
+					Name: "run:" + runId.String(),
+					Role: domain.RolePublisher,
+					Run:  &runId,
+				})
+			} else {
+				token, err := self.ApiTokenService.Authenticate(str)
+				if err != nil {
+					self.unauthenticated(w, req, err)
+					return
+				}
+
+				req = withIdentity(req, token.Identity())
+			}
+		} else if cookie, err := req.Cookie(sessionCookie); err == nil {
+			identity := domain.Identity{}
+			if err := self.verify(sessionCookie, cookie.Value, &identity); err != nil {
+				// Treat expired or tampered sessions as absent so the user can log in again.
+				self.Logger.Debug().Err(err).Msg("Ignoring invalid session")
+			} else {
+				token := self.csrfToken(cookie.Value)
+				if !isSafeMethod(req.Method) && !hmac.Equal([]byte(token), []byte(requestCsrfToken(req))) {
+					self.Error(w, errors.New("Missing or invalid CSRF token"), http.StatusForbidden)
+					return
+				}
+				req = withIdentity(req, identity)
+				req = req.WithContext(context.WithValue(req.Context(), csrfContextKey{}, token))
+			}
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Only lets requests through that are authenticated with at least the given role.
+// Every route except the login and static ones must be wrapped with this
+// or `authorizeRun()`.
+func (self *Web) authorize(role domain.Role, handler http.HandlerFunc) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if identity, ok := identityFromContext(req.Context()); !ok {
+			self.unauthenticated(w, req, errors.New("Not logged in"))
+		} else if identity.Run != nil {
+			self.Error(w, errors.New("Run tokens may only publish facts for their Run"), http.StatusForbidden)
+		} else if !identity.Role.Includes(role) {
+			self.Error(w, fmt.Errorf("%q has role %s but this requires role %s", identity.Name, identity.Role, role), http.StatusForbidden)
+		} else {
+			handler(w, req)
+		}
+	}
+}
+
+// Only lets requests through that are authenticated
+// with the token of the Run given in the route's `id` variable.
+func (self *Web) authorizeRun(handler http.HandlerFunc) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if identity, ok := identityFromContext(req.Context()); !ok {
+			self.unauthenticated(w, req, errors.New("Not logged in"))
+		} else if identity.Run == nil || identity.Run.String() != mux.Vars(req)["id"] {
+			self.Error(w, fmt.Errorf("%q may not publish facts for Run %q, only its token may", identity.Name, mux.Vars(req)["id"]), http.StatusForbidden)
+		} else {
+			handler(w, req)
+		}
+	}
+}
+
+// Who makes the request, for the audit log.
+func (self *Web) actor(req *http.Request) *domain.Actor {
+	identity, ok := identityFromContext(req.Context())
+	if !ok {
+		return nil
+	}
+
+	return &domain.Actor{
+		Identity: identity,
+		Request: &domain.AuditRequest{
+			Method:       req.Method,
+			Path:         req.URL.Path,
+			RemoteAddr:   req.RemoteAddr,
+			ForwardedFor: req.Header.Get("X-Forwarded-For"),
+			UserAgent:    req.UserAgent(),
+		},
+	}
+}
+
+// Sends browsers to log in. API clients are told to authenticate instead.
+func (self *Web) unauthenticated(w http.ResponseWriter, req *http.Request, err error) {
+	if req.Method == http.MethodGet && !strings.HasPrefix(req.URL.Path, "/api/") && req.Header.Get("Authorization") == "" {
+		http.Redirect(w, req, "/login?"+url.Values{"return": {req.URL.RequestURI()}}.Encode(), http.StatusFound)
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", `Bearer realm="cicero"`)
+	self.Error(w, err, http.StatusUnauthorized)
+}
+
+type login struct {
+	State  string
+	Return string
+}
+
+func (self *Web) LoginGet(w http.ResponseWriter, req *http.Request) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		self.ServerError(w, err)
+		return
+	}
+
+	l := login{
+		State:  base64.RawURLEncoding.EncodeToString(state),
+		Return: req.URL.Query().Get("return"),
+	}
+	if value, err := self.sign(loginCookie, l, loginTtl); err != nil {
+		self.ServerError(w, err)
+		return
+	} else {
+		self.setCookie(w, req, loginCookie, value, loginTtl)
+	}
+
+	http.Redirect(w, req, self.Authenticator.LoginUrl(l.State), http.StatusFound)
+}
+
+func (self *Web) LoginCallbackGet(w http.ResponseWriter, req *http.Request) {
+	l := login{}
+	if cookie, err := req.Cookie(loginCookie); err != nil {
+		self.BadRequest(w, errors.WithMessage(err, "Login was not started here"))
+		return
+	} else if err := self.verify(loginCookie, cookie.Value, &l); err != nil {
+		self.BadRequest(w, errors.WithMessage(err, "Invalid login"))
+		return
+	} else if !hmac.Equal([]byte(l.State), []byte(req.URL.Query().Get("state"))) {
+		self.BadRequest(w, errors.New("Login state does not match"))
+		return
+	}
+	self.setCookie(w, req, loginCookie, "", -1)
+
+	identity, err := self.Authenticator.Callback(req)
+	if err != nil {
+		self.Error(w, errors.WithMessage(err, "Could not log in"), http.StatusUnauthorized)
+		return
+	}
+
+	if value, err := self.sign(sessionCookie, identity, sessionTtl); err != nil {
+		self.ServerError(w, err)
+		return
+	} else {
+		self.setCookie(w, req, sessionCookie, value, sessionTtl)
+	}
+
+	self.Logger.Info().Str("name", identity.Name).Str("role", identity.Role.String()).Msg("Logged in")
+
+	// Only redirect to our own pages.
+	if strings.HasPrefix(l.Return, "/") && !strings.HasPrefix(l.Return, "//") {
+		http.Redirect(w, req, l.Return, http.StatusFound)
+	} else {
+		http.Redirect(w, req, "/", http.StatusFound)
+	}
+}
+
+func (self *Web) LogoutGet(w http.ResponseWriter, req *http.Request) {
+	self.setCookie(w, req, sessionCookie, "", -1)
+	http.Redirect(w, req, "/", http.StatusFound)
+}
+
+// Browsers send the session along with every request to Cicero,
+// including those that other sites make them send,
+// so requests that change anything must also carry this token,
+// which only Cicero's own pages know.
+func (self *Web) csrfToken(session string) string {
+	return base64.RawURLEncoding.EncodeToString(self.mac([]byte("csrf\x00" + session)))
+}
+
+func requestCsrfToken(req *http.Request) string {
+	if token := req.Header.Get(csrfHeader); token != "" {
+		return token
+	}
+	return req.PostFormValue(csrfField)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// SameSite=Lax keeps browsers from sending the session with requests
+// that other sites make in the background, like with a form posted by a script.
+// It is still sent with top-level navigations to Cicero, like following a link,
+// so it does not protect against CSRF on its own, see csrfToken.
+func (self *Web) setCookie(w http.ResponseWriter, req *http.Request, name, value string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+type signed struct {
+	// Keeps values signed for one purpose from being used for another.
+	Kind    string          `json:"kind"`
+	Value   json.RawMessage `json:"value"`
+	Expires time.Time       `json:"expires"`
+}
+
+// Encodes the value so that it can be given to the client
+// and verified with `verify()` when it comes back.
+func (self *Web) sign(kind string, value interface{}, ttl time.Duration) (string, error) {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(signed{Kind: kind, Value: valueJson, Expires: time.Now().Add(ttl)})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(self.mac(payload)), nil
+}
+
+func (self *Web) verify(kind, str string, value interface{}) error {
+	parts := strings.SplitN(str, ".", 2)
+	if len(parts) != 2 {
+		return errors.New("Malformed signed value")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return err
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(mac, self.mac(payload)) {
+		return errors.New("Invalid signature")
+	}
+
+	s := signed{}
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return err
+	}
+	if s.Kind != kind {
+		return fmt.Errorf("Expected signed %s but got %s", kind, s.Kind)
+	}
+	if time.Now().After(s.Expires) {
+		return errors.New("Expired")
+	}
+
+	return json.Unmarshal(s.Value, value)
+}
+
+func (self *Web) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, self.SessionKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,297 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/application/service"
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+type staticApiTokenService struct {
+	service.ApiTokenService
+	tokens map[string]domain.ApiToken
+}
+
+func (self staticApiTokenService) WithQuerier(config.PgxIface) service.ApiTokenService {
+	return self
+}
+
+func (self staticApiTokenService) GetAll() (tokens []*domain.ApiToken, _ error) {
+	for _, token := range self.tokens {
+		token := token
+		tokens = append(tokens, &token)
+	}
+	return
+}
+
+func (self staticApiTokenService) Authenticate(str string) (domain.ApiToken, error) {
+	if token, ok := self.tokens[str]; ok {
+		return token, nil
+	}
+	return domain.ApiToken{}, errors.New("unknown token")
+}
+
+type staticRunService struct {
+	service.RunService
+	tokens map[string]uuid.UUID
+}
+
+func (self staticRunService) AuthenticateToken(str string) (uuid.UUID, error) {
+	if id, ok := self.tokens[str]; ok {
+		return id, nil
+	}
+	return uuid.UUID{}, errors.New("unknown token")
+}
+
+func (self staticRunService) GetByNomadJobId(uuid.UUID) (domain.Run, error) {
+	return domain.Run{}, pgx.ErrNoRows
+}
+
+var tokenRunId = uuid.New()
+
+func buildAuthWeb(t *testing.T, localRole domain.Role) (*Web, *mux.Router) {
+	self := &Web{
+		Logger: zerolog.Nop(),
+		ApiTokenService: staticApiTokenService{tokens: map[string]domain.ApiToken{
+			"cicero_viewer": {Name: "viewer", Role: domain.RoleViewer},
+			"cicero_admin":  {Name: "admin", Role: domain.RoleAdmin},
+		}},
+		RunService:    staticRunService{tokens: map[string]uuid.UUID{"cicero_run_token": tokenRunId}},
+		Authenticator: application.NewLocalAuthenticator("/login/callback", domain.Identity{Name: "local", Role: localRole}),
+		SessionKey:    []byte("secret"),
+	}
+
+	router, err := self.router(context.Background())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return self, router
+}
+
+var routeVarRegexp = regexp.MustCompile(`\{[^}]+\}`)
+
+func TestShouldAuthorizeEveryRoute(t *testing.T) {
+	t.Parallel()
+
+	_, router := buildAuthWeb(t, domain.RoleViewer)
+
+	// Webhooks are authenticated by their signatures instead
+	// and health probes cannot authenticate.
+	public := regexp.MustCompile(`^/(login|logout|healthz|readyz|static|documentation|_dispatch|api/webhook)(/|$)`)
+
+	count := 0
+	assert.NoError(t, router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil || public.MatchString(tmpl) {
+			return nil
+		}
+
+		target := routeVarRegexp.ReplaceAllString(tmpl, uuid.New().String())
+		if queries, err := route.GetQueriesTemplates(); err == nil && len(queries) > 0 {
+			target += "?" + strings.Join(queries, "&")
+		}
+
+		for _, method := range methods {
+			count++
+
+			// The handlers would panic because no services are given,
+			// so the route must not be reachable anonymously.
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+
+			if method == http.MethodGet && !strings.HasPrefix(tmpl, "/api/") {
+				assert.Equal(t, http.StatusFound, w.Code, "%s %s", method, tmpl)
+				assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "/login?"), "%s %s", method, tmpl)
+			} else {
+				assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s", method, tmpl)
+			}
+		}
+
+		return nil
+	}))
+
+	assert.Greater(t, count, 30)
+}
+
+func TestShouldCheckRole(t *testing.T) {
+	t.Parallel()
+
+	_, router := buildAuthWeb(t, domain.RoleViewer)
+
+	for token, status := range map[string]int{
+		"cicero_viewer":  http.StatusForbidden,
+		"cicero_admin":   http.StatusOK,
+		"cicero_unknown": http.StatusUnauthorized,
+		"":               http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/token", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, status, w.Code, token)
+	}
+}
+
+func TestShouldOnlyAcceptRunTokenForItsRun(t *testing.T) {
+	t.Parallel()
+
+	_, router := buildAuthWeb(t, domain.RoleViewer)
+
+	for _, tc := range []struct {
+		token, method, target string
+		status                int
+	}{
+		// passes authorization, then the stub does not find the Run
+		{"cicero_run_token", http.MethodPost, "/api/run/" + tokenRunId.String() + "/fact", http.StatusNotFound},
+		{"cicero_run_token", http.MethodPost, "/api/run/" + uuid.New().String() + "/fact", http.StatusForbidden},
+		{"cicero_run_token", http.MethodGet, "/api/run", http.StatusForbidden},
+		{"cicero_run_token", http.MethodPost, "/api/fact", http.StatusForbidden},
+		{"cicero_run_revoked", http.MethodPost, "/api/run/" + tokenRunId.String() + "/fact", http.StatusUnauthorized},
+		{"cicero_admin", http.MethodPost, "/api/run/" + tokenRunId.String() + "/fact", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.status, w.Code, "%s %s %s", tc.token, tc.method, tc.target)
+	}
+}
+
+func TestShouldLogIn(t *testing.T) {
+	t.Parallel()
+
+	self, router := buildAuthWeb(t, domain.RoleAdmin)
+
+	serve := func(target string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// anonymous users are sent to log in
+	w := serve("/api/token?x", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve("/login?"+url.Values{"return": {"/api/token"}}.Encode(), nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+	loginCookies := w.Result().Cookies()
+
+	// the login cookie must not be usable as a session
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/token", []*http.Cookie{{
+		Name:  sessionCookie,
+		Value: loginCookies[0].Value,
+	}}).Code)
+
+	// a callback without the login cookie is rejected
+	callback := w.Header().Get("Location")
+	assert.Equal(t, http.StatusBadRequest, serve(callback, nil).Code)
+
+	w = serve(callback, loginCookies)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/api/token", w.Header().Get("Location"))
+
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			session = cookie
+		}
+	}
+	if !assert.NotNil(t, session) {
+		return
+	}
+
+	identity := domain.Identity{}
+	assert.NoError(t, self.verify(sessionCookie, session.Value, &identity))
+	assert.Equal(t, domain.Identity{Name: "local", Role: domain.RoleAdmin}, identity)
+
+	assert.Equal(t, http.StatusOK, serve("/api/token", []*http.Cookie{session}).Code)
+
+	// tampering invalidates the session
+	session.Value = "x" + session.Value
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/token", []*http.Cookie{session}).Code)
+}
+
+func TestShouldRequireCsrfTokenWithSession(t *testing.T) {
+	t.Parallel()
+
+	self, router := buildAuthWeb(t, domain.RoleViewer)
+
+	session, err := self.sign(sessionCookie, domain.Identity{Name: "operator", Role: domain.RoleOperator}, sessionTtl)
+	if !assert.NoError(t, err) {
+		return
+	}
+	target := "/_dispatch/method/DELETE/run/" + uuid.New().String()
+
+	for _, tc := range []struct {
+		name, method, token, bearer string
+		status                      int
+	}{
+		// passes authorization, then redirects back to the Run
+		{"valid token", http.MethodPost, self.csrfToken(session), "", http.StatusFound},
+		{"missing token", http.MethodPost, "", "", http.StatusForbidden},
+		{"token of other session", http.MethodPost, self.csrfToken("x" + session), "", http.StatusForbidden},
+		{"dispatch by link", http.MethodGet, self.csrfToken(session), "", http.StatusMethodNotAllowed},
+		{"API token", http.MethodPost, "", "cicero_admin", http.StatusFound},
+	} {
+		form := url.Values{}
+		if tc.token != "" {
+			form.Set(csrfField, tc.token)
+		}
+
+		req := httptest.NewRequest(tc.method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tc.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+		} else {
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.status, w.Code, tc.name)
+	}
+}
+
+func TestShouldIgnoreOversizedTraceparent(t *testing.T) {
+	t.Parallel()
+
+	_, router := buildAuthWeb(t, domain.RoleViewer)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/action", nil)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c0af7-b7ad6b7169203331-01")
+
+	w := httptest.NewRecorder()
+	assert.NotPanics(t, func() { router.ServeHTTP(w, req) })
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// A field of the form to publish a fact for an input.
+type inputFormField struct {
+	Key      string
+	Label    string
+	Path     []string
+	Type     string // any of: text, number, bool, json
+	Value    string
+	Optional bool
+	// Concrete in the schema or match so the value cannot be changed.
+	Fixed bool
+
+	example interface{}
+}
+
+// Generates a field for every leaf of the input's schema and match.
+// Leaves that are neither strings, numbers nor bools are entered as JSON.
+func inputForm(input domain.InputDefinition) []inputFormField {
+	value := input.MatchWithSchema()
+	if value.Err() != nil {
+		return nil
+	}
+
+	fields := []inputFormField{}
+	collectInputFormFields(value, []string{}, false, &fields)
+	return fields
+}
+
+func collectInputFormFields(value cue.Value, path []string, optional bool, fields *[]inputFormField) {
+	if value.IncompleteKind() == cue.StructKind {
+		if iter, err := value.Fields(cue.Optional(true)); err == nil {
+			for iter.Next() {
+				fieldPath := append(path[:len(path):len(path)], iter.Label())
+				collectInputFormFields(iter.Value(), fieldPath, optional || iter.IsOptional(), fields)
+			}
+		}
+		return
+	}
+
+	field := inputFormField{
+		Key:      fmt.Sprintf("field%d", len(*fields)),
+		Label:    strings.Join(path, "."),
+		Path:     path,
+		Optional: optional,
+		Fixed:    value.IsConcrete(),
+		example:  domain.SynthesizeValue(value),
+	}
+
+	switch value.IncompleteKind() {
+	case cue.StringKind:
+		field.Type = "text"
+		field.Value = field.example.(string)
+	case cue.IntKind, cue.FloatKind, cue.NumberKind:
+		field.Type = "number"
+	case cue.BoolKind:
+		field.Type = "bool"
+	default:
+		field.Type = "json"
+	}
+
+	if field.Type != "text" && (field.Fixed || field.Type == "json") {
+		if exampleJson, err := json.Marshal(field.example); err == nil {
+			field.Value = string(exampleJson)
+		}
+	}
+
+	*fields = append(*fields, field)
+}
+
+// Builds a fact from the values submitted with the form.
+// Empty optional fields are left out.
+func parseInputForm(fields []inputFormField, form url.Values) (interface{}, error) {
+	var fact interface{} = map[string]interface{}{}
+
+	for _, field := range fields {
+		var value interface{}
+
+		if field.Fixed {
+			value = field.example
+		} else {
+			str := form.Get(field.Key)
+			if str == "" && field.Optional {
+				continue
+			}
+
+			switch field.Type {
+			case "text":
+				value = str
+			case "number", "bool", "json":
+				if err := json.Unmarshal([]byte(str), &value); err != nil {
+					return nil, fmt.Errorf("Field %q is not valid %s: %w", field.Label, field.Type, err)
+				}
+			}
+
+			switch value.(type) {
+			case float64:
+				if field.Type != "number" && field.Type != "json" {
+					return nil, fmt.Errorf("Field %q is not a %s", field.Label, field.Type)
+				}
+			case bool:
+				if field.Type != "bool" && field.Type != "json" {
+					return nil, fmt.Errorf("Field %q is not a %s", field.Label, field.Type)
+				}
+			default:
+				if field.Type == "number" || field.Type == "bool" {
+					return nil, fmt.Errorf("Field %q is not a %s", field.Label, field.Type)
+				}
+			}
+		}
+
+		if len(field.Path) == 0 {
+			fact = value
+			continue
+		}
+
+		obj, ok := fact.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Field %q is nested in a value that is not an object", field.Label)
+		}
+		for _, key := range field.Path[:len(field.Path)-1] {
+			child, ok := obj[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				obj[key] = child
+			}
+			obj = child
+		}
+		obj[field.Path[len(field.Path)-1]] = value
+	}
+
+	return fact, nil
+}
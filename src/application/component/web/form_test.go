@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldPublishFactWithInputForm(t *testing.T) {
+	t.Parallel()
+
+	var input domain.InputDefinition
+	if !assert.NoError(t, json.Unmarshal([]byte(`{
+		"match": "kind: \"push\"",
+		"schema": "kind: string, ref: string, commits: int, draft?: bool, labels: [...string], repo: name: string"
+	}`), &input)) {
+		return
+	}
+
+	fields := inputForm(input)
+
+	byLabel := map[string]inputFormField{}
+	for _, field := range fields {
+		byLabel[field.Label] = field
+	}
+	assert.Len(t, fields, 6)
+	assert.True(t, byLabel["kind"].Fixed)
+	assert.Equal(t, "text", byLabel["ref"].Type)
+	assert.Equal(t, "number", byLabel["commits"].Type)
+	assert.Equal(t, "bool", byLabel["draft"].Type)
+	assert.True(t, byLabel["draft"].Optional)
+	assert.Equal(t, "json", byLabel["labels"].Type)
+	assert.Equal(t, "text", byLabel["repo.name"].Type)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/action/test", nil)
+	req = req.WithContext(context.WithValue(req.Context(), csrfContextKey{}, "csrf"))
+	assert.NoError(t, render("action/[id].html", w, req, struct {
+		domain.Action
+		PublishedFact string
+	}{domain.Action{
+		ID:               uuid.New(),
+		Name:             "test",
+		ActionDefinition: domain.ActionDefinition{Inputs: map[string]domain.InputDefinition{"push": input}},
+	}, ""}))
+	assert.Contains(t, w.Body.String(), `name="`+byLabel["commits"].Key+`"`)
+	assert.Contains(t, w.Body.String(), `name="csrf_token"`)
+	assert.Contains(t, w.Body.String(), `value="csrf"`)
+
+	form := url.Values{}
+	form.Set(byLabel["kind"].Key, "tampered")
+	form.Set(byLabel["ref"].Key, "main")
+	form.Set(byLabel["commits"].Key, "2")
+	form.Set(byLabel["labels"].Key, `["a"]`)
+	form.Set(byLabel["repo.name"].Key, "cicero")
+
+	value, err := parseInputForm(fields, form)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]interface{}{
+		"kind":    "push",
+		"ref":     "main",
+		"commits": float64(2),
+		"labels":  []interface{}{"a"},
+		"repo":    map[string]interface{}{"name": "cicero"},
+	}, value)
+	assert.NoError(t, domain.FactSchema{Schema: input.Schema}.Check(value))
+
+	form.Set(byLabel["commits"].Key, "two")
+	_, err = parseInputForm(fields, form)
+	assert.Error(t, err)
+
+	form.Set(byLabel["commits"].Key, "2")
+	form.Set(byLabel["draft"].Key, "2")
+	_, err = parseInputForm(fields, form)
+	assert.EqualError(t, err, `Field "draft" is not a bool`)
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldReportHealth(t *testing.T) {
+	t.Parallel()
+
+	self, router := buildAuthWeb(t, domain.RoleViewer)
+	self.HealthChecks = []application.HealthCheck{
+		{
+			Name:     "database",
+			Liveness: true,
+			Check: func(context.Context) (interface{}, error) {
+				return map[string]int{"connections": 1}, nil
+			},
+		},
+		{
+			Name: "loki",
+			Check: func(context.Context) (interface{}, error) {
+				return nil, errors.New("connection refused")
+			},
+		},
+	}
+
+	get := func(path, token string) (int, application.HealthReport) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var report application.HealthReport
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+		return w.Code, report
+	}
+
+	// Loki being down is no reason to restart.
+	code, report := get("/healthz", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.Healthy)
+	assert.Contains(t, report.Components, "database")
+	assert.NotContains(t, report.Components, "loki")
+
+	// Probes only learn what is unhealthy.
+	code, report = get("/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, report.Healthy)
+	assert.Equal(t, application.ComponentHealth{Healthy: true}, report.Components["database"])
+	assert.Equal(t, application.ComponentHealth{Healthy: false}, report.Components["loki"])
+
+	code, report = get("/readyz", "cicero_viewer")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.True(t, report.Components["database"].Healthy)
+	assert.Equal(t, map[string]interface{}{"connections": float64(1)}, report.Components["database"].Detail)
+	assert.False(t, report.Components["loki"].Healthy)
+	assert.Equal(t, "connection refused", report.Components["loki"].Error)
+}
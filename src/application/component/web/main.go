@@ -3,6 +3,8 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"html/template"
 	"io"
 	"net/http"
 	"net/url"
@@ -15,8 +17,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 
+	"github.com/input-output-hk/cicero/src/application"
 	"github.com/input-output-hk/cicero/src/application/component/web/apidoc"
 	"github.com/input-output-hk/cicero/src/application/service"
 	"github.com/input-output-hk/cicero/src/config"
@@ -32,54 +36,144 @@ type Web struct {
 	FactService       service.FactService
 	NomadEventService service.NomadEventService
 	EvaluationService service.EvaluationService
-	Db                config.PgxIface
+	ApiTokenService   service.ApiTokenService
+	AuditService      service.AuditService
+	// Notifications may contain secret webhook URLs so only operators may see them.
+	NotificationService service.NotificationService
+	Authenticator       application.Authenticator
+	// By provider name. Authenticated by their signatures instead of `authorize()`.
+	Webhooks map[string]application.Webhook
+	// Facts published through the API and UI must conform to these.
+	FactSchemas []domain.FactSchema
+	// Signs the cookies of logged in users.
+	SessionKey []byte
+	// Served at `/healthz` and `/readyz`, with details only to viewers.
+	HealthChecks []application.HealthCheck
+	Db           config.PgxIface
+	// Work started by requests is aborted when this is done
+	// instead of when the client goes away. Defaults to never.
+	WorkContext context.Context
+	// How long to wait for requests in flight when shutting down.
+	// Defaults to 5 seconds.
+	ShutdownTimeout time.Duration
 }
 
 func (self *Web) Start(ctx context.Context) error {
 	self.Logger.Info().Msg("Starting")
 
+	muxRouter, err := self.router(ctx)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: self.Listen, Handler: muxRouter}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			self.Logger.Err(err).Msgf("Failed to start web server on %s", self.Listen)
+		}
+	}()
+
+	<-ctx.Done()
+
+	timeout := self.ShutdownTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	// Stop accepting requests and wait for those in flight.
+	self.Logger.Info().Dur("timeout", timeout).Msg("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		self.Logger.Err(err).Msg("Failed to stop web server")
+		if err := server.Close(); err != nil {
+			self.Logger.Err(err).Msg("Failed to close web server")
+		}
+	}
+
+	return nil
+}
+
+// Returns a context for work started by the request
+// that continues its trace but is not aborted if the client goes away.
+func (self *Web) workContext(req *http.Request) context.Context {
+	ctx := self.WorkContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return application.WithTraceparent(ctx, application.Traceparent(req.Context()))
+}
+
+// Records a span for the request that continues the caller's trace, if any.
+func (self *Web) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := req.URL.Path
+		if route := mux.CurrentRoute(req); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				name = template
+			}
+		}
+
+		ctx := req.Context()
+		if application.Traceparent(ctx) == "" {
+			ctx = application.WithTraceparent(ctx, req.Header.Get("traceparent"))
+		}
+
+		ctx, span := application.StartSpan(ctx, req.Method+" "+name, application.SpanKindServer)
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.target", req.URL.RequestURI())
+		defer span.End(nil)
+
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func (self *Web) router(ctx context.Context) (*mux.Router, error) {
 	muxRouter := mux.NewRouter().StrictSlash(true).UseEncodedPath()
 	muxRouter.NotFoundHandler = http.NotFoundHandler()
+	muxRouter.Use(self.trace)
+	muxRouter.Use(self.authenticate)
 
 	r, err := apidoc.NewRouterDocumented(apirouter.NewGorillaMuxRouter(muxRouter), "Cicero REST API", "1.0.0", "cicero", ctx)
 	if err != nil {
-		return errors.WithMessage(err, "Failed to create swagger router")
+		return nil, errors.WithMessage(err, "Failed to create swagger router")
 	}
 
 	// sorted alphabetically, please keep it this way
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action/current/{name}/definition",
-		self.ApiActionCurrentNameDefinitionGet,
+		self.authorize(domain.RoleViewer, self.ApiActionCurrentNameDefinitionGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "name", Description: "name of an action", Value: "actionName"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.ActionDefinition{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action/current/{name}",
-		self.ApiActionCurrentNameGet,
+		self.authorize(domain.RoleViewer, self.ApiActionCurrentNameGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "name", Description: "name of an action", Value: "actionName"}}),
 			apidoc.BuildBodyRequest(domain.Action{}),
 			apidoc.BuildResponseSuccessfully(http.StatusNoContent, nil, "NoContent")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action/current",
-		self.ApiActionCurrentGet,
+		self.authorize(domain.RoleViewer, self.ApiActionCurrentGet),
 		apidoc.BuildSwaggerDef(
 			nil,
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.Action{}, "Ok")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action/definition/{source}/{name}/{id}",
-		self.ApiActionDefinitionSourceNameIdGet,
+		self.authorize(domain.RoleOperator, self.ApiActionDefinitionSourceNameIdGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{
 				{Name: "source", Description: "source of one or more action definitions", Value: "source"},
@@ -89,234 +183,381 @@ func (self *Web) Start(ctx context.Context) error {
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.ActionDefinition{}, "Ok")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action/definition/{source}",
-		self.ApiActionDefinitionSourceGet,
+		self.authorize(domain.RoleOperator, self.ApiActionDefinitionSourceGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "source", Description: "source of one or more action definitions", Value: "source"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, []string{}, "Ok")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action/{id}/definition",
-		self.ApiActionIdDefinitionGet,
+		self.authorize(domain.RoleViewer, self.ApiActionIdDefinitionGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of the action", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.ActionDefinition{}, "Ok")),
 	); err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/action/{id}/plan",
+		self.authorize(domain.RoleViewer, self.ApiActionIdPlanGet),
+		apidoc.BuildSwaggerDef(
+			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of the action", Value: "UUID"}}),
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.ActionPlan{}, "Ok")),
+	); err != nil {
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action/{id}",
-		self.ApiActionIdGet,
+		self.authorize(domain.RoleViewer, self.ApiActionIdGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of the action", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Action{}, "Ok")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodPatch,
 		"/api/action/{id}",
-		self.ApiActionIdPatch,
+		self.authorize(domain.RoleOperator, self.ApiActionIdPatch),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of the action", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Action{}, "Ok")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/action",
-		self.ApiActionGet,
+		self.authorize(domain.RoleViewer, self.ApiActionGet),
 		apidoc.BuildSwaggerDef(
 			nil,
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.Action{}, "Ok")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodPost,
 		"/api/action",
-		self.ApiActionPost,
+		self.authorize(domain.RoleOperator, self.ApiActionPost),
 		apidoc.BuildSwaggerDef(
 			nil,
 			apidoc.BuildBodyRequest(apiActionPostBody{}), //TODO: move to domain
 			apidoc.BuildResponseSuccessfully(http.StatusNoContent, nil, "NoContent")),
 	); err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodPost,
+		"/api/action/validate",
+		self.authorize(domain.RoleOperator, self.ApiActionValidatePost),
+		apidoc.BuildSwaggerDef(
+			nil,
+			apidoc.BuildBodyRequest(apiActionPostBody{}),
+			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.SourceValidation{}, "OK")),
+	); err != nil {
+		return nil, err
 	}
 	var value interface{} //TODO: WIP
 	if _, err := r.AddRoute(http.MethodPost,
 		"/api/run/{id}/fact",
-		self.ApiRunIdFactPost,
+		self.authorizeRun(self.ApiRunIdFactPost),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
 			apidoc.BuildBodyRequest(value),
 			apidoc.BuildResponseSuccessfully(http.StatusNoContent, nil, "NoContent")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/run/{id}/logs",
-		self.ApiRunIdLogsGet,
+		self.authorize(domain.RoleViewer, self.ApiRunIdLogsGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, map[string]*domain.LokiOutput{"logs": {}}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/run/{id}",
-		self.ApiRunIdGet,
+		self.authorize(domain.RoleViewer, self.ApiRunIdGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Run{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/run/{id}/inputs",
-		self.ApiRunIdInputsGet,
+		self.authorize(domain.RoleViewer, self.ApiRunIdInputsGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Run{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/run/{id}/output",
-		self.ApiRunIdOutputGet,
+		self.authorize(domain.RoleViewer, self.ApiRunIdOutputGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Run{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/run/{id}/plan",
+		self.authorize(domain.RoleViewer, self.ApiRunIdPlanGet),
+		apidoc.BuildSwaggerDef(
+			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.RunPlan{}, "OK")),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/run/{id}/definition",
+		self.authorize(domain.RoleViewer, self.ApiRunIdDefinitionGet),
+		apidoc.BuildSwaggerDef(
+			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.RunDefinition{}, "OK")),
+	); err != nil {
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodDelete,
 		"/api/run/{id}",
-		self.ApiRunIdDelete,
+		self.authorize(domain.RoleOperator, self.ApiRunIdDelete),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a run", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusNoContent, domain.Run{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if route, err := r.AddRoute(http.MethodGet,
 		"/api/run",
-		self.ApiRunByInputGet,
+		self.authorize(domain.RoleViewer, self.ApiRunByInputGet),
 		apidoc.BuildSwaggerDef(
 			nil,
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.Run{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	} else {
 		route.(*mux.Route).Queries("input", "")
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/run",
-		self.ApiRunGet,
+		self.authorize(domain.RoleViewer, self.ApiRunGet),
 		apidoc.BuildSwaggerDef(
 			nil,
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.Run{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/fact/schema",
+		self.authorize(domain.RoleViewer, self.ApiFactSchemaGet),
+		apidoc.BuildSwaggerDef(
+			nil,
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.FactSchema{}, "OK")),
+	); err != nil {
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/fact/{id}/binary",
-		self.ApiFactIdBinaryGet,
+		self.authorize(domain.RoleViewer, self.ApiFactIdBinaryGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a fact", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, []byte{}, "OK"),
 		),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := r.AddRoute(http.MethodGet,
 		"/api/fact/{id}",
-		self.ApiFactIdGet,
+		self.authorize(domain.RoleViewer, self.ApiFactIdGet),
 		apidoc.BuildSwaggerDef(
 			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a fact", Value: "UUID"}}),
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Fact{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	}
 	if route, err := r.AddRoute(http.MethodGet,
 		"/api/fact",
-		self.ApiFactByRunGet,
+		self.authorize(domain.RoleViewer, self.ApiFactByRunGet),
 		apidoc.BuildSwaggerDef(
 			nil,
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Fact{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
 	} else {
 		route.(*mux.Route).Queries("run", "")
 	}
 	if _, err := r.AddRoute(http.MethodPost,
 		"/api/fact",
-		self.ApiFactPost,
+		self.authorize(domain.RolePublisher, self.ApiFactPost),
 		apidoc.BuildSwaggerDef(
 			nil,
 			nil,
 			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Fact{}, "OK")),
 	); err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodPost,
+		"/api/webhook/{provider}",
+		self.ApiWebhookProviderPost,
+		apidoc.BuildSwaggerDef(
+			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "provider", Description: "any of: github, gitlab, gitea", Value: "github"}}),
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, domain.Fact{}, "OK")),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/audit",
+		self.authorize(domain.RoleAdmin, self.ApiAuditGet),
+		apidoc.BuildSwaggerDef(
+			nil,
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.AuditEvent{}, "OK")),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/token",
+		self.authorize(domain.RoleAdmin, self.ApiTokenGet),
+		apidoc.BuildSwaggerDef(
+			nil,
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.ApiToken{}, "OK")),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodPost,
+		"/api/token",
+		self.authorize(domain.RoleAdmin, self.ApiTokenPost),
+		apidoc.BuildSwaggerDef(
+			nil,
+			apidoc.BuildBodyRequest(apiTokenPostBody{}),
+			apidoc.BuildResponseSuccessfully(http.StatusOK, apiTokenPostResponse{}, "OK")),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodDelete,
+		"/api/token/{id}",
+		self.authorize(domain.RoleAdmin, self.ApiTokenIdDelete),
+		apidoc.BuildSwaggerDef(
+			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of an API token", Value: "UUID"}}),
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusNoContent, nil, "NoContent")),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/notification",
+		self.authorize(domain.RoleOperator, self.ApiNotificationGet),
+		apidoc.BuildSwaggerDef(
+			nil,
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, []domain.Notification{}, "OK")),
+	); err != nil {
+		return nil, err
 	}
-	muxRouter.HandleFunc("/", self.IndexGet).Methods(http.MethodGet)
-	muxRouter.HandleFunc("/run/{id}", self.RunIdDelete).Methods(http.MethodDelete)
-	muxRouter.HandleFunc("/run/{id}", self.RunIdGet).Methods(http.MethodGet)
-	muxRouter.HandleFunc("/run", self.RunGet).Methods(http.MethodGet)
-	muxRouter.HandleFunc("/action/current", self.ActionCurrentGet).Methods(http.MethodGet)
-	muxRouter.HandleFunc("/action/new", self.ActionNewGet).Methods(http.MethodGet)
-	muxRouter.HandleFunc("/action/{id}", self.ActionIdGet).Methods(http.MethodGet)
-	muxRouter.HandleFunc("/action/{id}", self.ActionIdPatch).Methods(http.MethodPatch)
-	muxRouter.HandleFunc("/action/{id}/run", self.ActionIdRunGet).Methods(http.MethodGet)
-	muxRouter.HandleFunc("/action/{id}/version", self.ActionIdVersionGet).Methods(http.MethodGet)
+	if _, err := r.AddRoute(http.MethodGet,
+		"/api/notification/{id}",
+		self.authorize(domain.RoleOperator, self.ApiNotificationIdGet),
+		apidoc.BuildSwaggerDef(
+			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a notification", Value: "UUID"}}),
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusOK, apiNotificationIdGetResponse{}, "OK")),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := r.AddRoute(http.MethodPost,
+		"/api/notification/{id}/replay",
+		self.authorize(domain.RoleOperator, self.ApiNotificationIdReplayPost),
+		apidoc.BuildSwaggerDef(
+			apidoc.BuildSwaggerPathParams([]apidoc.PathParams{{Name: "id", Description: "id of a notification", Value: "UUID"}}),
+			nil,
+			apidoc.BuildResponseSuccessfully(http.StatusNoContent, nil, "NoContent")),
+	); err != nil {
+		return nil, err
+	}
+	muxRouter.HandleFunc("/login", self.LoginGet).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/login/callback", self.LoginCallbackGet).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/logout", self.LogoutGet).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/healthz", self.HealthzGet).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/readyz", self.ReadyzGet).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/", self.authorize(domain.RoleViewer, self.IndexGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/run/{id}", self.authorize(domain.RoleOperator, self.RunIdDelete)).Methods(http.MethodDelete)
+	muxRouter.HandleFunc("/run/{id}", self.authorize(domain.RoleViewer, self.RunIdGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/run", self.authorize(domain.RoleViewer, self.RunGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/action/current", self.authorize(domain.RoleViewer, self.ActionCurrentGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/action/new", self.authorize(domain.RoleOperator, self.ActionNewGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/action/{id}", self.authorize(domain.RoleViewer, self.ActionIdGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/action/{id}", self.authorize(domain.RoleOperator, self.ActionIdPatch)).Methods(http.MethodPatch)
+	muxRouter.HandleFunc("/action/{id}/run", self.authorize(domain.RoleViewer, self.ActionIdRunGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/action/{id}/fact", self.authorize(domain.RolePublisher, self.ActionIdFactPost)).Methods(http.MethodPost)
+	muxRouter.HandleFunc("/metrics", self.authorize(domain.RoleViewer, promhttp.Handler().ServeHTTP)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/audit", self.authorize(domain.RoleAdmin, self.AuditGet)).Methods(http.MethodGet)
+	muxRouter.HandleFunc("/action/{id}/version", self.authorize(domain.RoleViewer, self.ActionIdVersionGet)).Methods(http.MethodGet)
 	muxRouter.PathPrefix("/static/").Handler(http.StripPrefix("/", http.FileServer(http.FS(staticFs))))
 
+	// Lets HTML forms use methods other than POST.
+	// Only reachable with POST so that links cannot change anything.
 	muxRouter.PathPrefix("/_dispatch/method/{method}/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		req.Method = mux.Vars(req)["method"]
 		http.StripPrefix("/_dispatch/method/"+req.Method, muxRouter).ServeHTTP(w, req)
-	})
+	}).Methods(http.MethodPost)
 
 	// creates /documentation/cicero.json and /documentation/cicero.yaml routes
 	err = r.GenerateAndExposeSwagger()
 	if err != nil {
-		return errors.WithMessage(err, "Failed to generate and expose swagger: %s")
+		return nil, errors.WithMessage(err, "Failed to generate and expose swagger: %s")
 	}
 
-	server := &http.Server{Addr: self.Listen, Handler: muxRouter}
+	return muxRouter, nil
+}
 
-	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			self.Logger.Err(err).Msgf("Failed to start web server on %s", self.Listen)
-		}
-	}()
+// Fails if Cicero should be restarted.
+func (self *Web) HealthzGet(w http.ResponseWriter, req *http.Request) {
+	self.health(w, req, true)
+}
 
-	<-ctx.Done()
+// Fails if Cicero cannot serve, for example because Loki is down.
+func (self *Web) ReadyzGet(w http.ResponseWriter, req *http.Request) {
+	self.health(w, req, false)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		self.Logger.Err(err).Msg("Failed to stop web server")
+func (self *Web) health(w http.ResponseWriter, req *http.Request, liveness bool) {
+	report := application.CheckHealth(req.Context(), self.HealthChecks, liveness)
+	// Probes need no token but should not learn about internals.
+	if identity, ok := identityFromContext(req.Context()); !ok || identity.Run != nil || !identity.Role.Includes(domain.RoleViewer) {
+		report = report.Redacted()
+	}
+	if report.Healthy {
+		self.json(w, report, http.StatusOK)
+	} else {
+		self.json(w, report, http.StatusServiceUnavailable)
 	}
-
-	return nil
 }
 
 func (self *Web) IndexGet(w http.ResponseWriter, req *http.Request) {
@@ -339,7 +580,7 @@ func (self *Web) ActionCurrentGet(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := render("action/current.html", w, map[string]interface{}{
+	if err := render("action/current.html", w, req, map[string]interface{}{
 		"Actions": actions,
 		"active":  active,
 	}); err != nil {
@@ -358,7 +599,7 @@ func (self *Web) ActionIdRunGet(w http.ResponseWriter, req *http.Request) {
 	} else if runs, err := self.RunService.GetByActionId(id, page); err != nil {
 		self.ServerError(w, errors.WithMessagef(err, "Could not get Runs by Action ID: %q", id))
 		return
-	} else if err := render("action/runs.html", w, struct {
+	} else if err := render("action/runs.html", w, req, struct {
 		Runs []*domain.Run
 		*repository.Page
 	}{
@@ -383,7 +624,7 @@ func (self *Web) ActionIdVersionGet(w http.ResponseWriter, req *http.Request) {
 	} else if actions, err := self.ActionService.GetByName(action.Name, page); err != nil {
 		self.ServerError(w, errors.WithMessagef(err, "Could not get Action by name: %q", action.Name))
 		return
-	} else if err := render("action/version.html", w, struct {
+	} else if err := render("action/version.html", w, req, struct {
 		ActionID uuid.UUID
 		Actions  []*domain.Action
 		*repository.Page
@@ -404,12 +645,59 @@ func (self *Web) ActionIdGet(w http.ResponseWriter, req *http.Request) {
 	} else if action, err := self.ActionService.GetById(id); err != nil {
 		self.ServerError(w, errors.WithMessagef(err, "Could not get Action by ID: %q", id))
 		return
-	} else if err := render("action/[id].html", w, action); err != nil {
+	} else if err := render("action/[id].html", w, req, struct {
+		domain.Action
+		// ID of the fact that was just published with an input's form.
+		PublishedFact string
+	}{action, req.URL.Query().Get("fact")}); err != nil {
 		self.ServerError(w, err)
 		return
 	}
 }
 
+func (self *Web) ActionIdFactPost(w http.ResponseWriter, req *http.Request) {
+	id, err := uuid.Parse(mux.Vars(req)["id"])
+	if err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Could not parse Action ID"))
+		return
+	}
+
+	action, err := self.ActionService.GetById(id)
+	if err != nil {
+		self.NotFound(w, errors.WithMessagef(err, "Could not get Action by ID: %q", id))
+		return
+	}
+
+	inputName := req.PostFormValue("input")
+	input, ok := action.Inputs[inputName]
+	if !ok {
+		self.ClientError(w, fmt.Errorf("Action %q has no input %q", action.Name, inputName))
+		return
+	}
+
+	value, err := parseInputForm(inputForm(input), req.PostForm)
+	if err != nil {
+		self.ClientError(w, err)
+		return
+	}
+
+	if err := (domain.FactSchema{Name: inputName, Schema: input.Schema}).Check(value); err != nil {
+		self.ClientError(w, err)
+		return
+	} else if err := self.checkFactSchemas(value); err != nil {
+		self.ClientError(w, err)
+		return
+	}
+
+	fact := domain.Fact{Value: value}
+	if err := self.FactService.WithActor(self.actor(req)).WithContext(self.workContext(req)).Save(&fact, nil); err != nil {
+		self.ServerError(w, errors.WithMessage(err, "Failed to save Fact"))
+		return
+	}
+
+	http.Redirect(w, req, "/action/"+id.String()+"?fact="+fact.ID.String(), http.StatusFound)
+}
+
 func (self *Web) ActionIdPatch(w http.ResponseWriter, req *http.Request) {
 	self.ApiActionIdPatch(NopResponseWriter{w}, req)
 
@@ -429,7 +717,7 @@ func (self *Web) ActionNewGet(w http.ResponseWriter, req *http.Request) {
 
 	// step 1
 	if source == "" {
-		if err := render(templateName, w, nil); err != nil {
+		if err := render(templateName, w, req, nil); err != nil {
 			self.ServerError(w, err)
 		}
 		return
@@ -437,15 +725,15 @@ func (self *Web) ActionNewGet(w http.ResponseWriter, req *http.Request) {
 
 	// step 2
 	if name == "" {
-		if names, err := self.EvaluationService.ListActions(source); err != nil {
+		if names, err := self.EvaluationService.ListActions(source, ""); err != nil {
 			self.ServerError(w, errors.WithMessagef(err, "While listing Actions in %q", source))
-		} else if err := render(templateName, w, map[string]interface{}{"Source": source, "Names": names}); err != nil {
+		} else if err := render(templateName, w, req, map[string]interface{}{"Source": source, "Names": names}); err != nil {
 			self.ServerError(w, err)
 		}
 		return
 	}
 
-	if action, err := self.ActionService.Create(source, name); err != nil {
+	if action, err := self.ActionService.WithActor(self.actor(req)).WithContext(self.workContext(req)).Create(source, name); err != nil {
 		self.ServerError(w, err)
 		return
 	} else {
@@ -519,18 +807,103 @@ func (self *Web) RunIdGet(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := render("run/[id].html", w, map[string]interface{}{
-		"Run":    run,
-		"inputs": inputs,
-		"output": output,
-		"facts":  facts,
-		"allocs": allocs,
+	var plan *domain.RunPlan
+	if p, err := self.RunService.GetPlanByNomadJobId(id); err != nil {
+		if !pgxscan.NotFound(err) {
+			self.ServerError(w, err)
+			return
+		}
+	} else {
+		plan = &p
+	}
+
+	var definition *domain.RunDefinition
+	if d, err := self.RunService.GetDefinitionByNomadJobId(id); err != nil {
+		if !pgxscan.NotFound(err) {
+			self.ServerError(w, err)
+			return
+		}
+	} else {
+		definition = &d
+	}
+
+	if err := render("run/[id].html", w, req, map[string]interface{}{
+		"Run":        run,
+		"inputs":     inputs,
+		"output":     output,
+		"facts":      facts,
+		"allocs":     allocs,
+		"plan":       plan,
+		"definition": definition,
 	}); err != nil {
 		self.ServerError(w, err)
 		return
 	}
 }
 
+func (self *Web) AuditGet(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	query.Del("offset")
+	query.Del("limit")
+
+	if filter, err := getAuditEventFilter(req); err != nil {
+		self.BadRequest(w, err)
+	} else if page, err := getPage(req); err != nil {
+		self.BadRequest(w, err)
+	} else if events, err := self.AuditService.Get(filter, page); err != nil {
+		self.ServerError(w, err)
+	} else if err := render("audit/index.html", w, req, struct {
+		Events []*domain.AuditEvent
+		Types  []domain.AuditEventType
+		Filter repository.AuditEventFilter
+		Since  string
+		Until  string
+		Query  template.URL
+		*repository.Page
+	}{
+		Events: events,
+		Types:  domain.AuditEventTypes,
+		Filter: filter,
+		Since:  query.Get("since"),
+		Until:  query.Get("until"),
+		Query:  template.URL(query.Encode()),
+		Page:   page,
+	}); err != nil {
+		self.ServerError(w, err)
+	}
+}
+
+func getAuditEventFilter(req *http.Request) (filter repository.AuditEventFilter, err error) {
+	query := req.URL.Query()
+
+	filter.Type = domain.AuditEventType(query.Get("type"))
+	filter.Actor = query.Get("actor")
+
+	if str := query.Get("subject"); str != "" {
+		if id, err := uuid.Parse(str); err != nil {
+			return filter, errors.WithMessage(err, "subject parameter is invalid, should be a UUID")
+		} else {
+			filter.SubjectId = &id
+		}
+	}
+
+	for name, dst := range map[string]**time.Time{
+		"since": &filter.Since,
+		"until": &filter.Until,
+	} {
+		if str := query.Get(name); str != "" {
+			if t, err := time.Parse(time.RFC3339, str); err != nil {
+				return filter, errors.WithMessagef(err, "%s parameter is invalid, should be an RFC 3339 timestamp", name)
+			} else {
+				t = t.UTC()
+				*dst = &t
+			}
+		}
+	}
+
+	return
+}
+
 func getPage(req *http.Request) (*repository.Page, error) {
 	page := repository.Page{}
 
@@ -579,7 +952,7 @@ func (self *Web) RunGet(w http.ResponseWriter, req *http.Request) {
 			}
 		}
 
-		if err := render("run/index.html", w, struct {
+		if err := render("run/index.html", w, req, struct {
 			Runs []RunWrapper
 			*repository.Page
 		}{
@@ -609,8 +982,8 @@ func (self *Web) ApiActionDefinitionSourceGet(w http.ResponseWriter, req *http.R
 		return
 	}
 
-	if wfs, err := self.EvaluationService.ListActions(source); err != nil {
-		self.ClientError(w, errors.WithMessage(err, "Failed to list actions"))
+	if wfs, err := self.EvaluationService.ListActions(source, ""); err != nil {
+		self.EvaluationError(w, errors.WithMessage(err, "Failed to list actions"), http.StatusPreconditionFailed)
 		return
 	} else {
 		self.json(w, wfs, http.StatusOK)
@@ -627,8 +1000,8 @@ func (self *Web) ApiActionDefinitionSourceNameIdGet(w http.ResponseWriter, req *
 		self.ClientError(w, errors.WithMessagef(err, "Invalid escaping of action ID: %q", vars["id"]))
 	} else if id, err := uuid.Parse(idStr); err != nil {
 		self.ClientError(w, errors.WithMessagef(err, "Invalid UUID given as action ID: %q", idStr))
-	} else if def, err := self.EvaluationService.EvaluateAction(source, name, id); err != nil {
-		self.ServerError(w, err)
+	} else if def, err := self.EvaluationService.EvaluateAction(source, "", name, id); err != nil {
+		self.EvaluationError(w, err, http.StatusInternalServerError)
 	} else {
 		self.json(w, def, http.StatusOK)
 	}
@@ -679,21 +1052,21 @@ func (self *Web) ApiActionPost(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if params.Name != nil {
-		if action, err := self.ActionService.Create(params.Source, *params.Name); err != nil {
-			self.ClientError(w, err) //TODO: checking
+		if action, err := self.ActionService.WithActor(self.actor(req)).WithContext(self.workContext(req)).Create(params.Source, *params.Name); err != nil {
+			self.EvaluationError(w, err, http.StatusPreconditionFailed) //TODO: checking
 			return
 		} else {
 			self.json(w, action, http.StatusOK)
 		}
 	} else {
-		if actionNames, err := self.EvaluationService.ListActions(params.Source); err != nil {
-			self.ClientError(w, errors.WithMessage(err, "Failed to list actions")) //TODO: checking
+		if actionNames, err := self.EvaluationService.ListActions(params.Source, ""); err != nil {
+			self.EvaluationError(w, errors.WithMessage(err, "Failed to list actions"), http.StatusPreconditionFailed) //TODO: checking
 			return
 		} else {
 			actions := make([]*domain.Action, len(actionNames))
 			for i, actionName := range actionNames {
-				if action, err := self.ActionService.Create(params.Source, actionName); err != nil {
-					self.ClientError(w, err) //TODO: checking
+				if action, err := self.ActionService.WithActor(self.actor(req)).WithContext(self.workContext(req)).Create(params.Source, actionName); err != nil {
+					self.EvaluationError(w, err, http.StatusPreconditionFailed) //TODO: checking
 					return
 				} else {
 					actions[i] = action
@@ -704,6 +1077,25 @@ func (self *Web) ApiActionPost(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func (self *Web) ApiActionValidatePost(w http.ResponseWriter, req *http.Request) {
+	params := apiActionPostBody{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Could not unmarshal params from request body"))
+		return
+	}
+
+	name := ""
+	if params.Name != nil {
+		name = *params.Name
+	}
+
+	if validation, err := self.ActionService.WithContext(self.workContext(req)).Validate(params.Source, name); err != nil {
+		self.EvaluationError(w, errors.WithMessage(err, "Failed to list actions"), http.StatusPreconditionFailed)
+	} else {
+		self.json(w, validation, http.StatusOK)
+	}
+}
+
 func (self *Web) getRun(req *http.Request) (domain.Run, error) {
 	if id, err := uuid.Parse(mux.Vars(req)["id"]); err != nil {
 		return domain.Run{}, err
@@ -748,11 +1140,39 @@ func (self *Web) ApiRunIdOutputGet(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func (self *Web) ApiRunIdPlanGet(w http.ResponseWriter, req *http.Request) {
+	if id, err := uuid.Parse(mux.Vars(req)["id"]); err != nil {
+		self.ClientError(w, err)
+	} else if plan, err := self.RunService.GetPlanByNomadJobId(id); err != nil {
+		if pgxscan.NotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			self.ServerError(w, err)
+		}
+	} else {
+		self.json(w, plan, http.StatusOK)
+	}
+}
+
+func (self *Web) ApiRunIdDefinitionGet(w http.ResponseWriter, req *http.Request) {
+	if id, err := uuid.Parse(mux.Vars(req)["id"]); err != nil {
+		self.ClientError(w, err)
+	} else if def, err := self.RunService.GetDefinitionByNomadJobId(id); err != nil {
+		if pgxscan.NotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			self.ServerError(w, err)
+		}
+	} else {
+		self.json(w, def, http.StatusOK)
+	}
+}
+
 func (self *Web) ApiRunIdDelete(w http.ResponseWriter, req *http.Request) {
 	if run, err := self.getRun(req); err != nil {
 		self.NotFound(w, err)
 		return
-	} else if err := self.RunService.Cancel(&run); err != nil {
+	} else if err := self.ActionService.WithActor(self.actor(req)).WithContext(self.workContext(req)).CancelRun(&run); err != nil {
 		self.ServerError(w, errors.WithMessagef(err, "Failed to cancel Run %q", run.NomadJobID))
 		return
 	}
@@ -781,7 +1201,7 @@ func (self *Web) ApiRunIdFactPost(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := self.FactService.Save(&fact, io.MultiReader(factDecoder.Buffered(), req.Body)); err != nil {
+	if err := self.FactService.WithContext(self.workContext(req)).Save(&fact, io.MultiReader(factDecoder.Buffered(), req.Body)); err != nil {
 		self.ServerError(w, err)
 		return
 	}
@@ -833,8 +1253,8 @@ func (self *Web) ApiActionCurrentNameDefinitionGet(w http.ResponseWriter, req *h
 		self.ClientError(w, errors.WithMessagef(err, "Invalid escaping of action name: %q", vars["name"]))
 	} else if action, err := self.ActionService.GetLatestByName(name); err != nil {
 		self.ClientError(w, errors.WithMessage(err, "Failed to get action"))
-	} else if actionDef, err := self.EvaluationService.EvaluateAction(action.Source, action.Name, action.ID); err != nil {
-		self.ServerError(w, errors.WithMessage(err, "Failed to evaluate action"))
+	} else if actionDef, err := self.EvaluationService.EvaluateAction(action.Source, action.Revision, action.Name, action.ID); err != nil {
+		self.EvaluationError(w, errors.WithMessage(err, "Failed to evaluate action"), http.StatusInternalServerError)
 	} else {
 		self.json(w, actionDef, http.StatusOK)
 	}
@@ -863,7 +1283,7 @@ func (self *Web) ApiActionIdPatch(w http.ResponseWriter, req *http.Request) {
 			action.Active = active
 		}
 
-		if err := self.ActionService.Update(&action); err != nil {
+		if err := self.ActionService.WithActor(self.actor(req)).Update(&action); err != nil {
 			self.ServerError(w, err)
 			return
 		}
@@ -878,13 +1298,26 @@ func (self *Web) ApiActionIdDefinitionGet(w http.ResponseWriter, req *http.Reque
 		self.ClientError(w, errors.WithMessage(err, "Failed to parse id"))
 	} else if action, err := self.ActionService.GetById(id); err != nil {
 		self.ServerError(w, errors.WithMessage(err, "Failed to get action"))
-	} else if actionDef, err := self.EvaluationService.EvaluateAction(action.Source, action.Name, action.ID); err != nil {
-		self.ServerError(w, errors.WithMessage(err, "Failed to evaluate action"))
+	} else if actionDef, err := self.EvaluationService.EvaluateAction(action.Source, action.Revision, action.Name, action.ID); err != nil {
+		self.EvaluationError(w, errors.WithMessage(err, "Failed to evaluate action"), http.StatusInternalServerError)
 	} else {
 		self.json(w, actionDef, http.StatusOK)
 	}
 }
 
+func (self *Web) ApiActionIdPlanGet(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	if id, err := uuid.Parse(vars["id"]); err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Failed to parse id"))
+	} else if action, err := self.ActionService.GetById(id); err != nil {
+		self.NotFound(w, errors.WithMessage(err, "Failed to get action"))
+	} else if plan, err := self.ActionService.WithContext(self.workContext(req)).Plan(&action); err != nil {
+		self.EvaluationError(w, errors.WithMessage(err, "Failed to plan action"), http.StatusInternalServerError)
+	} else {
+		self.json(w, plan, http.StatusOK)
+	}
+}
+
 func (self *Web) ApiRunIdLogsGet(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	if id, err := uuid.Parse(vars["id"]); err != nil {
@@ -952,10 +1385,181 @@ func (self *Web) ApiFactPost(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := self.FactService.Save(&fact, io.MultiReader(factDecoder.Buffered(), req.Body)); err != nil {
+	if err := self.checkFactSchemas(fact.Value); err != nil {
+		self.ClientError(w, err)
+		return
+	}
+
+	if err := self.FactService.WithActor(self.actor(req)).WithContext(self.workContext(req)).Save(&fact, io.MultiReader(factDecoder.Buffered(), req.Body)); err != nil {
 		self.ServerError(w, errors.WithMessage(err, "Failed to save Fact"))
 		return
 	}
 
 	self.json(w, fact, http.StatusOK)
 }
+
+func (self *Web) ApiFactSchemaGet(w http.ResponseWriter, req *http.Request) {
+	schemas := self.FactSchemas
+	if schemas == nil {
+		schemas = []domain.FactSchema{}
+	}
+	self.json(w, schemas, http.StatusOK)
+}
+
+func (self *Web) checkFactSchemas(value interface{}) error {
+	for _, schema := range self.FactSchemas {
+		if err := schema.Check(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliveries larger than this are rejected.
+const webhookMaxBody = 25 << 20
+
+func (self *Web) ApiWebhookProviderPost(w http.ResponseWriter, req *http.Request) {
+	provider := mux.Vars(req)["provider"]
+	webhook, ok := self.Webhooks[provider]
+	if !ok {
+		self.NotFound(w, fmt.Errorf("No webhook configured for %q", provider))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, webhookMaxBody))
+	if err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Could not read body"))
+		return
+	}
+
+	value, err := webhook.Handle(req.Header, body)
+	if errors.Is(err, application.ErrWebhookSignature) {
+		self.Error(w, err, http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		self.ClientError(w, err)
+		return
+	} else if value == nil {
+		// The mapping ignores this event.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	actor := self.actor(withIdentity(req, domain.Identity{Name: "webhook:" + provider, Role: domain.RolePublisher}))
+
+	fact := domain.Fact{Value: value}
+	if err := self.FactService.WithActor(actor).WithContext(self.workContext(req)).Save(&fact, nil); err != nil {
+		self.ServerError(w, errors.WithMessage(err, "Failed to save Fact"))
+		return
+	}
+
+	self.json(w, fact, http.StatusOK)
+}
+
+func (self *Web) ApiTokenGet(w http.ResponseWriter, req *http.Request) {
+	if tokens, err := self.ApiTokenService.GetAll(); err != nil {
+		self.ServerError(w, err)
+	} else {
+		self.json(w, tokens, http.StatusOK)
+	}
+}
+
+type apiTokenPostBody struct {
+	Name string      `json:"name"`
+	Role domain.Role `json:"role"`
+}
+
+type apiTokenPostResponse struct {
+	domain.ApiToken
+	// The secret to pass in the Authorization header. Cannot be retrieved again.
+	Token string `json:"token"`
+}
+
+func (self *Web) ApiTokenPost(w http.ResponseWriter, req *http.Request) {
+	params := apiTokenPostBody{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Could not unmarshal params from request body"))
+		return
+	} else if params.Name == "" {
+		self.ClientError(w, errors.New("API token name must not be empty"))
+		return
+	}
+
+	if token, str, err := self.ApiTokenService.Create(params.Name, params.Role); err != nil {
+		self.ServerError(w, err)
+	} else {
+		self.json(w, apiTokenPostResponse{ApiToken: token, Token: str}, http.StatusOK)
+	}
+}
+
+func (self *Web) ApiTokenIdDelete(w http.ResponseWriter, req *http.Request) {
+	if id, err := uuid.Parse(mux.Vars(req)["id"]); err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Could not parse API token ID"))
+	} else if err := self.ApiTokenService.Delete(id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			self.NotFound(w, err)
+		} else {
+			self.ServerError(w, err)
+		}
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (self *Web) ApiAuditGet(w http.ResponseWriter, req *http.Request) {
+	if filter, err := getAuditEventFilter(req); err != nil {
+		self.BadRequest(w, err)
+	} else if page, err := getPage(req); err != nil {
+		self.BadRequest(w, err)
+	} else if events, err := self.AuditService.Get(filter, page); err != nil {
+		self.ServerError(w, err)
+	} else {
+		self.json(w, events, http.StatusOK)
+	}
+}
+
+func (self *Web) ApiNotificationGet(w http.ResponseWriter, req *http.Request) {
+	state := domain.NotificationState(req.URL.Query().Get("state"))
+	if page, err := getPage(req); err != nil {
+		self.BadRequest(w, err)
+	} else if notifications, err := self.NotificationService.Get(state, page); err != nil {
+		self.ServerError(w, err)
+	} else {
+		self.json(w, notifications, http.StatusOK)
+	}
+}
+
+type apiNotificationIdGetResponse struct {
+	domain.Notification
+	Attempts []*domain.NotificationAttempt `json:"attempts"`
+}
+
+func (self *Web) ApiNotificationIdGet(w http.ResponseWriter, req *http.Request) {
+	if id, err := uuid.Parse(mux.Vars(req)["id"]); err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Could not parse Notification ID"))
+	} else if notification, err := self.NotificationService.GetById(id); err != nil {
+		if pgxscan.NotFound(err) {
+			self.NotFound(w, err)
+		} else {
+			self.ServerError(w, err)
+		}
+	} else if attempts, err := self.NotificationService.GetAttempts(id); err != nil {
+		self.ServerError(w, err)
+	} else {
+		self.json(w, apiNotificationIdGetResponse{notification, attempts}, http.StatusOK)
+	}
+}
+
+func (self *Web) ApiNotificationIdReplayPost(w http.ResponseWriter, req *http.Request) {
+	if id, err := uuid.Parse(mux.Vars(req)["id"]); err != nil {
+		self.ClientError(w, errors.WithMessage(err, "Could not parse Notification ID"))
+	} else if err := self.NotificationService.WithActor(self.actor(req)).Replay(id); err != nil {
+		if pgxscan.NotFound(err) {
+			self.NotFound(w, err)
+		} else {
+			self.ServerError(w, err)
+		}
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldServeMetrics(t *testing.T) {
+	t.Parallel()
+
+	_, router := buildAuthWeb(t, domain.RoleViewer)
+
+	application.MetricFactsPublished.WithLabelValues("external").Inc()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer cicero_viewer")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `cicero_facts_published_total{source="external"}`)
+}
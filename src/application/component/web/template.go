@@ -10,6 +10,9 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/input-output-hk/cicero/src/application/service"
 	"github.com/input-output-hk/cicero/src/domain"
 )
 
@@ -40,6 +43,29 @@ func (self *Web) Error(w http.ResponseWriter, err error, status int) {
 	http.Error(w, err.Error(), status)
 }
 
+// Responds with the details of an EvaluationError in JSON if there is one,
+// otherwise like Error with the given status.
+// Retryable evaluation errors are temporary failures of the evaluator.
+func (self *Web) EvaluationError(w http.ResponseWriter, err error, status int) {
+	var evalErr service.EvaluationError
+	if !errors.As(err, &evalErr) {
+		self.Error(w, err, status)
+		return
+	}
+
+	self.Logger.Err(err).Msg("Handler error")
+
+	status = http.StatusPreconditionFailed
+	if evalErr.Retryable {
+		status = http.StatusServiceUnavailable
+	}
+
+	self.json(w, map[string]interface{}{
+		"error":      err.Error(),
+		"evaluation": evalErr,
+	}, status)
+}
+
 func (self *Web) json(w http.ResponseWriter, obj interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -77,13 +103,23 @@ func loadTemplate(route string) (*template.Template, error) {
 	return parsed, nil
 }
 
-func render(route string, w http.ResponseWriter, data interface{}) error {
+func render(route string, w http.ResponseWriter, req *http.Request, data interface{}) error {
 	tmpl, err := loadTemplate(route)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
 	}
 
+	// Cached templates are never executed so that they can be cloned
+	// to give them the functions that depend on the request.
+	if tmpl, err = tmpl.Clone(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"csrfToken": func() string { return csrfTokenFromContext(req.Context()) },
+	})
+
 	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return err
@@ -93,6 +129,8 @@ func render(route string, w http.ResponseWriter, data interface{}) error {
 }
 
 var templateFuncs = template.FuncMap{
+	// Replaced when rendering, see render.
+	"csrfToken": func() string { return "" },
 	"buildInfo": func() domain.BuildInfo {
 		return domain.Build
 	},
@@ -115,4 +153,5 @@ var templateFuncs = template.FuncMap{
 	"InputDefinitionSelectString": func(inputDefinitionSelect domain.InputDefinitionSelect) (string, error) {
 		return inputDefinitionSelect.String()
 	},
+	"inputForm": inputForm,
 }
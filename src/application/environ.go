@@ -0,0 +1,22 @@
+package application
+
+import (
+	"os"
+	"strings"
+)
+
+// Returns the variables of Cicero's environment that are allowed.
+// A trailing * in an allowed name matches any suffix.
+func AllowedEnviron(allowed []string) []string {
+	env := []string{}
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		for _, allow := range allowed {
+			if name == allow || (strings.HasSuffix(allow, "*") && strings.HasPrefix(name, strings.TrimSuffix(allow, "*"))) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
@@ -0,0 +1,91 @@
+package application
+
+import (
+	"context"
+	"strings"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Runs the jobs of Runs.
+// Progress is reported as Nomad allocation events
+// regardless of which backend actually runs the jobs.
+type Executor interface {
+	// Checks whether the job can be run and how it would be scheduled.
+	// Returns an InvalidJobError if the job is rejected.
+	Plan(*nomad.Job) (*domain.RunPlan, error)
+	Submit(context.Context, *nomad.Job) error
+	Cancel(jobId string) error
+	EventStream(ctx context.Context, index uint64) (<-chan *nomad.Events, error)
+}
+
+// The job was rejected by the Executor.
+type InvalidJobError struct {
+	Errors []string
+}
+
+func (e InvalidJobError) Error() string {
+	return "Job is invalid: " + strings.Join(e.Errors, "; ")
+}
+
+type nomadExecutor struct {
+	logger      zerolog.Logger
+	nomadClient NomadClient
+}
+
+func NewNomadExecutor(nomadClient NomadClient, logger *zerolog.Logger) Executor {
+	return &nomadExecutor{
+		logger:      logger.With().Str("component", "NomadExecutor").Logger(),
+		nomadClient: nomadClient,
+	}
+}
+
+func (self *nomadExecutor) Plan(job *nomad.Job) (*domain.RunPlan, error) {
+	if response, _, err := self.nomadClient.JobsValidate(job, &nomad.WriteOptions{}); err != nil {
+		return nil, errors.WithMessage(err, "Failed to validate Nomad job")
+	} else if len(response.ValidationErrors) > 0 {
+		return nil, InvalidJobError{Errors: response.ValidationErrors}
+	} else if response.Error != "" {
+		return nil, InvalidJobError{Errors: []string{response.Error}}
+	}
+
+	if response, _, err := self.nomadClient.JobsPlan(job, true, &nomad.WriteOptions{}); err != nil {
+		return nil, errors.WithMessage(err, "Failed to plan Nomad job")
+	} else {
+		return &domain.RunPlan{
+			Diff:           response.Diff,
+			FailedTGAllocs: response.FailedTGAllocs,
+			Warnings:       response.Warnings,
+		}, nil
+	}
+}
+
+func (self *nomadExecutor) Submit(ctx context.Context, job *nomad.Job) (err error) {
+	_, span := StartSpan(ctx, "JobsRegister", SpanKindClient)
+	span.SetAttribute("nomad.job.id", *job.ID)
+	defer func() { span.End(err) }()
+
+	if response, _, err := self.nomadClient.JobsRegister(job, &nomad.WriteOptions{}); err != nil {
+		return errors.WithMessage(err, "Failed to register Nomad job")
+	} else if len(response.Warnings) > 0 {
+		self.logger.Warn().
+			Str("nomad-job", *job.ID).
+			Str("nomad-evaluation", response.EvalID).
+			Str("warnings", response.Warnings).
+			Msg("Warnings occured registering Nomad job")
+	}
+	return nil
+}
+
+func (self *nomadExecutor) Cancel(jobId string) error {
+	_, _, err := self.nomadClient.JobsDeregister(jobId, false, &nomad.WriteOptions{})
+	return errors.WithMessagef(err, "Failed to deregister Nomad job %q", jobId)
+}
+
+func (self *nomadExecutor) EventStream(ctx context.Context, index uint64) (<-chan *nomad.Events, error) {
+	return self.nomadClient.EventStream(ctx, index)
+}
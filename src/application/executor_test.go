@@ -0,0 +1,44 @@
+package application_test
+
+import (
+	"testing"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/application/mocks"
+)
+
+func TestShouldReportNomadValidationError(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	nomadClient := mocks.NewNomadClient()
+	executor := application.NewNomadExecutor(nomadClient, &logger)
+
+	id := "invalid"
+	job := &nomad.Job{ID: &id}
+
+	// given
+	nomadClient.ValidateResponse = &nomad.JobValidateResponse{Error: "Missing job datacenters"}
+
+	// when
+	_, err := executor.Plan(job)
+
+	// then
+	assert.Equal(t, application.InvalidJobError{Errors: []string{"Missing job datacenters"}}, err)
+	assert.EqualError(t, err, "Job is invalid: Missing job datacenters")
+
+	// given
+	nomadClient.ValidateResponse = &nomad.JobValidateResponse{
+		Error:            "2 errors occurred",
+		ValidationErrors: []string{"Missing job datacenters", "Missing task groups"},
+	}
+
+	// when
+	_, err = executor.Plan(job)
+
+	// then
+	assert.EqualError(t, err, "Job is invalid: Missing job datacenters; Missing task groups")
+}
@@ -0,0 +1,152 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// How long a single health check may take.
+const healthCheckTimeout = 5 * time.Second
+
+// Checks one component, served at `/healthz` and `/readyz`.
+type HealthCheck struct {
+	Name string
+	// Whether Cicero should be restarted if this fails.
+	// Otherwise it is only not ready to serve.
+	Liveness bool
+	// Returns details about the component even if it fails.
+	Check func(context.Context) (detail interface{}, err error)
+}
+
+type ComponentHealth struct {
+	Healthy bool        `json:"healthy"`
+	Error   string      `json:"error,omitempty"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+type HealthReport struct {
+	Healthy    bool                       `json:"healthy"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// Leaves out errors and details, which may reveal internals,
+// so that only whether each component is healthy remains.
+func (self HealthReport) Redacted() HealthReport {
+	redacted := HealthReport{Healthy: self.Healthy, Components: make(map[string]ComponentHealth, len(self.Components))}
+	for name, health := range self.Components {
+		redacted.Components[name] = ComponentHealth{Healthy: health.Healthy}
+	}
+	return redacted
+}
+
+// Runs the checks concurrently. If liveness is true
+// only those are run that would require a restart to fix.
+func CheckHealth(ctx context.Context, checks []HealthCheck, liveness bool) HealthReport {
+	report := HealthReport{Healthy: true, Components: map[string]ComponentHealth{}}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		if liveness && !check.Liveness {
+			continue
+		}
+
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			detail, err := check.Check(ctx)
+			health := ComponentHealth{Healthy: err == nil, Detail: detail}
+			if err != nil {
+				health.Error = err.Error()
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Components[check.Name] = health
+			report.Healthy = report.Healthy && health.Healthy
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// Restarting does not help while the database is down.
+func NewDbHealthCheck(pool *pgxpool.Pool) HealthCheck {
+	return HealthCheck{
+		Name: "database",
+		Check: func(ctx context.Context) (interface{}, error) {
+			stat := pool.Stat()
+			detail := map[string]int32{
+				"acquired-connections": stat.AcquiredConns(),
+				"idle-connections":     stat.IdleConns(),
+				"connections":          stat.TotalConns(),
+				"max-connections":      stat.MaxConns(),
+			}
+			return detail, pool.Ping(ctx)
+		},
+	}
+}
+
+// Loki serves the logs of Runs.
+func NewLokiHealthCheck(client *http.Client, addr string) HealthCheck {
+	url := strings.TrimSuffix(addr, "/") + "/ready"
+	return HealthCheck{
+		Name: "loki",
+		Check: func(ctx context.Context) (interface{}, error) {
+			detail := map[string]string{"url": url}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return detail, err
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				return detail, err
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				return detail, fmt.Errorf("%s responded with %s", url, res.Status)
+			}
+			return detail, nil
+		},
+	}
+}
+
+// Looks up the binaries of the evaluators on `PATH`.
+func NewEvaluatorsHealthCheck(evaluators []string) HealthCheck {
+	return HealthCheck{
+		Name: "evaluators",
+		Check: func(context.Context) (interface{}, error) {
+			detail := map[string]string{}
+			missing := []string{}
+			for _, evaluator := range evaluators {
+				name := "cicero-evaluator-" + evaluator
+				if path, err := exec.LookPath(name); err != nil {
+					missing = append(missing, name)
+					detail[evaluator] = ""
+				} else {
+					detail[evaluator] = path
+				}
+			}
+
+			if len(missing) > 0 {
+				return detail, fmt.Errorf("Not found on PATH: %s", strings.Join(missing, ", "))
+			}
+			return detail, nil
+		},
+	}
+}
@@ -0,0 +1,380 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Runs `exec` and `raw_exec` tasks as child processes
+// so that Cicero can be used without a Nomad cluster.
+// There is no isolation between tasks or from Cicero itself
+// except that tasks only get the allowed variables of its environment.
+type localExecutor struct {
+	logger zerolog.Logger
+
+	// Where task directories are created.
+	dir string
+
+	// Names of the environment variables that tasks get from Cicero's environment.
+	// A trailing * matches any suffix.
+	env []string
+
+	mutex sync.Mutex
+	index uint64
+	jobs  map[string]context.CancelFunc
+	// The most recent events so that streams can resume
+	// from an index, like after the consumer restarted.
+	backlog []*nomad.Events
+	// Signaled when events are added to the backlog.
+	subscribers map[chan struct{}]struct{}
+}
+
+// How many events are kept for streams to resume from.
+const localExecutorBacklog = 1024
+
+func NewLocalExecutor(dir string, env []string, logger *zerolog.Logger) Executor {
+	return &localExecutor{
+		logger:      logger.With().Str("component", "LocalExecutor").Logger(),
+		dir:         dir,
+		env:         env,
+		jobs:        map[string]context.CancelFunc{},
+		subscribers: map[chan struct{}]struct{}{},
+	}
+}
+
+func (self *localExecutor) Plan(job *nomad.Job) (*domain.RunPlan, error) {
+	var problems []string
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			switch task.Driver {
+			case "exec", "raw_exec":
+				if command, ok := task.Config["command"].(string); !ok || command == "" {
+					problems = append(problems, fmt.Sprintf("Task %q has no command", task.Name))
+				}
+			default:
+				problems = append(problems, fmt.Sprintf("Task %q uses unsupported driver %q", task.Name, task.Driver))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, InvalidJobError{Errors: problems}
+	}
+
+	return &domain.RunPlan{}, nil
+}
+
+func (self *localExecutor) Submit(_ context.Context, job *nomad.Job) error {
+	if _, err := self.Plan(job); err != nil {
+		return err
+	}
+
+	jobId := *job.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	self.mutex.Lock()
+	if _, exists := self.jobs[jobId]; exists {
+		self.mutex.Unlock()
+		cancel()
+		return fmt.Errorf("Job %q is already running", jobId)
+	}
+	self.jobs[jobId] = cancel
+	self.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			self.mutex.Lock()
+			delete(self.jobs, jobId)
+			self.mutex.Unlock()
+			cancel()
+		}()
+
+		var wg sync.WaitGroup
+		for _, group := range job.TaskGroups {
+			count := 1
+			if group.Count != nil {
+				count = *group.Count
+			}
+
+			for i := 0; i < count; i++ {
+				wg.Add(1)
+				go func(group *nomad.TaskGroup, i int) {
+					defer wg.Done()
+					self.runAlloc(ctx, job, group, i)
+				}(group, i)
+			}
+		}
+		wg.Wait()
+	}()
+
+	return nil
+}
+
+func (self *localExecutor) runAlloc(ctx context.Context, job *nomad.Job, group *nomad.TaskGroup, i int) {
+	now := time.Now()
+	alloc := &nomad.Allocation{
+		ID:                 uuid.New().String(),
+		Name:               fmt.Sprintf("%s.%s[%d]", *job.ID, *group.Name, i),
+		NodeName:           "local",
+		JobID:              *job.ID,
+		Job:                job,
+		TaskGroup:          *group.Name,
+		ClientStatus:       nomad.AllocClientStatusRunning,
+		TaskStates:         map[string]*nomad.TaskState{},
+		AllocatedResources: &nomad.AllocatedResources{Tasks: map[string]*nomad.AllocatedTaskResources{}},
+		CreateTime:         now.UnixNano(),
+		ModifyTime:         now.UnixNano(),
+	}
+
+	logger := self.logger.With().
+		Str("nomad-job", alloc.JobID).
+		Str("alloc", alloc.ID).
+		Logger()
+
+	allocDir := filepath.Join(self.dir, alloc.JobID, alloc.ID)
+
+	for _, task := range group.Tasks {
+		alloc.TaskStates[task.Name] = &nomad.TaskState{
+			State:     "running",
+			StartedAt: now,
+		}
+	}
+
+	self.publish(alloc)
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, task := range group.Tasks {
+		wg.Add(1)
+		go func(task *nomad.Task) {
+			defer wg.Done()
+
+			taskState, err := self.runTask(ctx, alloc, allocDir, task)
+			if err != nil {
+				logger.Err(err).Str("task", task.Name).Msg("Could not run task")
+			}
+
+			mutex.Lock()
+			alloc.TaskStates[task.Name] = taskState
+			mutex.Unlock()
+		}(task)
+	}
+
+	wg.Wait()
+
+	alloc.ClientStatus = nomad.AllocClientStatusComplete
+	for _, state := range alloc.TaskStates {
+		if state.Failed {
+			alloc.ClientStatus = nomad.AllocClientStatusFailed
+		}
+	}
+	alloc.ModifyTime = time.Now().UnixNano()
+
+	self.publish(alloc)
+}
+
+func (self *localExecutor) runTask(ctx context.Context, alloc *nomad.Allocation, allocDir string, task *nomad.Task) (*nomad.TaskState, error) {
+	state := &nomad.TaskState{
+		State:     "dead",
+		StartedAt: time.Now(),
+	}
+	defer func() { state.FinishedAt = time.Now() }()
+
+	taskDir := filepath.Join(allocDir, task.Name)
+	if err := os.MkdirAll(taskDir, 0o755); err != nil {
+		state.Failed = true
+		return state, err
+	}
+
+	var args []string
+	if configArgs, ok := task.Config["args"].([]interface{}); ok {
+		for _, arg := range configArgs {
+			args = append(args, fmt.Sprint(arg))
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, task.Config["command"].(string), args...) //nolint:gosec // running jobs is the point
+	cmd.Dir = taskDir
+	cmd.Env = append(AllowedEnviron(self.env),
+		"NOMAD_JOB_ID="+alloc.JobID,
+		"NOMAD_JOB_NAME="+alloc.JobID,
+		"NOMAD_ALLOC_ID="+alloc.ID,
+		"NOMAD_ALLOC_NAME="+alloc.Name,
+		"NOMAD_ALLOC_DIR="+allocDir,
+		"NOMAD_GROUP_NAME="+alloc.TaskGroup,
+		"NOMAD_TASK_NAME="+task.Name,
+		"NOMAD_TASK_DIR="+taskDir,
+	)
+	for k, v := range task.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	if stdout, err := os.Create(filepath.Join(taskDir, "stdout")); err != nil {
+		state.Failed = true
+		return state, err
+	} else {
+		defer stdout.Close()
+		cmd.Stdout = stdout
+	}
+	if stderr, err := os.Create(filepath.Join(taskDir, "stderr")); err != nil {
+		state.Failed = true
+		return state, err
+	} else {
+		defer stderr.Close()
+		cmd.Stderr = stderr
+	}
+
+	self.logger.Debug().
+		Strs("command", cmd.Args).
+		Str("dir", taskDir).
+		Msg("Starting task")
+
+	err := cmd.Run()
+
+	event := &nomad.TaskEvent{
+		Type:    nomad.TaskTerminated,
+		Time:    time.Now().UnixNano(),
+		Details: map[string]string{},
+	}
+	if cmd.ProcessState != nil {
+		event.ExitCode = cmd.ProcessState.ExitCode()
+		event.Details["exit_code"] = fmt.Sprint(event.ExitCode)
+	}
+	state.Events = append(state.Events, event)
+
+	switch {
+	case ctx.Err() != nil:
+		event.Type = nomad.TaskKilled
+		state.Failed = true
+		return state, nil
+	case err != nil:
+		var errExit *exec.ExitError
+		state.Failed = true
+		if errors.As(err, &errExit) {
+			return state, nil
+		}
+		return state, err
+	}
+
+	return state, nil
+}
+
+func (self *localExecutor) Cancel(jobId string) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	// Nothing to do if the job already finished.
+	if cancel, exists := self.jobs[jobId]; exists {
+		cancel()
+	}
+
+	return nil
+}
+
+func (self *localExecutor) EventStream(ctx context.Context, index uint64) (<-chan *nomad.Events, error) {
+	stream := make(chan *nomad.Events, 64)
+	wake := make(chan struct{}, 1)
+
+	self.mutex.Lock()
+	if index > 0 && self.index < index-1 {
+		self.index = index - 1
+	}
+	if index == 0 {
+		index = self.index + 1
+	}
+	self.subscribers[wake] = struct{}{}
+	self.mutex.Unlock()
+
+	// Events are sent from the backlog by this goroutine
+	// so that publishing never waits for a slow subscriber.
+	go func() {
+		defer func() {
+			self.mutex.Lock()
+			delete(self.subscribers, wake)
+			self.mutex.Unlock()
+			close(stream)
+		}()
+
+		for {
+			self.mutex.Lock()
+			pending := self.eventsFrom(index)
+			self.mutex.Unlock()
+
+			for _, events := range pending {
+				select {
+				case stream <- events:
+					index = events.Index + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// Returns the events in the backlog with the given index or greater.
+// Must be called with the mutex held.
+func (self *localExecutor) eventsFrom(index uint64) []*nomad.Events {
+	i := sort.Search(len(self.backlog), func(i int) bool {
+		return self.backlog[i].Index >= index
+	})
+	return append([]*nomad.Events{}, self.backlog[i:]...)
+}
+
+func (self *localExecutor) publish(alloc *nomad.Allocation) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.index += 1
+
+	// Copy the allocation as it is still being modified.
+	allocCopy := *alloc
+	allocCopy.TaskStates = make(map[string]*nomad.TaskState, len(alloc.TaskStates))
+	for name, state := range alloc.TaskStates {
+		stateCopy := *state
+		allocCopy.TaskStates[name] = &stateCopy
+	}
+
+	self.backlog = append(self.backlog, &nomad.Events{
+		Index: self.index,
+		Events: []nomad.Event{{
+			Topic:   nomad.TopicAllocation,
+			Type:    "AllocationUpdated",
+			Key:     alloc.ID,
+			Index:   self.index,
+			Payload: map[string]interface{}{"Allocation": &allocCopy},
+		}},
+	})
+	if len(self.backlog) > 2*localExecutorBacklog {
+		self.backlog = append([]*nomad.Events{}, self.backlog[len(self.backlog)-localExecutorBacklog:]...)
+	}
+
+	for wake := range self.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildLocalJob(id, command string) *nomad.Job {
+	group := "group"
+	return &nomad.Job{
+		ID: &id,
+		TaskGroups: []*nomad.TaskGroup{{
+			Name: &group,
+			Tasks: []*nomad.Task{{
+				Name:   "task",
+				Driver: "raw_exec",
+				Config: map[string]interface{}{"command": command},
+			}},
+		}},
+	}
+}
+
+func nextTerminalAlloc(t *testing.T, stream <-chan *nomad.Events) *nomad.Allocation {
+	for {
+		select {
+		case events := <-stream:
+			alloc, err := events.Events[0].Allocation()
+			assert.Nil(t, err)
+			if alloc.ClientTerminalStatus() {
+				return alloc
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for allocation event")
+			return nil
+		}
+	}
+}
+
+func TestShouldRunLocalJob(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	executor := NewLocalExecutor(t.TempDir(), []string{"PATH"}, &logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// given
+	stream, err := executor.EventStream(ctx, 42)
+	assert.Nil(t, err)
+
+	// when
+	assert.Nil(t, executor.Submit(context.Background(), buildLocalJob("success", "true")))
+	alloc := nextTerminalAlloc(t, stream)
+
+	// then
+	assert.Equal(t, "success", alloc.JobID)
+	assert.Equal(t, nomad.AllocClientStatusComplete, alloc.ClientStatus)
+	assert.False(t, alloc.TaskStates["task"].Failed)
+
+	// when
+	assert.Nil(t, executor.Submit(context.Background(), buildLocalJob("failure", "false")))
+	alloc = nextTerminalAlloc(t, stream)
+
+	// then
+	assert.Equal(t, "failure", alloc.JobID)
+	assert.Equal(t, nomad.AllocClientStatusFailed, alloc.ClientStatus)
+	assert.True(t, alloc.TaskStates["task"].Failed)
+}
+
+func TestShouldRejectUnsupportedDriver(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	executor := NewLocalExecutor(t.TempDir(), []string{"PATH"}, &logger)
+
+	// given
+	job := buildLocalJob("docker", "true")
+	job.TaskGroups[0].Tasks[0].Driver = "docker"
+
+	// when
+	_, err := executor.Plan(job)
+
+	// then
+	assert.IsType(t, InvalidJobError{}, err)
+}
+
+func TestShouldResumeLocalEventsAfterCancel(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	executor := NewLocalExecutor(t.TempDir(), []string{"PATH"}, &logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// given
+	stream, err := executor.EventStream(ctx, 1)
+	assert.Nil(t, err)
+
+	job := buildLocalJob("canceled", "sleep")
+	job.TaskGroups[0].Tasks[0].Config["args"] = []interface{}{"60"}
+	assert.Nil(t, executor.Submit(context.Background(), job))
+
+	// when
+	events := <-stream
+	// Nobody listens while the job ends.
+	cancel()
+	assert.Nil(t, executor.Cancel("canceled"))
+
+	resumeCtx, resumeCancel := context.WithCancel(context.Background())
+	defer resumeCancel()
+	resumed, err := executor.EventStream(resumeCtx, events.Index+1)
+	assert.Nil(t, err)
+	alloc := nextTerminalAlloc(t, resumed)
+
+	// then
+	assert.Equal(t, nomad.AllocClientStatusFailed, alloc.ClientStatus)
+	assert.True(t, alloc.TaskStates["task"].Failed)
+	assert.Equal(t, nomad.TaskKilled, alloc.TaskStates["task"].Events[0].Type)
+}
+
+func TestShouldOnlyPassAllowedEnvironmentToLocalTasks(t *testing.T) {
+	t.Setenv("CICERO_TEST_SECRET", "secret")
+	logger := zerolog.Nop()
+	executor := NewLocalExecutor(t.TempDir(), []string{"PATH"}, &logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// given
+	stream, err := executor.EventStream(ctx, 1)
+	assert.Nil(t, err)
+
+	job := buildLocalJob("env", "sh")
+	job.TaskGroups[0].Tasks[0].Config["args"] = []interface{}{"-c", `test -z "$CICERO_TEST_SECRET" && test -n "$PATH"`}
+
+	// when
+	assert.Nil(t, executor.Submit(context.Background(), job))
+	alloc := nextTerminalAlloc(t, stream)
+
+	// then
+	assert.Equal(t, nomad.AllocClientStatusComplete, alloc.ClientStatus)
+}
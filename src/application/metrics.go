@@ -0,0 +1,133 @@
+package application
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics about Cicero itself, served at `/metrics`.
+var (
+	MetricFactsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cicero",
+		Name:      "facts_published_total",
+		Help:      "Facts published, by whether a run or someone else published them.",
+	}, []string{"source"})
+
+	MetricIsRunnable = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cicero",
+		Name:      "is_runnable_duration_seconds",
+		Help:      "How long it took to check whether an action is runnable, by its result.",
+	}, []string{"action", "runnable"})
+
+	MetricEvaluations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cicero",
+		Name:      "evaluation_duration_seconds",
+		Help:      "How long evaluators took, by evaluator and command. Its count is the number of invocations.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"evaluator", "command"})
+
+	MetricEvaluationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cicero",
+		Name:      "evaluation_failures_total",
+		Help:      "Evaluator invocations that failed, by evaluator and command.",
+	}, []string{"evaluator", "command"})
+
+	MetricEvaluationCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cicero",
+		Name:      "evaluation_cache_total",
+		Help:      "Lookups of evaluator outputs in the cache, by command and whether they were found (hit, miss).",
+	}, []string{"command", "result"})
+
+	MetricRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cicero",
+		Name:      "runs_total",
+		Help:      "Runs that were started (pending) or ended (success, failure), by action.",
+	}, []string{"action", "status"})
+
+	MetricNomadEventStreamIndex = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cicero",
+		Name:      "nomad_event_stream_index",
+		Help:      "Index of the latest events received from Nomad.",
+	})
+
+	MetricNomadEventProcessedIndex = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cicero",
+		Name:      "nomad_event_processed_index",
+		Help:      "Index of the last Nomad event that was processed. Lags behind the stream index if processing is stuck.",
+	})
+)
+
+// Holds back metric updates made with the returned context
+// until the returned function is called,
+// usually once the transaction that did the counted work has committed.
+// Nested calls hold them back until the outermost one applies them.
+// Also defers other work like WithAfterCommit.
+func WithPendingMetrics(ctx context.Context) (context.Context, func()) {
+	return WithAfterCommit(ctx)
+}
+
+// Updates metrics now or, if the context holds them back, once they are applied.
+func UpdateMetrics(ctx context.Context, update func()) {
+	AfterCommit(ctx, update)
+}
+
+// Exposes the statistics of a connection pool.
+type pgxPoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+}
+
+func NewPgxPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("cicero", "db_pool", name), help, nil, nil)
+	}
+	return &pgxPoolCollector{
+		pool:                 pool,
+		acquireCount:         desc("acquires_total", "Successful acquires of a connection."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+		emptyAcquireCount:    desc("empty_acquires_total", "Acquires that had to wait for a connection because the pool was empty."),
+		canceledAcquireCount: desc("canceled_acquires_total", "Acquires that were canceled."),
+		acquiredConns:        desc("acquired_connections", "Connections in use."),
+		idleConns:            desc("idle_connections", "Idle connections."),
+		constructingConns:    desc("constructing_connections", "Connections being opened."),
+		totalConns:           desc("connections", "All connections in the pool."),
+		maxConns:             desc("max_connections", "Maximum size of the pool."),
+	}
+}
+
+func (self *pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(self, ch)
+}
+
+func (self *pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := self.pool.Stat()
+	for _, m := range []struct {
+		desc      *prometheus.Desc
+		valueType prometheus.ValueType
+		value     float64
+	}{
+		{self.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount())},
+		{self.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds()},
+		{self.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount())},
+		{self.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount())},
+		{self.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns())},
+		{self.idleConns, prometheus.GaugeValue, float64(stat.IdleConns())},
+		{self.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns())},
+		{self.totalConns, prometheus.GaugeValue, float64(stat.TotalConns())},
+		{self.maxConns, prometheus.GaugeValue, float64(stat.MaxConns())},
+	} {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value)
+	}
+}
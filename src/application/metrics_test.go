@@ -0,0 +1,40 @@
+package application_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+)
+
+func TestShouldHoldBackMetricsUntilApplied(t *testing.T) {
+	t.Parallel()
+
+	count := 0
+	inc := func() { count++ }
+
+	// given
+	ctx, apply := application.WithPendingMetrics(context.Background())
+	nestedCtx, applyNested := application.WithPendingMetrics(ctx)
+
+	// when
+	application.UpdateMetrics(nestedCtx, inc)
+	applyNested()
+
+	// then
+	assert.Equal(t, 0, count, "only the outermost applies")
+
+	// when
+	apply()
+
+	// then
+	assert.Equal(t, 1, count)
+
+	// when
+	application.UpdateMetrics(context.Background(), inc)
+
+	// then
+	assert.Equal(t, 2, count, "updated right away without pending metrics")
+}
@@ -0,0 +1,143 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	nomad "github.com/hashicorp/nomad/api"
+
+	"github.com/input-output-hk/cicero/src/application"
+)
+
+// An in-memory stand-in for Nomad.
+// Registered jobs are only recorded, they never run.
+// Events have to be emitted by the test.
+type NomadClient struct {
+	mutex sync.Mutex
+
+	// Returned by JobsValidate and JobsPlan if not nil.
+	ValidateResponse *nomad.JobValidateResponse
+	PlanResponse     *nomad.JobPlanResponse
+
+	jobs         map[string]*nomad.Job
+	deregistered []string
+
+	index   uint64
+	backlog []*nomad.Events
+	streams map[chan *nomad.Events]<-chan struct{}
+}
+
+var _ application.NomadClient = &NomadClient{}
+
+func NewNomadClient() *NomadClient {
+	return &NomadClient{
+		jobs:    map[string]*nomad.Job{},
+		streams: map[chan *nomad.Events]<-chan struct{}{},
+	}
+}
+
+// Returns all jobs that are currently registered.
+func (self *NomadClient) Jobs() map[string]*nomad.Job {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	jobs := make(map[string]*nomad.Job, len(self.jobs))
+	for id, job := range self.jobs {
+		jobs[id] = job
+	}
+	return jobs
+}
+
+// Returns the IDs of all jobs that were deregistered in order.
+func (self *NomadClient) Deregistered() []string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return append([]string{}, self.deregistered...)
+}
+
+// Sends the given events to all current and future event streams.
+// Indices are assigned in order.
+func (self *NomadClient) Emit(events ...nomad.Event) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.index += 1
+	for i := range events {
+		events[i].Index = self.index
+	}
+
+	batch := &nomad.Events{Index: self.index, Events: events}
+	self.backlog = append(self.backlog, batch)
+
+	for stream, done := range self.streams {
+		select {
+		case stream <- batch:
+		case <-done:
+		}
+	}
+}
+
+// Emits an `AllocationUpdated` event.
+func (self *NomadClient) EmitAllocation(alloc *nomad.Allocation) {
+	self.Emit(nomad.Event{
+		Topic:   nomad.TopicAllocation,
+		Type:    "AllocationUpdated",
+		Key:     alloc.ID,
+		Payload: map[string]interface{}{"Allocation": alloc},
+	})
+}
+
+func (self *NomadClient) EventStream(ctx context.Context, index uint64) (<-chan *nomad.Events, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	stream := make(chan *nomad.Events, len(self.backlog)+64)
+	for _, events := range self.backlog {
+		if events.Index >= index {
+			stream <- events
+		}
+	}
+	self.streams[stream] = ctx.Done()
+
+	go func() {
+		<-ctx.Done()
+		self.mutex.Lock()
+		delete(self.streams, stream)
+		close(stream)
+		self.mutex.Unlock()
+	}()
+
+	return stream, nil
+}
+
+func (self *NomadClient) JobsValidate(job *nomad.Job, q *nomad.WriteOptions) (*nomad.JobValidateResponse, *nomad.WriteMeta, error) {
+	if self.ValidateResponse != nil {
+		return self.ValidateResponse, &nomad.WriteMeta{}, nil
+	}
+	return &nomad.JobValidateResponse{DriverConfigValidated: true}, &nomad.WriteMeta{}, nil
+}
+
+func (self *NomadClient) JobsPlan(job *nomad.Job, diff bool, q *nomad.WriteOptions) (*nomad.JobPlanResponse, *nomad.WriteMeta, error) {
+	if self.PlanResponse != nil {
+		return self.PlanResponse, &nomad.WriteMeta{}, nil
+	}
+	return &nomad.JobPlanResponse{}, &nomad.WriteMeta{}, nil
+}
+
+func (self *NomadClient) JobsRegister(job *nomad.Job, q *nomad.WriteOptions) (*nomad.JobRegisterResponse, *nomad.WriteMeta, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.jobs[*job.ID] = job
+	return &nomad.JobRegisterResponse{}, &nomad.WriteMeta{}, nil
+}
+
+func (self *NomadClient) JobsDeregister(jobID string, purge bool, q *nomad.WriteOptions) (string, *nomad.WriteMeta, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	delete(self.jobs, jobID)
+	self.deregistered = append(self.deregistered, jobID)
+	return "", &nomad.WriteMeta{}, nil
+}
@@ -0,0 +1,120 @@
+package mocks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// A minimal OpenID Connect provider that logs in everyone without asking.
+type OidcProvider struct {
+	*httptest.Server
+
+	ClientId     string
+	ClientSecret string
+
+	mutex sync.Mutex
+	// Returned by the user info endpoint.
+	userinfo map[string]interface{}
+	codes    map[string]bool
+	tokens   map[string]bool
+}
+
+func NewOidcProvider() *OidcProvider {
+	self := &OidcProvider{
+		ClientId:     "cicero",
+		ClientSecret: "secret",
+		userinfo:     map[string]interface{}{"sub": "user"},
+		codes:        map[string]bool{},
+		tokens:       map[string]bool{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", self.discovery)
+	mux.HandleFunc("/authorize", self.authorize)
+	mux.HandleFunc("/token", self.token)
+	mux.HandleFunc("/userinfo", self.info)
+	self.Server = httptest.NewServer(mux)
+
+	return self
+}
+
+// Sets the user info for subsequent logins.
+func (self *OidcProvider) SetUserinfo(userinfo map[string]interface{}) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.userinfo = userinfo
+}
+
+func (self *OidcProvider) discovery(w http.ResponseWriter, req *http.Request) {
+	writeJson(w, map[string]string{
+		"issuer":                 self.URL,
+		"authorization_endpoint": self.URL + "/authorize",
+		"token_endpoint":         self.URL + "/token",
+		"userinfo_endpoint":      self.URL + "/userinfo",
+	})
+}
+
+func (self *OidcProvider) authorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if query.Get("client_id") != self.ClientId || query.Get("response_type") != "code" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code := uuid.New().String()
+	self.mutex.Lock()
+	self.codes[code] = true
+	self.mutex.Unlock()
+
+	http.Redirect(w, req, query.Get("redirect_uri")+"?"+url.Values{
+		"code":  {code},
+		"state": {query.Get("state")},
+	}.Encode(), http.StatusFound)
+}
+
+func (self *OidcProvider) token(w http.ResponseWriter, req *http.Request) {
+	if id, secret, ok := req.BasicAuth(); !ok || id != self.ClientId || secret != self.ClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJson(w, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	code := req.PostFormValue("code")
+	if !self.codes[code] {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJson(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	delete(self.codes, code)
+
+	token := uuid.New().String()
+	self.tokens[token] = true
+
+	writeJson(w, map[string]string{"access_token": token, "token_type": "Bearer"})
+}
+
+func (self *OidcProvider) info(w http.ResponseWriter, req *http.Request) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if !self.tokens[strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJson(w, self.userinfo)
+}
+
+func writeJson(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(obj)
+}
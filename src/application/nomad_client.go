@@ -7,33 +7,42 @@ import (
 
 type NomadClient interface {
 	EventStream(ctx context.Context, index uint64) (<-chan *nomad.Events, error)
+	JobsValidate(job *nomad.Job, q *nomad.WriteOptions) (*nomad.JobValidateResponse, *nomad.WriteMeta, error)
+	JobsPlan(job *nomad.Job, diff bool, q *nomad.WriteOptions) (*nomad.JobPlanResponse, *nomad.WriteMeta, error)
 	JobsRegister(job *nomad.Job, q *nomad.WriteOptions) (*nomad.JobRegisterResponse, *nomad.WriteMeta, error)
 	JobsDeregister(jobID string, purge bool, q *nomad.WriteOptions) (string, *nomad.WriteMeta, error)
 }
 
 type nomadClient struct {
 	nClient *nomad.Client
+	topics  map[nomad.Topic][]string
 }
 
-func NewNomadClient(nClient *nomad.Client) NomadClient {
+// Only events of the given topics are streamed.
+// Defaults to all allocation events if none are given.
+func NewNomadClient(nClient *nomad.Client, topics map[nomad.Topic][]string) NomadClient {
+	if len(topics) == 0 {
+		topics = map[nomad.Topic][]string{
+			nomad.TopicAllocation: {string(nomad.TopicAll)},
+		}
+	}
+
 	return &nomadClient{
 		nClient: nClient,
+		topics:  topics,
 	}
 }
 
 func (self *nomadClient) EventStream(ctx context.Context, nomadIndex uint64) (<-chan *nomad.Events, error) {
-	return self.nClient.EventStream().Stream(
-		ctx,
-		map[nomad.Topic][]string{
-			nomad.TopicDeployment: {string(nomad.TopicAll)},
-			nomad.TopicEvaluation: {string(nomad.TopicAll)},
-			nomad.TopicAllocation: {string(nomad.TopicAll)},
-			nomad.TopicJob:        {string(nomad.TopicAll)},
-			nomad.TopicNode:       {string(nomad.TopicAll)},
-		},
-		nomadIndex,
-		nil,
-	)
+	return self.nClient.EventStream().Stream(ctx, self.topics, nomadIndex, nil)
+}
+
+func (self *nomadClient) JobsValidate(job *nomad.Job, q *nomad.WriteOptions) (*nomad.JobValidateResponse, *nomad.WriteMeta, error) {
+	return self.nClient.Jobs().Validate(job, q)
+}
+
+func (self *nomadClient) JobsPlan(job *nomad.Job, diff bool, q *nomad.WriteOptions) (*nomad.JobPlanResponse, *nomad.WriteMeta, error) {
+	return self.nClient.Jobs().Plan(job, diff, q)
 }
 
 func (self *nomadClient) JobsRegister(job *nomad.Job, q *nomad.WriteOptions) (*nomad.JobRegisterResponse, *nomad.WriteMeta, error) {
@@ -0,0 +1,182 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Delivers notifications about Runs that ended.
+type NotificationSink interface {
+	// Must not retry, the caller does that.
+	Send(ctx context.Context, target string, payload domain.NotificationPayload) error
+}
+
+// Posts the payload as JSON to the target URL.
+type webhookNotificationSink struct {
+	client *http.Client
+}
+
+func NewWebhookNotificationSink(client *http.Client) NotificationSink {
+	return &webhookNotificationSink{client}
+}
+
+func (self *webhookNotificationSink) Send(ctx context.Context, target string, payload domain.NotificationPayload) error {
+	return postJson(ctx, self.client, target, payload)
+}
+
+// Posts a message to the target URL in the format
+// that Slack's and Matrix bridges' incoming webhooks understand.
+type chatNotificationSink struct {
+	client *http.Client
+}
+
+func NewChatNotificationSink(client *http.Client) NotificationSink {
+	return &chatNotificationSink{client}
+}
+
+func (self *chatNotificationSink) Send(ctx context.Context, target string, payload domain.NotificationPayload) error {
+	return postJson(ctx, self.client, target, map[string]string{"text": payload.Text()})
+}
+
+func postJson(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	bodyJson, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyJson))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s responded with %s: %s", url, res.Status, resBody)
+	}
+
+	return nil
+}
+
+type SmtpConfig struct {
+	// host:port
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+var headerReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+// Sends an email to the target address.
+type emailNotificationSink struct {
+	config SmtpConfig
+}
+
+func NewEmailNotificationSink(config SmtpConfig) NotificationSink {
+	return &emailNotificationSink{config}
+}
+
+func (self *emailNotificationSink) Send(ctx context.Context, target string, payload domain.NotificationPayload) error {
+	if strings.ContainsAny(target, "\r\n") {
+		return fmt.Errorf("Invalid email address %q", target)
+	}
+
+	host := self.config.Addr
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	msg := strings.Join([]string{
+		"From: " + self.config.From,
+		"To: " + target,
+		"Subject: [cicero] " + headerReplacer.Replace(payload.ActionName) + " " + string(payload.Status),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		payload.Text(),
+		"",
+	}, "\r\n")
+
+	// Unlike smtp.SendMail this gives up when the context is done.
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", self.config.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	if err := self.send(conn, host, target, msg); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func (self *emailNotificationSink) send(conn net.Conn, host, target, msg string) error {
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil { //nolint:gosec // same as smtp.SendMail
+			return err
+		}
+	}
+
+	if self.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", self.config.Username, self.config.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(self.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(target); err != nil {
+		return err
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
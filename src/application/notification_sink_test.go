@@ -0,0 +1,86 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldPostNotifications(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	payload := domain.NotificationPayload{
+		RunId:      uuid.New(),
+		ActionId:   uuid.New(),
+		ActionName: "ci",
+		Status:     domain.RunFailed,
+		CreatedAt:  now.Add(-time.Minute),
+		FinishedAt: &now,
+		Url:        "https://cicero.example.com/run/x",
+	}
+
+	var received map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		received = nil
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, application.NewWebhookNotificationSink(server.Client()).Send(context.Background(), server.URL, payload))
+	assert.Equal(t, payload.RunId.String(), received["run_id"])
+	assert.Equal(t, "failure", received["status"])
+
+	assert.NoError(t, application.NewChatNotificationSink(server.Client()).Send(context.Background(), server.URL, payload))
+	assert.Equal(t, map[string]interface{}{
+		"text": "Run " + payload.RunId.String() + " of ci failed after 1m0s: https://cicero.example.com/run/x",
+	}, received)
+
+	status = http.StatusBadGateway
+	assert.Error(t, application.NewWebhookNotificationSink(server.Client()).Send(context.Background(), server.URL, payload))
+}
+
+func TestShouldGiveUpOnSilentMailServer(t *testing.T) {
+	t.Parallel()
+
+	// given
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+	go func() {
+		// Accept but never greet.
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(10 * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// when
+	start := time.Now()
+	err = application.NewEmailNotificationSink(application.SmtpConfig{
+		Addr: listener.Addr().String(),
+		From: "cicero@example.com",
+	}).Send(ctx, "dev@example.com", domain.NotificationPayload{ActionName: "ci"})
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
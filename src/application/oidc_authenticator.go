@@ -0,0 +1,241 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+type OidcConfig struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	CallbackUrl  string
+	Scopes       []string
+
+	// Claim of the user info that lists the groups a user is in.
+	GroupsClaim string
+	// Users get the highest role of all their groups.
+	GroupRoles map[string]domain.Role
+	// Role for users that are in none of the groups.
+	// If nil such users cannot log in.
+	DefaultRole *domain.Role
+}
+
+// Logs in users with the OpenID Connect authorization code flow.
+// The identity is taken from the user info endpoint,
+// which is queried directly with the access token
+// so the ID token does not need to be verified.
+type oidcAuthenticator struct {
+	config OidcConfig
+	logger zerolog.Logger
+	client *http.Client
+
+	mutex     sync.Mutex
+	discovery *oidcDiscovery
+}
+
+type oidcDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+func NewOidcAuthenticator(config OidcConfig, logger *zerolog.Logger) Authenticator {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	return &oidcAuthenticator{
+		config: config,
+		logger: logger.With().Str("component", "OidcAuthenticator").Logger(),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetches the provider configuration on first use
+// so that Cicero can start while the provider is unavailable.
+func (self *oidcAuthenticator) discover() (*oidcDiscovery, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.discovery != nil {
+		return self.discovery, nil
+	}
+
+	discovery := oidcDiscovery{}
+	if err := self.getJson(strings.TrimSuffix(self.config.Issuer, "/")+"/.well-known/openid-configuration", "", &discovery); err != nil {
+		return nil, errors.WithMessagef(err, "Could not discover OpenID Connect provider %q", self.config.Issuer)
+	}
+
+	self.discovery = &discovery
+	return self.discovery, nil
+}
+
+func (self *oidcAuthenticator) LoginUrl(state string) string {
+	discovery, err := self.discover()
+	if err != nil {
+		// Send the browser back to us so that the error is shown.
+		self.logger.Err(err).Send()
+		return self.config.CallbackUrl + "?" + url.Values{
+			"state":             {state},
+			"error":             {"temporarily_unavailable"},
+			"error_description": {err.Error()},
+		}.Encode()
+	}
+
+	return discovery.AuthorizationEndpoint + "?" + url.Values{
+		"response_type": {"code"},
+		"client_id":     {self.config.ClientId},
+		"redirect_uri":  {self.config.CallbackUrl},
+		"scope":         {strings.Join(self.config.Scopes, " ")},
+		"state":         {state},
+	}.Encode()
+}
+
+func (self *oidcAuthenticator) Callback(req *http.Request) (identity domain.Identity, err error) {
+	query := req.URL.Query()
+
+	if e := query.Get("error"); e != "" {
+		err = fmt.Errorf("OpenID Connect provider returned %s: %s", e, query.Get("error_description"))
+		return
+	}
+
+	discovery, err := self.discover()
+	if err != nil {
+		return
+	}
+
+	accessToken, err := self.exchange(req.Context(), discovery, query.Get("code"))
+	if err != nil {
+		return
+	}
+
+	userinfo := map[string]interface{}{}
+	if err = self.getJson(discovery.UserinfoEndpoint, accessToken, &userinfo); err != nil {
+		err = errors.WithMessage(err, "Could not get user info")
+		return
+	}
+
+	return self.identity(userinfo)
+}
+
+func (self *oidcAuthenticator) exchange(ctx context.Context, discovery *oidcDiscovery, code string) (string, error) {
+	if code == "" {
+		return "", errors.New("Missing authorization code")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {self.config.CallbackUrl},
+	}.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(self.config.ClientId), url.QueryEscape(self.config.ClientSecret))
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return "", errors.WithMessage(err, "Could not exchange authorization code")
+	}
+	defer res.Body.Close()
+
+	body := struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", errors.WithMessage(err, "Could not decode token response")
+	}
+
+	switch {
+	case body.Error != "":
+		return "", fmt.Errorf("Could not exchange authorization code: %s: %s", body.Error, body.ErrorDescription)
+	case res.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("Could not exchange authorization code: %s", res.Status)
+	case body.AccessToken == "":
+		return "", errors.New("Token response contains no access token")
+	}
+
+	return body.AccessToken, nil
+}
+
+func (self *oidcAuthenticator) identity(userinfo map[string]interface{}) (identity domain.Identity, err error) {
+	for _, claim := range []string{"preferred_username", "email", "sub"} {
+		if name, ok := userinfo[claim].(string); ok && name != "" {
+			identity.Name = name
+			break
+		}
+	}
+	if identity.Name == "" {
+		err = errors.New("User info contains no name")
+		return
+	}
+
+	var groups []string
+	switch claim := userinfo[self.config.GroupsClaim].(type) {
+	case string:
+		groups = []string{claim}
+	case []interface{}:
+		for _, group := range claim {
+			if group, ok := group.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	found := false
+	for _, group := range groups {
+		if role, ok := self.config.GroupRoles[group]; ok && (!found || role.Includes(identity.Role)) {
+			identity.Role = role
+			found = true
+		}
+	}
+
+	if !found {
+		if self.config.DefaultRole == nil {
+			err = fmt.Errorf("User %q is not in any group that has a role", identity.Name)
+			return
+		}
+		identity.Role = *self.config.DefaultRole
+	}
+
+	return
+}
+
+func (self *oidcAuthenticator) getJson(url, accessToken string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
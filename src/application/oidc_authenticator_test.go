@@ -0,0 +1,103 @@
+package application_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/application/mocks"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Follows the login URL to the provider and returns the request it redirects back with.
+func login(t *testing.T, authenticator application.Authenticator, state string) *http.Request {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	res, err := client.Get(authenticator.LoginUrl(state))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	res.Body.Close()
+
+	location, err := res.Location()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, state, location.Query().Get("state"))
+
+	return httptest.NewRequest(http.MethodGet, location.String(), nil)
+}
+
+func TestShouldLoginWithOidc(t *testing.T) {
+	t.Parallel()
+
+	provider := mocks.NewOidcProvider()
+	defer provider.Close()
+
+	logger := zerolog.Nop()
+	viewer := domain.RoleViewer
+	authenticator := application.NewOidcAuthenticator(application.OidcConfig{
+		Issuer:       provider.URL,
+		ClientId:     provider.ClientId,
+		ClientSecret: provider.ClientSecret,
+		CallbackUrl:  "http://cicero/login/callback",
+		GroupsClaim:  "groups",
+		GroupRoles: map[string]domain.Role{
+			"ops":    domain.RoleOperator,
+			"admins": domain.RoleAdmin,
+		},
+		DefaultRole: &viewer,
+	}, &logger)
+
+	for _, tc := range []struct {
+		userinfo map[string]interface{}
+		identity domain.Identity
+	}{
+		{
+			userinfo: map[string]interface{}{"sub": "1", "preferred_username": "alice", "groups": []string{"admins", "ops"}},
+			identity: domain.Identity{Name: "alice", Role: domain.RoleAdmin},
+		},
+		{
+			userinfo: map[string]interface{}{"sub": "2", "email": "bob@example.com", "groups": "ops"},
+			identity: domain.Identity{Name: "bob@example.com", Role: domain.RoleOperator},
+		},
+		{
+			userinfo: map[string]interface{}{"sub": "3"},
+			identity: domain.Identity{Name: "3", Role: domain.RoleViewer},
+		},
+	} {
+		provider.SetUserinfo(tc.userinfo)
+
+		identity, err := authenticator.Callback(login(t, authenticator, "state"))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.identity, identity)
+	}
+}
+
+func TestShouldRejectOidcUsersWithoutRole(t *testing.T) {
+	t.Parallel()
+
+	provider := mocks.NewOidcProvider()
+	defer provider.Close()
+
+	logger := zerolog.Nop()
+	authenticator := application.NewOidcAuthenticator(application.OidcConfig{
+		Issuer:       provider.URL,
+		ClientId:     provider.ClientId,
+		ClientSecret: provider.ClientSecret,
+		CallbackUrl:  "http://cicero/login/callback",
+		GroupsClaim:  "groups",
+		GroupRoles:   map[string]domain.Role{"ops": domain.RoleOperator},
+	}, &logger)
+
+	provider.SetUserinfo(map[string]interface{}{"sub": "mallory", "groups": []string{"guests"}})
+
+	_, err := authenticator.Callback(login(t, authenticator, "state"))
+	assert.Error(t, err)
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"cuelang.org/go/cue"
 	"github.com/georgysavva/scany/pgxscan"
@@ -21,6 +23,8 @@ import (
 
 type ActionService interface {
 	WithQuerier(config.PgxIface) ActionService
+	WithActor(*domain.Actor) ActionService
+	WithContext(context.Context) ActionService
 
 	GetById(uuid.UUID) (domain.Action, error)
 	GetByRunId(uuid.UUID) (domain.Action, error)
@@ -34,43 +38,103 @@ type ActionService interface {
 	IsRunnable(*domain.Action) (bool, map[string]interface{}, error)
 	Create(string, string) (*domain.Action, error)
 	Invoke(*domain.Action) (bool, error)
+	Plan(*domain.Action) (domain.ActionPlan, error)
+	Validate(source, name string) (domain.SourceValidation, error)
 	InvokeCurrentActive() error
+	// Ends the Run with the outcome and finish time set on it
+	// and queues notifications. Unless told not to publish, it also publishes
+	// the output for the outcome, preferring that of the given task group.
+	// Returns false if the Run had already ended.
+	EndRun(run *domain.Run, group string, exitCode *int, publish bool) (bool, error)
+	// Cancels the Run like RunService.Cancel.
+	// Ends it right away if it has no allocations
+	// as no allocation event would ever end it then.
+	CancelRun(*domain.Run) error
+	// Times out the Run like RunService.TimeOut
+	// and ends it right away like CancelRun.
+	TimeOutRun(*domain.Run) (bool, error)
 }
 
 type actionService struct {
-	logger            zerolog.Logger
-	actionRepository  repository.ActionRepository
-	factRepository    repository.FactRepository
-	evaluationService EvaluationService
-	runService        RunService
-	nomadClient       application.NomadClient
-	db                config.PgxIface
+	logger                  zerolog.Logger
+	actionRepository        repository.ActionRepository
+	factRepository          repository.FactRepository
+	runAllocationRepository repository.RunAllocationRepository
+	evaluationService       EvaluationService
+	runService              RunService
+	notificationService     NotificationService
+	executor                application.Executor
+	transformers            []domain.TransformerConfig
+	db                      config.PgxIface
+	// Not replaced by WithQuerier so that work
+	// done after a transaction committed can use it.
+	pool config.PgxIface
+
+	auditEventRepository repository.AuditEventRepository
+	actor                *domain.Actor
+
+	// Spans are started as children of the one in this context
+	// and work in progress is aborted when it is done.
+	ctx context.Context
 }
 
-func NewActionService(db config.PgxIface, nomadClient application.NomadClient, runService RunService, evaluationService EvaluationService, logger *zerolog.Logger) ActionService {
+// The transformers are those that Actions can select
+// as well as the external ones that the EvaluationService runs.
+func NewActionService(db config.PgxIface, executor application.Executor, runService RunService, evaluationService EvaluationService, notificationService NotificationService, transformers []domain.TransformerConfig, logger *zerolog.Logger) ActionService {
 	return &actionService{
-		logger:            logger.With().Str("component", "ActionService").Logger(),
-		actionRepository:  persistence.NewActionRepository(db),
-		factRepository:    persistence.NewFactRepository(db),
-		evaluationService: evaluationService,
-		nomadClient:       nomadClient,
-		runService:        runService,
-		db:                db,
+		logger:                  logger.With().Str("component", "ActionService").Logger(),
+		actionRepository:        persistence.NewActionRepository(db),
+		factRepository:          persistence.NewFactRepository(db),
+		runAllocationRepository: persistence.NewRunAllocationRepository(db),
+		evaluationService:       evaluationService,
+		executor:                executor,
+		transformers:            transformers,
+		runService:              runService,
+		notificationService:     notificationService,
+		db:                      db,
+		pool:                    db,
+
+		auditEventRepository: persistence.NewAuditEventRepository(db),
+		ctx:                  context.Background(),
 	}
 }
 
 func (self *actionService) WithQuerier(querier config.PgxIface) ActionService {
 	return &actionService{
-		logger:            self.logger,
-		actionRepository:  self.actionRepository.WithQuerier(querier),
-		factRepository:    self.factRepository.WithQuerier(querier),
-		runService:        self.runService.WithQuerier(querier),
-		evaluationService: self.evaluationService,
-		nomadClient:       self.nomadClient,
-		db:                querier,
+		logger:                  self.logger,
+		actionRepository:        self.actionRepository.WithQuerier(querier),
+		factRepository:          self.factRepository.WithQuerier(querier),
+		runAllocationRepository: self.runAllocationRepository.WithQuerier(querier),
+		runService:              self.runService.WithQuerier(querier),
+		notificationService:     self.notificationService.WithQuerier(querier),
+		evaluationService:       self.evaluationService,
+		executor:                self.executor,
+		transformers:            self.transformers,
+		db:                      querier,
+		pool:                    self.pool,
+
+		auditEventRepository: self.auditEventRepository.WithQuerier(querier),
+		actor:                self.actor,
+		ctx:                  self.ctx,
 	}
 }
 
+// Returns a copy that records the given actor in the audit log.
+func (self *actionService) WithActor(actor *domain.Actor) ActionService {
+	clone := *self
+	clone.actor = actor
+	clone.runService = self.runService.WithActor(actor)
+	return &clone
+}
+
+// Returns a copy that continues the trace in the given context
+// and aborts work in progress when it is done.
+func (self *actionService) WithContext(ctx context.Context) ActionService {
+	clone := *self
+	clone.ctx = ctx
+	return &clone
+}
+
 func (self *actionService) GetById(id uuid.UUID) (action domain.Action, err error) {
 	self.logger.Debug().Str("id", id.String()).Msg("Getting Action by ID")
 	action, err = self.actionRepository.GetById(id)
@@ -115,8 +179,17 @@ func (self *actionService) Save(action *domain.Action) error {
 
 func (self *actionService) Update(action *domain.Action) error {
 	self.logger.Debug().Str("id", action.ID.String()).Msg("Updating Action")
-	if err := self.actionRepository.Update(action); err != nil {
-		return errors.WithMessagef(err, "Could not update Action")
+	if err := self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		before, err := self.actionRepository.WithQuerier(tx).GetById(action.ID)
+		if err != nil {
+			return errors.WithMessagef(err, "Could not select existing Action for ID %q", action.ID)
+		}
+		if err := self.actionRepository.WithQuerier(tx).Update(action); err != nil {
+			return errors.WithMessagef(err, "Could not update Action")
+		}
+		return audit(self.auditEventRepository.WithQuerier(tx), self.actor, domain.AuditActionUpdate, action.ID, before, action)
+	}); err != nil {
+		return err
 	}
 	self.logger.Debug().Str("id", action.ID.String()).Msg("Updated Action")
 	return nil
@@ -137,6 +210,22 @@ func (self *actionService) GetCurrentActive() (actions []*domain.Action, err err
 }
 
 func (self *actionService) IsRunnable(action *domain.Action) (bool, map[string]interface{}, error) {
+	_, span := application.StartSpan(self.ctx, "ActionService.IsRunnable", application.SpanKindInternal)
+	span.SetAttribute("cicero.action.name", action.Name)
+	span.SetAttribute("cicero.action.id", action.ID.String())
+
+	start := time.Now()
+	runnable, inputs, err := self.isRunnable(action)
+	if err == nil {
+		application.MetricIsRunnable.WithLabelValues(action.Name, strconv.FormatBool(runnable)).Observe(time.Since(start).Seconds())
+		span.SetAttribute("cicero.action.runnable", strconv.FormatBool(runnable))
+	}
+
+	span.End(err)
+	return runnable, inputs, err
+}
+
+func (self *actionService) isRunnable(action *domain.Action) (bool, map[string]interface{}, error) {
 	logger := self.logger.With().
 		Str("name", action.Name).
 		Str("id", action.ID.String()).
@@ -495,8 +584,14 @@ func (self *actionService) Create(source, name string) (*domain.Action, error) {
 		Source: source,
 	}
 
+	if revision, err := self.evaluationService.WithContext(self.ctx).ResolveSource(source); err != nil {
+		return nil, errors.WithMessagef(err, "Could not resolve source %q", source)
+	} else {
+		action.Revision = revision
+	}
+
 	var actionDef domain.ActionDefinition
-	if def, err := self.evaluationService.EvaluateAction(source, name, action.ID); err != nil {
+	if def, err := self.evaluationService.WithContext(self.ctx).EvaluateAction(source, action.Revision, name, action.ID); err != nil {
 		self.logger.Err(err).Send()
 		return nil, err
 	} else {
@@ -506,8 +601,10 @@ func (self *actionService) Create(source, name string) (*domain.Action, error) {
 	action.Meta = actionDef.Meta
 	action.Inputs = actionDef.Inputs
 
-	if err := self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
-		txSelf := self.WithQuerier(tx)
+	ctx, applyMetrics := application.WithPendingMetrics(self.ctx)
+
+	if err := self.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		txSelf := self.WithQuerier(tx).WithContext(ctx)
 
 		// deactivate previous version for convenience
 		if prev, err := txSelf.GetLatestByName(action.Name); err != nil && !pgxscan.NotFound(err) {
@@ -523,20 +620,36 @@ func (self *actionService) Create(source, name string) (*domain.Action, error) {
 			return err
 		}
 
+		if err := audit(self.auditEventRepository.WithQuerier(tx), self.actor, domain.AuditActionCreate, action.ID, nil, action); err != nil {
+			return err
+		}
+
 		_, err := txSelf.Invoke(&action)
 
 		return err
 	}); err != nil {
 		return nil, err
 	}
+	applyMetrics()
 
 	return &action, nil
 }
 
-func (self *actionService) Invoke(action *domain.Action) (bool, error) {
-	runnable := false
-	return runnable, self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
-		txSelf := self.WithQuerier(tx)
+func (self *actionService) Invoke(action *domain.Action) (runnable bool, err error) {
+	ctx, span := application.StartSpan(self.ctx, "ActionService.Invoke", application.SpanKindInternal)
+	span.SetAttribute("cicero.action.name", action.Name)
+	span.SetAttribute("cicero.action.id", action.ID.String())
+	defer func() { span.End(err) }()
+
+	ctx, applyMetrics := application.WithPendingMetrics(ctx)
+	defer func() {
+		if err == nil {
+			applyMetrics()
+		}
+	}()
+
+	err = self.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		txSelf := self.WithQuerier(tx).WithContext(ctx)
 
 		runnable_, inputs, err := txSelf.IsRunnable(action)
 		runnable = runnable_
@@ -544,7 +657,7 @@ func (self *actionService) Invoke(action *domain.Action) (bool, error) {
 			return err
 		}
 
-		runDef, err := self.evaluationService.EvaluateRun(action.Source, action.Name, action.ID, inputs)
+		runDef, err := self.evaluationService.WithContext(ctx).EvaluateRun(action.Source, action.Revision, action.Name, action.ID, inputs)
 		if err != nil {
 			var evalErr EvaluationError
 			if errors.As(err, &evalErr) {
@@ -556,50 +669,275 @@ func (self *actionService) Invoke(action *domain.Action) (bool, error) {
 			return err
 		}
 
+		chain, err := domain.TransformerChain(self.transformers, action.ActionDefinition)
+		if err != nil {
+			return newEvaluationError(err)
+		}
+
+		timeout, err := action.RunTimeout()
+		if err != nil {
+			return newEvaluationError(err)
+		}
+
 		run := domain.Run{
-			ActionId: action.ID,
+			ActionId:     action.ID,
+			Transformers: chain,
+		}
+		if timeout > 0 && !runDef.IsDecision() {
+			deadline := time.Now().UTC().Add(timeout)
+			run.Deadline = &deadline
 		}
 
 		if err := self.runService.WithQuerier(tx).Save(&run, inputs, &runDef.Output); err != nil {
 			return errors.WithMessage(err, "Could not insert Run")
 		}
+		span.SetAttribute("cicero.run.id", run.NomadJobID.String())
 
 		if runDef.IsDecision() {
-			if runDef.Output.Success != nil {
-				if err := self.factRepository.WithQuerier(tx).Save(&domain.Fact{Value: runDef.Output.Success}, nil); err != nil {
-					return errors.WithMessage(err, "Could not publish fact")
-				}
+			if err := self.runService.WithQuerier(tx).SaveDefinition(run.NomadJobID, &runDef); err != nil {
+				return err
 			}
 
 			run.CreatedAt = run.CreatedAt.UTC()
 			run.FinishedAt = &run.CreatedAt
+			outcome := domain.RunOutcomeSuccess
+			run.Outcome = &outcome
+
+			if value, template := runDef.Output.Select(outcome, ""); value != nil {
+				fact := domain.Fact{Value: *value}
+				if template {
+					fact.Value = domain.RunOutputContext{
+						RunId:      run.NomadJobID,
+						ActionId:   action.ID,
+						ActionName: action.Name,
+						Outcome:    outcome,
+						CreatedAt:  run.CreatedAt,
+						FinishedAt: run.CreatedAt,
+					}.Render(fact.Value)
+				}
+				if err := self.factRepository.WithQuerier(tx).Save(&fact, nil); err != nil {
+					return errors.WithMessage(err, "Could not publish fact")
+				}
+				application.UpdateMetrics(ctx, application.MetricFactsPublished.WithLabelValues("run").Inc)
+			}
 
-			err := self.runService.WithQuerier(tx).Update(&run)
-			err = errors.WithMessage(err, "Could not update decision Run")
+			if err := self.runService.WithQuerier(tx).Update(&run); err != nil {
+				return errors.WithMessage(err, "Could not update decision Run")
+			}
 
-			return err
+			application.UpdateMetrics(ctx, application.MetricRuns.WithLabelValues(action.Name, string(domain.RunSucceeded)).Inc)
+
+			return nil
 		}
 
 		runId := run.NomadJobID.String()
 		runDef.Job.ID = &runId
 
-		if response, _, err := self.nomadClient.JobsRegister(runDef.Job, &nomad.WriteOptions{}); err != nil {
-			return errors.WithMessage(err, "Failed to run Action")
-		} else if len(response.Warnings) > 0 {
+		token, err := self.runService.WithQuerier(tx).CreateToken(run.NomadJobID)
+		if err != nil {
+			return err
+		}
+
+		env := self.runEnv(action, run.NomadJobID, token)
+		if err := self.transform(runDef.Job, chain, env); err != nil {
+			return err
+		}
+
+		// Saved with the definition so that the Run's events
+		// continue the trace that started it.
+		if traceparent := application.Traceparent(ctx); traceparent != "" {
+			env[application.TraceparentEnv] = traceparent
+		}
+
+		// Set again after the chain so that no configuration
+		// or transformer keeps the job from publishing facts.
+		for _, group := range runDef.Job.TaskGroups {
+			for _, task := range group.Tasks {
+				if task.Env == nil {
+					task.Env = map[string]string{}
+				}
+				for k, v := range env {
+					task.Env[k] = v
+				}
+			}
+		}
+
+		var plan *domain.RunPlan
+		if err := withoutRunToken(runDef.Job, func() (err error) {
+			if plan, err = self.planJob(runDef.Job); err != nil {
+				return
+			}
+			return self.runService.WithQuerier(tx).SaveDefinition(run.NomadJobID, &runDef)
+		}); err != nil {
+			return err
+		}
+
+		if err := self.runService.WithQuerier(tx).SavePlan(run.NomadJobID, plan); err != nil {
+			return errors.WithMessage(err, "Could not insert Run Plan")
+		}
+
+		if !plan.IsPlaceable() {
 			self.logger.Warn().
 				Str("nomad-job", runId).
-				Str("nomad-evaluation", response.EvalID).
-				Str("warnings", response.Warnings).
-				Msg("Warnings occured registering Nomad job")
+				Interface("failed-task-groups", plan.FailedTGAllocs).
+				Msg("Nomad job cannot be placed at the moment")
 		}
 
+		if err := self.notificationService.WithQuerier(tx).Notify(run, domain.RunPending); err != nil {
+			return err
+		}
+
+		// Only start the job once the Run is committed
+		// so that the executor's events always find it.
+		job := runDef.Job
+		application.AfterCommit(ctx, func() { self.submit(ctx, run, job) })
+
+		application.UpdateMetrics(ctx, application.MetricRuns.WithLabelValues(action.Name, string(domain.RunPending)).Inc)
+
 		return nil
 	})
+	return
+}
+
+// Starts the job of the committed Run, ending it as failed if that is not possible.
+func (self *actionService) submit(ctx context.Context, run domain.Run, job *nomad.Job) {
+	err := self.executor.Submit(ctx, job)
+	if err == nil {
+		return
+	}
+	self.logger.Err(err).Str("nomad-job", *job.ID).Msg("Failed to run Action")
+
+	outcome := domain.RunOutcomeFailure
+	finishedAt := time.Now().UTC()
+	run.Outcome = &outcome
+	run.FinishedAt = &finishedAt
+
+	ctx, applyMetrics := application.WithPendingMetrics(ctx)
+	if _, err := self.WithQuerier(self.pool).WithContext(ctx).EndRun(&run, "", nil, false); err != nil {
+		self.logger.Err(err).Str("nomad-job", *job.ID).Msg("Could not end Run whose job failed to start")
+		return
+	}
+	applyMetrics()
+}
+
+// Environment variables that every task of the Run gets.
+// The token is left out if it is empty.
+func (self *actionService) runEnv(action *domain.Action, runId uuid.UUID, token string) map[string]string {
+	env := map[string]string{
+		"CICERO_ACTION_NAME": action.Name,
+		"CICERO_ACTION_ID":   action.ID.String(),
+		"CICERO_RUN_ID":      runId.String(),
+	}
+	if token != "" {
+		env[RunTokenEnv] = token
+	}
+	return env
+}
+
+// Applies the built-in transformers of the chain to the job.
+// External ones were already applied during evaluation.
+func (self *actionService) transform(job *nomad.Job, chain []domain.TransformerConfig, env map[string]string) error {
+	for _, config := range chain {
+		if config.Type == domain.ExternalTransformer {
+			continue
+		}
+
+		transformer, err := application.NewTransformer(config)
+		if err != nil {
+			return err
+		}
+
+		self.logger.Debug().Str("transformer", config.Name).Msg("Running transformer")
+
+		if err := transformer.Transform(job, application.TransformContext{Env: env}); err != nil {
+			var invalidErr application.InvalidJobError
+			if errors.As(err, &invalidErr) {
+				err = newEvaluationError(invalidErr)
+			}
+			return errors.WithMessagef(err, "Transformer %q failed", config.Name)
+		}
+	}
+	return nil
+}
+
+// Calls the function while the Run token is removed from the job
+// so that it does not end up in the plan's diff or the stored definition.
+func withoutRunToken(job *nomad.Job, f func() error) error {
+	tokens := map[*nomad.Task]string{}
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			if token, ok := task.Env[RunTokenEnv]; ok {
+				tokens[task] = token
+				delete(task.Env, RunTokenEnv)
+			}
+		}
+	}
+	defer func() {
+		for task, token := range tokens {
+			task.Env[RunTokenEnv] = token
+		}
+	}()
+
+	return f()
+}
+
+// Asks the Executor whether and how it would run the job.
+func (self *actionService) planJob(job *nomad.Job) (*domain.RunPlan, error) {
+	plan, err := self.executor.Plan(job)
+	var invalidErr application.InvalidJobError
+	if errors.As(err, &invalidErr) {
+		err = newEvaluationError(invalidErr)
+	}
+	return plan, err
+}
+
+// Evaluates and plans the Action against the current facts
+// without creating a Run or registering a Nomad job.
+// Inputs are also given if the Action is not runnable
+// because they are the same as for the previous Run.
+func (self *actionService) Plan(action *domain.Action) (plan domain.ActionPlan, err error) {
+	plan.Runnable, plan.Inputs, err = self.IsRunnable(action)
+	if err != nil || plan.Inputs == nil {
+		return
+	}
+
+	runDef, err := self.evaluationService.WithContext(self.ctx).EvaluateRun(action.Source, action.Revision, action.Name, action.ID, plan.Inputs)
+	if err != nil {
+		return
+	}
+
+	plan.Output = &runDef.Output
+
+	if runDef.IsDecision() {
+		return
+	}
+
+	chain, err := domain.TransformerChain(self.transformers, action.ActionDefinition)
+	if err != nil {
+		err = newEvaluationError(err)
+		return
+	}
+
+	// Use an ID that no Run has so Nomad plans it as a new job.
+	jobId := uuid.New()
+	jobIdStr := jobId.String()
+	runDef.Job.ID = &jobIdStr
+	plan.Job = runDef.Job
+
+	if err = self.transform(runDef.Job, chain, self.runEnv(action, jobId, "")); err != nil {
+		return
+	}
+
+	plan.Plan, err = self.planJob(runDef.Job)
+	return
 }
 
-func (self *actionService) InvokeCurrentActive() error {
-	return self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
-		txSelf := self.WithQuerier(tx)
+func (self *actionService) InvokeCurrentActive() (err error) {
+	ctx, span := application.StartSpan(self.ctx, "ActionService.InvokeCurrentActive", application.SpanKindInternal)
+	defer func() { span.End(err) }()
+
+	return self.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		txSelf := self.WithQuerier(tx).WithContext(ctx)
 
 		actions, err := txSelf.GetCurrentActive()
 		if err != nil {
@@ -626,3 +964,107 @@ func (self *actionService) InvokeCurrentActive() error {
 		return nil
 	})
 }
+
+func (self *actionService) EndRun(run *domain.Run, group string, exitCode *int, publish bool) (ended bool, err error) {
+	ctx, span := application.StartSpan(self.ctx, "ActionService.EndRun", application.SpanKindInternal)
+	span.SetAttribute("cicero.run.id", run.NomadJobID.String())
+	span.SetAttribute("cicero.run.outcome", string(*run.Outcome))
+	defer func() { span.End(err) }()
+
+	err = self.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		// Ending the Run deletes its output so get it first.
+		output, err := self.runService.WithQuerier(tx).GetOutputByNomadJobId(run.NomadJobID)
+		if err != nil && !pgxscan.NotFound(err) {
+			return err
+		}
+
+		action, err := self.actionRepository.WithQuerier(tx).GetById(run.ActionId)
+		if err != nil {
+			return errors.WithMessagef(err, "Could not select Action of Run %q", run.NomadJobID)
+		}
+
+		if ended, err = self.runService.WithQuerier(tx).End(run); err != nil {
+			return errors.WithMessagef(err, "Failed to end Run with ID %q", run.NomadJobID)
+		} else if !ended {
+			return nil
+		}
+
+		published := false
+		if value, template := output.Select(*run.Outcome, group); value != nil && publish {
+			fact := domain.Fact{RunId: &run.NomadJobID, Value: *value}
+			if template {
+				fact.Value = domain.RunOutputContext{
+					RunId:      run.NomadJobID,
+					ActionId:   action.ID,
+					ActionName: action.Name,
+					Outcome:    *run.Outcome,
+					Group:      group,
+					CreatedAt:  run.CreatedAt,
+					FinishedAt: *run.FinishedAt,
+					ExitCode:   exitCode,
+				}.Render(fact.Value)
+			}
+			if err := self.factRepository.WithQuerier(tx).Save(&fact, nil); err != nil {
+				return errors.WithMessage(err, "Could not publish Fact")
+			}
+			application.UpdateMetrics(ctx, application.MetricFactsPublished.WithLabelValues("run").Inc)
+			published = true
+		}
+
+		status := run.Outcome.Status()
+		application.UpdateMetrics(ctx, application.MetricRuns.WithLabelValues(action.Name, string(status)).Inc)
+
+		if err := self.notificationService.WithQuerier(tx).Notify(*run, status); err != nil {
+			return errors.WithMessage(err, "Could not queue notifications")
+		}
+
+		if published {
+			return self.WithQuerier(tx).WithContext(ctx).InvokeCurrentActive()
+		}
+		return nil
+	})
+	return
+}
+
+func (self *actionService) CancelRun(run *domain.Run) error {
+	_, err := self.stopRun(run, func(runService RunService) (bool, error) {
+		return runService.Cancel(run)
+	})
+	return err
+}
+
+func (self *actionService) TimeOutRun(run *domain.Run) (bool, error) {
+	return self.stopRun(run, func(runService RunService) (bool, error) {
+		return runService.TimeOut(run)
+	})
+}
+
+// Stops the Run and, in the same transaction, ends it if it has no allocations.
+// Should its job get an allocation meanwhile, its events are ignored
+// as the Run has already ended and the job is canceled anyway.
+func (self *actionService) stopRun(run *domain.Run, stop func(RunService) (bool, error)) (stopped bool, err error) {
+	ctx, applyMetrics := application.WithPendingMetrics(self.ctx)
+	defer func() {
+		if err == nil {
+			applyMetrics()
+		}
+	}()
+
+	err = self.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if stopped, err = stop(self.runService.WithQuerier(tx)); err != nil || !stopped {
+			return err
+		}
+
+		if allocs, err := self.runAllocationRepository.WithQuerier(tx).GetByRunId(run.NomadJobID); err != nil {
+			return errors.WithMessagef(err, "Could not select allocations of Run %q", run.NomadJobID)
+		} else if len(allocs) > 0 {
+			return nil
+		}
+
+		finishedAt := time.Now().UTC()
+		run.FinishedAt = &finishedAt
+		_, err := self.WithQuerier(tx).WithContext(ctx).EndRun(run, "", nil, true)
+		return err
+	})
+	return
+}
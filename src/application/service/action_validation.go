@@ -0,0 +1,165 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Checks the Actions in the source, or only the one with the given name if not empty,
+// without creating them or looking at any facts.
+// Returns an error only if the source cannot be listed.
+func (self *actionService) Validate(source, name string) (validation domain.SourceValidation, err error) {
+	ctx, span := application.StartSpan(self.ctx, "ActionService.Validate", application.SpanKindInternal)
+	span.SetAttribute("cicero.source", source)
+	defer func() { span.End(err) }()
+
+	evaluationService := self.evaluationService.WithContext(ctx)
+
+	validation.Source = source
+	validation.Actions = []domain.ActionValidation{}
+
+	if validation.Revision, err = evaluationService.ResolveSource(source); err != nil {
+		return
+	}
+
+	names, err := evaluationService.ListActions(source, validation.Revision)
+	if err != nil {
+		return
+	}
+
+	if name != "" {
+		found := false
+		for _, n := range names {
+			if n == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			action := domain.ActionValidation{Name: name}
+			action.Add(domain.ValidationError, "", "There is no action named %q in the source", name)
+			validation.Actions = append(validation.Actions, action)
+			return
+		}
+		names = []string{name}
+	}
+
+	validation.Valid = true
+	for _, name := range names {
+		action := self.WithContext(ctx).(*actionService).validateAction(source, validation.Revision, name)
+		validation.Valid = validation.Valid && action.Valid
+		validation.Actions = append(validation.Actions, action)
+	}
+
+	return
+}
+
+func (self *actionService) validateAction(source, revision, name string) domain.ActionValidation {
+	validation := domain.ActionValidation{Name: name, Valid: true, Problems: []domain.ValidationProblem{}}
+
+	evaluationService := self.evaluationService.WithContext(self.ctx)
+
+	// Matches that do not compile fail here.
+	def, err := evaluationService.EvaluateAction(source, revision, name, uuid.New())
+	if err != nil {
+		validation.Add(domain.ValidationError, "", "Could not evaluate the action: %s", err)
+		return validation
+	}
+
+	chain, err := domain.TransformerChain(self.transformers, def)
+	if err != nil {
+		validation.Add(domain.ValidationError, "", "%s", err)
+	}
+
+	if _, err := def.RunTimeout(); err != nil {
+		validation.Add(domain.ValidationError, "", "%s", err)
+	}
+
+	inputNames := make([]string, 0, len(def.Inputs))
+	for inputName := range def.Inputs {
+		inputNames = append(inputNames, inputName)
+	}
+	sort.Strings(inputNames)
+
+	inputs := map[string]interface{}{}
+	now := time.Now().UTC()
+
+	for _, inputName := range inputNames {
+		input := def.Inputs[inputName]
+		match := input.Match.WithoutInputs()
+
+		if err := match.Validate(); err != nil {
+			validation.Add(domain.ValidationError, inputName, "No fact can ever match: %s", err)
+			continue
+		}
+
+		if input.Schema != "" {
+			if err := input.MatchWithSchema().Validate(); err != nil {
+				validation.Add(domain.ValidationError, inputName, "No fact that conforms to the schema can match: %s", err)
+				continue
+			}
+		}
+
+		if len(collectFieldPaths(match)) == 0 {
+			validation.Add(domain.ValidationWarning, inputName, "The match has no required fields to look up facts by so all facts are scanned")
+		}
+
+		if input.Not {
+			if input.Select != domain.InputDefinitionSelectAll {
+				continue
+			}
+			for _, otherName := range inputNames {
+				other := def.Inputs[otherName]
+				if other.Not || other.Optional {
+					continue
+				}
+				// Every fact that satisfies the other input would also match this one.
+				if otherMatch := other.Match.WithoutInputs(); otherMatch.Validate() == nil && match.Subsume(otherMatch) == nil {
+					validation.Add(domain.ValidationError, inputName, "The action can never run because every fact that satisfies input %q also matches this negated one", otherName)
+				}
+			}
+			continue
+		}
+
+		fact := &domain.Fact{ID: uuid.New(), CreatedAt: now, Value: input.Example()}
+		if matches, err := matchFact(match, fact); err != nil || !matches {
+			valueJson, _ := json.Marshal(fact.Value)
+			validation.Add(domain.ValidationWarning, inputName, "Could not make up a fact that matches for the trial evaluation, using %s anyway", valueJson)
+		}
+		filterFields(&fact.Value, match)
+
+		switch input.Select {
+		case domain.InputDefinitionSelectLatest:
+			inputs[inputName] = fact
+		case domain.InputDefinitionSelectAll:
+			inputs[inputName] = []*domain.Fact{fact}
+		}
+	}
+
+	if !validation.Valid {
+		return validation
+	}
+
+	runDef, err := evaluationService.EvaluateRun(source, revision, name, uuid.New(), inputs)
+	if err != nil {
+		validation.Add(domain.ValidationError, "", "Trial evaluation of the run failed: %s", err)
+		return validation
+	}
+
+	if !runDef.IsDecision() {
+		runId := uuid.New()
+		runIdStr := runId.String()
+		runDef.Job.ID = &runIdStr
+		if err := self.transform(runDef.Job, chain, self.runEnv(&domain.Action{ID: uuid.New(), Name: name}, runId, "")); err != nil {
+			validation.Add(domain.ValidationError, "", "Trial transformation of the job failed: %s", err)
+		}
+	}
+
+	return validation
+}
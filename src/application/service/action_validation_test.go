@@ -0,0 +1,116 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldValidateActions(t *testing.T) {
+	fakeEvaluator(t, "lint", `
+case "$1" in
+	capabilities ) echo '{"protocols": [1]}' ;;
+	list ) echo '["ok", "scan", "pattern", "never", "blocked", "badjob", "limited", "typed", "mistyped"]' ;;
+	eval )
+		if [ "$2" = meta ]; then
+			case "$CICERO_ACTION_NAME" in
+				ok | badjob | limited ) echo '{"meta": {}, "inputs": {"start": {"match": "start: string", "select": "all"}}}' ;;
+				pattern ) echo '{"meta": {}, "inputs": {"start": {"match": "start: =~\"^v\""}}}' ;;
+				scan ) echo '{"meta": {}, "inputs": {"any": {"match": "_"}}}' ;;
+				never ) echo '{"meta": {}, "inputs": {"x": {"match": "x: 1 & 2"}}}' ;;
+				typed ) echo '{"meta": {}, "inputs": {"start": {"match": "start: string", "schema": {"properties": {"start": {"type": "string", "default": "v1"}}}}}}' ;;
+				mistyped ) echo '{"meta": {}, "inputs": {"start": {"match": "start: string", "schema": "start: int"}}}' ;;
+				blocked ) echo '{"meta": {}, "inputs": {"start": {"match": "start: string"}, "stop": {"match": "start: string", "not": true, "select": "all"}}}' ;;
+			esac
+		else
+			case "$CICERO_ACTION_NAME" in
+				ok ) echo '{"output": {}, "job": {"ok": {"group": {"g": {"task": {"t": {"driver": "exec", "config": {"command": "true"}}}}}}}}' ;;
+				limited ) echo '{"output": {}, "job": {"limited": {"group": {"g": {"task": {"t": {"driver": "exec", "resources": {"cpu": 9000}}}}}}}}' ;;
+				badjob ) echo '{"output": {}, "job": {"badjob": {"group": {"g": {"task": {"t": {"driver": "exec", "bogus": 1}}}}}}}' ;;
+				* ) echo '{"output": {"success": {"done": true}}, "job": null}' ;;
+			esac
+		fi
+		;;
+esac
+`)
+
+	logger := zerolog.Nop()
+	evaluationService := NewEvaluationService(nil, nil, nil, Sandbox{Env: DefaultSandboxEnv}, &logger)
+	actionService := NewActionService(nil, nil, nil, evaluationService, nil, []domain.TransformerConfig{
+		{Name: "limits", Type: "resource-limits", Enforce: true, Options: []byte(`{"cpu": 1000}`)},
+	}, &logger)
+
+	src := t.TempDir() + "#lint"
+
+	validation, err := actionService.Validate(src, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, validation.Valid)
+	assert.NotEmpty(t, validation.Revision)
+
+	problems := map[string][]domain.ValidationProblem{}
+	valid := map[string]bool{}
+	for _, action := range validation.Actions {
+		problems[action.Name] = action.Problems
+		valid[action.Name] = action.Valid
+	}
+
+	assert.True(t, valid["ok"])
+	assert.Empty(t, problems["ok"])
+
+	assert.True(t, valid["scan"])
+	if assert.Len(t, problems["scan"], 1) {
+		assert.Equal(t, domain.ValidationWarning, problems["scan"][0].Severity)
+		assert.Equal(t, "any", problems["scan"][0].Input)
+		assert.Contains(t, problems["scan"][0].Message, "all facts are scanned")
+	}
+
+	assert.True(t, valid["pattern"])
+	if assert.Len(t, problems["pattern"], 1) {
+		assert.Equal(t, domain.ValidationWarning, problems["pattern"][0].Severity)
+		assert.Contains(t, problems["pattern"][0].Message, `Could not make up a fact`)
+	}
+
+	assert.True(t, valid["typed"])
+	assert.Empty(t, problems["typed"])
+
+	assert.False(t, valid["mistyped"])
+	if assert.Len(t, problems["mistyped"], 1) {
+		assert.Contains(t, problems["mistyped"][0].Message, "No fact that conforms to the schema can match")
+	}
+
+	assert.False(t, valid["never"])
+	if assert.NotEmpty(t, problems["never"]) {
+		assert.Equal(t, domain.ValidationError, problems["never"][0].Severity)
+	}
+
+	assert.False(t, valid["blocked"])
+	if assert.Len(t, problems["blocked"], 1) {
+		assert.Equal(t, "stop", problems["blocked"][0].Input)
+		assert.Contains(t, problems["blocked"][0].Message, `input "start"`)
+	}
+
+	assert.False(t, valid["badjob"])
+	if assert.Len(t, problems["badjob"], 1) {
+		assert.Contains(t, problems["badjob"][0].Message, "Trial evaluation of the run failed")
+		assert.Contains(t, problems["badjob"][0].Message, "bogus")
+	}
+
+	assert.False(t, valid["limited"])
+	if assert.Len(t, problems["limited"], 1) {
+		assert.Contains(t, problems["limited"][0].Message, "9000 MHz CPU")
+	}
+
+	validation, err = actionService.Validate(src, "ok")
+	assert.NoError(t, err)
+	assert.True(t, validation.Valid)
+	assert.Len(t, validation.Actions, 1)
+
+	validation, err = actionService.Validate(src, "missing")
+	assert.NoError(t, err)
+	assert.False(t, validation.Valid)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+	"github.com/input-output-hk/cicero/src/infrastructure/persistence"
+)
+
+// Prefixes of tokens so that they are easy to recognize, for example by secret scanners.
+const (
+	ApiTokenPrefix = "cicero_"
+	RunTokenPrefix = ApiTokenPrefix + "run_"
+)
+
+// Environment variable that holds the Run token in every task.
+const RunTokenEnv = "CICERO_RUN_TOKEN"
+
+type ApiTokenService interface {
+	WithQuerier(config.PgxIface) ApiTokenService
+
+	GetAll() ([]*domain.ApiToken, error)
+	// Returns the secret token. It cannot be retrieved again later.
+	Create(name string, role domain.Role) (domain.ApiToken, string, error)
+	Delete(uuid.UUID) error
+	Authenticate(string) (domain.ApiToken, error)
+}
+
+type apiTokenService struct {
+	logger             zerolog.Logger
+	apiTokenRepository repository.ApiTokenRepository
+}
+
+func NewApiTokenService(db config.PgxIface, logger *zerolog.Logger) ApiTokenService {
+	return &apiTokenService{
+		logger:             logger.With().Str("component", "ApiTokenService").Logger(),
+		apiTokenRepository: persistence.NewApiTokenRepository(db),
+	}
+}
+
+func (self *apiTokenService) WithQuerier(querier config.PgxIface) ApiTokenService {
+	return &apiTokenService{
+		logger:             self.logger,
+		apiTokenRepository: self.apiTokenRepository.WithQuerier(querier),
+	}
+}
+
+func (self *apiTokenService) GetAll() ([]*domain.ApiToken, error) {
+	self.logger.Debug().Msg("Getting all API tokens")
+	tokens, err := self.apiTokenRepository.GetAll()
+	return tokens, errors.WithMessage(err, "Could not select API tokens")
+}
+
+func (self *apiTokenService) Create(name string, role domain.Role) (domain.ApiToken, string, error) {
+	self.logger.Debug().Str("name", name).Str("role", role.String()).Msg("Creating API token")
+
+	str, err := generateToken(ApiTokenPrefix)
+	if err != nil {
+		return domain.ApiToken{}, "", errors.WithMessage(err, "Could not generate API token")
+	}
+
+	token := domain.ApiToken{Name: name, Role: role}
+	if err := self.apiTokenRepository.Save(&token, hashToken(str)); err != nil {
+		return token, "", errors.WithMessagef(err, "Could not insert API token %q", name)
+	}
+
+	self.logger.Info().Str("name", name).Str("role", role.String()).Msg("Created API token")
+	return token, str, nil
+}
+
+func (self *apiTokenService) Delete(id uuid.UUID) error {
+	self.logger.Debug().Str("id", id.String()).Msg("Deleting API token")
+	return errors.WithMessagef(self.apiTokenRepository.Delete(id), "Could not delete API token %q", id)
+}
+
+func (self *apiTokenService) Authenticate(str string) (domain.ApiToken, error) {
+	if !strings.HasPrefix(str, ApiTokenPrefix) || strings.HasPrefix(str, RunTokenPrefix) {
+		return domain.ApiToken{}, errors.New("Malformed API token")
+	}
+
+	token, err := self.apiTokenRepository.GetByHash(hashToken(str))
+	if err != nil {
+		return token, errors.WithMessage(err, "Could not find API token")
+	}
+
+	if err := self.apiTokenRepository.Touch(token.ID); err != nil {
+		// Not worth failing the request over.
+		self.logger.Err(err).Str("name", token.Name).Msg("Could not update last use of API token")
+	}
+
+	return token, nil
+}
+
+func generateToken(prefix string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// The tokens are random with high entropy
+// so a fast unsalted hash is sufficient.
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+	"github.com/input-output-hk/cicero/src/infrastructure/persistence"
+)
+
+type AuditService interface {
+	WithQuerier(config.PgxIface) AuditService
+
+	Get(repository.AuditEventFilter, *repository.Page) ([]*domain.AuditEvent, error)
+}
+
+type auditService struct {
+	logger               zerolog.Logger
+	auditEventRepository repository.AuditEventRepository
+}
+
+func NewAuditService(db config.PgxIface, logger *zerolog.Logger) AuditService {
+	return &auditService{
+		logger:               logger.With().Str("component", "AuditService").Logger(),
+		auditEventRepository: persistence.NewAuditEventRepository(db),
+	}
+}
+
+func (self *auditService) WithQuerier(querier config.PgxIface) AuditService {
+	return &auditService{
+		logger:               self.logger,
+		auditEventRepository: self.auditEventRepository.WithQuerier(querier),
+	}
+}
+
+func (self *auditService) Get(filter repository.AuditEventFilter, page *repository.Page) (events []*domain.AuditEvent, err error) {
+	self.logger.Debug().Interface("filter", filter).Int("offset", page.Offset).Int("limit", page.Limit).Msg("Getting audit events")
+	events, err = self.auditEventRepository.Get(filter, page)
+	err = errors.WithMessagef(err, "Could not select audit events with offset %d and limit %d", page.Offset, page.Limit)
+	return
+}
+
+// Records a change made by the given actor.
+// Should be called with a repository in the same transaction as the change.
+func audit(auditEventRepository repository.AuditEventRepository, actor *domain.Actor, eventType domain.AuditEventType, subjectId uuid.UUID, before, after interface{}) error {
+	event := domain.NewAuditEvent(actor, eventType, subjectId, before, after)
+	return errors.WithMessagef(auditEventRepository.Save(&event), "Could not record audit event %s for %q", eventType, subjectId)
+}
@@ -3,30 +3,54 @@ package service
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/adrg/xdg"
+	"github.com/georgysavva/scany/pgxscan"
 	"github.com/google/uuid"
-	getter "github.com/hashicorp/go-getter/v2"
 	"github.com/hashicorp/nomad/jobspec2"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 
+	"github.com/input-output-hk/cicero/src/application"
 	"github.com/input-output-hk/cicero/src/config"
 	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+	"github.com/input-output-hk/cicero/src/infrastructure/persistence"
 )
 
 type EvaluationService interface {
-	ListActions(src string) ([]string, error)
-	EvaluateAction(src, name string, id uuid.UUID) (domain.ActionDefinition, error)
-	EvaluateRun(src, name string, id uuid.UUID, inputs map[string]interface{}) (domain.RunDefinition, error)
+	// Returns a copy that continues the trace in the given context
+	// and aborts work in progress when it is done.
+	WithContext(context.Context) EvaluationService
+
+	// Downloads the source and returns the revision it is at.
+	// The source is evaluated the same at that revision
+	// even if it is downloaded again, like a floating git ref.
+	ResolveSource(src string) (revision string, err error)
+	// Deletes the downloaded sources that were not evaluated since the given time.
+	PruneSources(before time.Time) (deleted int, err error)
+	// Deletes evaluator outputs that were cached before the given time.
+	PruneCache(before time.Time) (deleted int64, err error)
+
+	// The following resolve the source again if the revision is empty.
+
+	ListActions(src, revision string) ([]string, error)
+	EvaluateAction(src, revision, name string, id uuid.UUID) (domain.ActionDefinition, error)
+	EvaluateRun(src, revision, name string, id uuid.UUID, inputs map[string]interface{}) (domain.RunDefinition, error)
 }
 
 func parseSource(src string) (fetchUrl *url.URL, evaluator string, err error) {
@@ -46,19 +70,88 @@ type evaluationService struct {
 	Evaluators   []string // Default evaluators. Will be tried in order if none is given for a source.
 	Transformers []string
 	logger       zerolog.Logger
+	ctx          context.Context
+	sandbox      Sandbox
+	// Nil if evaluator outputs are not cached.
+	evaluationCacheRepository repository.EvaluationCacheRepository
 }
 
-func NewEvaluationService(evaluators, transformers []string, logger *zerolog.Logger) EvaluationService {
-	return &evaluationService{
+// Caches evaluator outputs in the database unless it is nil.
+func NewEvaluationService(db config.PgxIface, evaluators, transformers []string, sandbox Sandbox, logger *zerolog.Logger) EvaluationService {
+	e := &evaluationService{
 		Evaluators:   evaluators,
 		Transformers: transformers,
+		sandbox:      sandbox,
 		logger:       logger.With().Str("component", "EvaluationService").Logger(),
+		ctx:          context.Background(),
 	}
+	if db != nil {
+		e.evaluationCacheRepository = persistence.NewEvaluationCacheRepository(db)
+	}
+	return e
+}
+
+func (e *evaluationService) WithContext(ctx context.Context) EvaluationService {
+	clone := *e
+	clone.ctx = ctx
+	return &clone
+}
+
+// The version of the evaluator protocol that Cicero speaks.
+// Evaluators without the `capabilities` command speak version 0
+// in which failures are only told by the exit status.
+const EvaluatorProtocol = 1
+
+// Tells evaluators which version of the protocol to speak.
+const EvaluatorProtocolEnv = "CICERO_EVALUATOR_PROTOCOL"
+
+// What the `capabilities` command of an evaluator prints.
+type EvaluatorCapabilities struct {
+	// The versions of the evaluator protocol the evaluator speaks.
+	Protocols []int `json:"protocols"`
+}
+
+type SourcePosition struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
 }
 
-// Evaluation failed due to a faulty action definition or transformer output.
+func (p SourcePosition) String() string {
+	str := p.File
+	if p.Line > 0 {
+		str += fmt.Sprintf(":%d", p.Line)
+		if p.Column > 0 {
+			str += fmt.Sprintf(":%d", p.Column)
+		}
+	}
+	return str
+}
+
+// What evaluators print since protocol version 1 when they fail.
+type EvaluatorError struct {
+	Message  string          `json:"message"`
+	Position *SourcePosition `json:"position,omitempty"`
+	// Whether evaluating again may succeed, for example after a network failure.
+	// Otherwise the action definition is at fault.
+	Retryable bool `json:"retryable"`
+}
+
+// Evaluation failed due to a faulty action definition or transformer output
+// or, if it is retryable, a temporary failure of the evaluator.
 type EvaluationError struct {
-	err error
+	EvaluatorError
+	Evaluator string `json:"evaluator,omitempty"`
+	err       error
+	// Whether the evaluator failed without printing an EvaluatorError.
+	unstructured bool
+}
+
+func newEvaluationError(err error) EvaluationError {
+	return EvaluationError{
+		EvaluatorError: EvaluatorError{Message: err.Error()},
+		err:            err,
+	}
 }
 
 func (e EvaluationError) Error() string {
@@ -69,63 +162,168 @@ func (e EvaluationError) Unwrap() error {
 	return e.err
 }
 
-func (e *evaluationService) evaluate(src string, args, extraEnv []string) ([]byte, error) {
-	fetchUrl, evaluator, err := parseSource(src)
-	if err != nil {
-		return nil, err
-	}
-
+func (e *evaluationService) sources() sourceCache {
 	cacheDir := config.GetenvStr("CICERO_CACHE_DIR")
 	if cacheDir == "" {
-		e.logger.Debug().Err(err).Msg("Falling back to XDG cache directory")
+		e.logger.Debug().Msg("Falling back to XDG cache directory")
 		cacheDir = xdg.CacheHome + "/cicero"
 	}
-	cacheDir += "/sources"
+	return sourceCache{dir: filepath.Join(cacheDir, "sources")}
+}
 
-	dst, err := filepath.Abs(cacheDir + "/" + base64.RawURLEncoding.EncodeToString([]byte(src)))
+func (e *evaluationService) ResolveSource(src string) (string, error) {
+	fetchUrl, _, err := parseSource(src)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return e.sources().resolve(e.ctx, fetchUrl.String())
+}
+
+func (e *evaluationService) PruneSources(before time.Time) (int, error) {
+	return e.sources().prune(before)
+}
+
+func (e *evaluationService) PruneCache(before time.Time) (deleted int64, err error) {
+	if e.evaluationCacheRepository == nil {
+		return
+	}
+	deleted, err = e.evaluationCacheRepository.Prune(before)
+	err = errors.WithMessagef(err, "Could not delete evaluator outputs cached before %s", before)
+	return
+}
+
+// What an evaluator printed and which one it was.
+type evaluation struct {
+	output    []byte
+	evaluator string
+	// Of the source.
+	revision string
+}
+
+// Only caches the output if told to, which is not worth it
+// for commands whose environment is different almost every time.
+func (e *evaluationService) evaluate(src, revision string, args, extraEnv []string, cache bool) (result evaluation, err error) {
+	ctx, span := application.StartSpan(e.ctx, "EvaluationService.evaluate", application.SpanKindInternal)
+	span.SetAttribute("cicero.source", src)
+	span.SetAttribute("cicero.evaluator.command", args[0])
+	defer func() { span.End(err) }()
+
+	fetchUrl, evaluator, err := parseSource(src)
+	if err != nil {
+		return
 	}
 
-	result, err := getter.GetAny(context.Background(), dst, fetchUrl.String())
+	sources := e.sources()
+
+	if revision == "" {
+		if revision, err = sources.resolve(ctx, fetchUrl.String()); err != nil {
+			return
+		}
+	}
+	span.SetAttribute("cicero.source.revision", revision)
+	result.revision = revision
+
+	evaluators := e.Evaluators
+	if evaluator != "" {
+		evaluators = []string{evaluator}
+	}
+
+	var cacheKey []byte
+	if cache {
+		cacheKey, err = e.cacheKey(src, revision, evaluators, args, extraEnv)
+	}
 	if err != nil {
-		return nil, err
+		e.logger.Warn().Err(err).Str("source", src).Msg("Could not compute evaluation cache key, not using the cache")
+	} else if cacheKey != nil {
+		if output, evaluator, err := e.evaluationCacheRepository.Get(cacheKey); err == nil {
+			application.MetricEvaluationCache.WithLabelValues(args[0], "hit").Inc()
+			span.SetAttribute("cicero.evaluation.cached", "true")
+			e.logger.Debug().Str("source", src).Str("revision", revision).Strs("args", args).Msg("Using cached evaluator output")
+			result.output = output
+			result.evaluator = evaluator
+			return result, nil
+		} else if !pgxscan.NotFound(err) {
+			e.logger.Warn().Err(err).Msg("Could not look up evaluator output in the cache")
+		}
+		application.MetricEvaluationCache.WithLabelValues(args[0], "miss").Inc()
+
+		defer func() {
+			if err != nil {
+				return
+			}
+			if err := e.evaluationCacheRepository.Save(cacheKey, src, revision, result.evaluator, result.output); err != nil {
+				e.logger.Warn().Err(err).Msg("Could not cache evaluator output")
+			}
+		}()
 	}
-	if result.Dst != dst {
-		return nil, errors.WithMessage(err, "go-getter did not download to the given directory. This should never happen™")
+
+	dst, release, err := sources.get(ctx, fetchUrl.String(), revision)
+	if err != nil {
+		return
 	}
+	defer release()
+
+	tryEval := func(evaluator string) (_ []byte, err error) {
+		ctx, span := application.StartSpan(ctx, "cicero-evaluator-"+evaluator, application.SpanKindClient)
+		span.SetAttribute("cicero.evaluator", evaluator)
+		span.SetAttribute("cicero.evaluator.command", args[0])
+		defer func() { span.End(err) }()
+
+		protocol, err := e.protocol(ctx, evaluator)
+		if err != nil {
+			return nil, err
+		}
+		span.SetAttribute("cicero.evaluator.protocol", strconv.Itoa(protocol))
 
-	tryEval := func(evaluator string) ([]byte, error) {
-		cmd := exec.Command("cicero-evaluator-"+evaluator, args...)
 		cmdEnv := append(extraEnv, "CICERO_ACTION_SRC="+dst) //nolint:gocritic // false positive
-		cmd.Env = append(os.Environ(), cmdEnv...)            //nolint:gocritic // false positive
+		if protocol > 0 {
+			cmdEnv = append(cmdEnv, EvaluatorProtocolEnv+"="+strconv.Itoa(protocol))
+		}
 
 		e.logger.Debug().
-			Strs("command", cmd.Args).
+			Str("evaluator", evaluator).
+			Strs("args", args).
 			Strs("environment", cmdEnv).
 			Msg("Running evaluator")
 
-		if output, err := cmd.Output(); err != nil {
-			message := "Failed to evaluate"
+		timer := prometheus.NewTimer(application.MetricEvaluations.WithLabelValues(evaluator, args[0]))
+		output, stderr, err := e.sandbox.run(
+			ctx, "cicero-evaluator-"+evaluator, args,
+			e.sandbox.environ(append(cmdEnv, application.TraceparentEnv+"="+application.Traceparent(ctx))),
+			nil,
+		)
+		timer.ObserveDuration()
+
+		if err != nil {
+			application.MetricEvaluationFailures.WithLabelValues(evaluator, args[0]).Inc()
 
 			var errExit *exec.ExitError
-			if errors.As(err, &errExit) {
-				message += fmt.Sprintf("\nStdout: %s\nStderr: %s", output, errExit.Stderr)
-				err = EvaluationError{err: errors.WithMessage(err, message)}
+			var errTimeout TimeoutError
+			switch {
+			case errors.As(err, &errTimeout):
+				// Not retryable as the action may well never finish.
+				err = newEvaluationError(errors.WithMessagef(err, "Evaluator %q did not finish in time", evaluator))
+			case errors.As(err, &errExit):
+				err = parseEvaluatorError(protocol, output, stderr, errExit)
+			}
+			if evalErr, ok := err.(EvaluationError); ok {
+				evalErr.Evaluator = evaluator
+				err = evalErr
 			}
 
 			return nil, err
-		} else {
-			return output, err
 		}
+
+		return output, nil
 	}
 
 	if evaluator != "" {
-		if output, err := tryEval(evaluator); err != nil {
-			return nil, errors.WithMessagef(err, "Evaluator %q specified in source failed", evaluator)
-		} else {
-			return output, nil
+		if result.output, err = tryEval(evaluator); err != nil {
+			err = errors.WithMessagef(err, "Evaluator %q specified in source failed", evaluator)
+			return
 		}
+		result.evaluator = evaluator
+		return
 	} else {
 		e.logger.Debug().Msg("No evaluator given in source, trying all")
 		var evalErr error
@@ -138,34 +336,181 @@ func (e *evaluationService) evaluate(src string, args, extraEnv []string) ([]byt
 				format += "Evaluator %q failed: %w"
 				evalErr = fmt.Errorf(format, evaluator, err)
 			} else {
-				return output, nil
+				result.output = output
+				result.evaluator = evaluator
+				return result, nil
 			}
 		}
 		e.logger.Err(evalErr).Msg("No evaluator succeeded.")
-		return nil, errors.WithMessage(evalErr, "No evaluator succeeded.")
+		err = errors.WithMessage(evalErr, "No evaluator succeeded.")
+		return
 	}
 }
 
-func (e *evaluationService) EvaluateAction(src, name string, id uuid.UUID) (domain.ActionDefinition, error) {
+// Identifies what the evaluators would output for the source at the given revision.
+// Returns a nil key if outputs are not cached.
+func (e *evaluationService) cacheKey(src, revision string, evaluators, args, extraEnv []string) ([]byte, error) {
+	if e.evaluationCacheRepository == nil {
+		return nil, nil
+	}
+
+	// Upgrading an evaluator usually changes its path, like in the Nix store.
+	binaries := make([]string, len(evaluators))
+	for i, evaluator := range evaluators {
+		binaries[i] = "cicero-evaluator-" + evaluator
+		if path, err := exec.LookPath(binaries[i]); err == nil {
+			binaries[i] = path
+		}
+	}
+
+	hash := sha256.New()
+	if err := json.NewEncoder(hash).Encode([]interface{}{src, revision, binaries, args, extraEnv}); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// Identifies the content of a downloaded source: the commit if it is
+// a git work tree without changes to tracked or untracked files,
+// otherwise a hash of all its files.
+func sourceRevision(ctx context.Context, dir string) (string, error) {
+	// Do not mistake a repository that the directory is in for the source.
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		if status, err := exec.CommandContext(ctx, "git", "-C", dir, "status", "--porcelain").Output(); err == nil && len(status) == 0 {
+			if commit, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output(); err == nil {
+				return "git:" + strings.TrimSpace(string(commit)), nil
+			}
+		}
+	}
+
+	// Local sources are symlinked by go-getter.
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00", rel, info.Mode())
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%s\x00", target)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(hash, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Asks the evaluator which protocol versions it speaks
+// and returns the latest one that Cicero speaks as well.
+func (e *evaluationService) protocol(ctx context.Context, evaluator string) (int, error) {
+	output, _, err := e.sandbox.run(ctx, "cicero-evaluator-"+evaluator, []string{"capabilities"}, e.sandbox.environ(nil), nil)
+	if err != nil {
+		var errExit *exec.ExitError
+		if errors.As(err, &errExit) {
+			// Evaluators that predate the `capabilities` command fail like for any unknown command.
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var capabilities EvaluatorCapabilities
+	if err := json.Unmarshal(output, &capabilities); err != nil {
+		return 0, errors.WithMessagef(err, "While unmarshaling capabilities of evaluator %q:\n%s", evaluator, string(output))
+	}
+
+	protocol := -1
+	for _, version := range capabilities.Protocols {
+		if version <= EvaluatorProtocol && version > protocol {
+			protocol = version
+		}
+	}
+	if protocol < 0 {
+		return 0, fmt.Errorf("Evaluator %q speaks none of the protocol versions up to %d, only %v", evaluator, EvaluatorProtocol, capabilities.Protocols)
+	}
+
+	return protocol, nil
+}
+
+// Turns the failure of an evaluator into an EvaluationError,
+// reading the EvaluatorError it printed if its protocol allows for that.
+func parseEvaluatorError(protocol int, output, stderr []byte, errExit *exec.ExitError) error {
+	if protocol >= 1 {
+		var evaluatorErr EvaluatorError
+		if err := json.Unmarshal(output, &evaluatorErr); err == nil && evaluatorErr.Message != "" {
+			message := evaluatorErr.Message
+			if evaluatorErr.Position != nil {
+				message = evaluatorErr.Position.String() + ": " + message
+			}
+			return EvaluationError{
+				EvaluatorError: evaluatorErr,
+				err:            errors.WithMessage(errExit, "Failed to evaluate: "+message),
+			}
+		}
+	}
+
+	evalErr := newEvaluationError(errors.WithMessage(errExit, fmt.Sprintf("Failed to evaluate\nStdout: %s\nStderr: %s", output, stderr)))
+	evalErr.unstructured = true
+	return evalErr
+}
+
+func (e *evaluationService) EvaluateAction(src, revision, name string, id uuid.UUID) (domain.ActionDefinition, error) {
 	var def domain.ActionDefinition
 
-	if output, err := e.evaluate(src,
+	if output, err := e.evaluate(src, revision,
 		[]string{"eval", "meta", "inputs"},
 		[]string{
 			"CICERO_ACTION_NAME=" + name,
 			"CICERO_ACTION_ID=" + id.String(),
 		},
+		true,
 	); err != nil {
 		return def, err
-	} else if err := json.Unmarshal(output, &def); err != nil {
-		e.logger.Err(err).Str("output", string(output)).Send()
+	} else if err := json.Unmarshal(output.output, &def); err != nil {
+		e.logger.Err(err).Str("output", string(output.output)).Send()
 		return def, errors.WithMessage(err, "While unmarshaling evaluator output")
 	}
 
 	return def, nil
 }
 
-func (e *evaluationService) EvaluateRun(src, name string, id uuid.UUID, inputs map[string]interface{}) (domain.RunDefinition, error) {
+func (e *evaluationService) EvaluateRun(src, revision, name string, id uuid.UUID, inputs map[string]interface{}) (domain.RunDefinition, error) {
 	var def domain.RunDefinition
 
 	inputsJson, err := json.Marshal(inputs)
@@ -179,12 +524,16 @@ func (e *evaluationService) EvaluateRun(src, name string, id uuid.UUID, inputs m
 		"CICERO_ACTION_INPUTS=" + string(inputsJson),
 	}
 
-	output, err := e.evaluate(src, []string{"eval", "output", "job"}, extraEnv)
+	// Not cached as the inputs are different for every Run.
+	evaluation, err := e.evaluate(src, revision, []string{"eval", "output", "job"}, extraEnv, false)
 	if err != nil {
 		return def, err
 	}
+	def.Evaluator = evaluation.evaluator
+	def.Revision = evaluation.revision
+	def.Inputs = inputs
 
-	output, err = e.transform(output, extraEnv)
+	output, err := e.transform(evaluation.output, extraEnv)
 	if err != nil {
 		return def, err
 	}
@@ -224,33 +573,20 @@ func (e *evaluationService) EvaluateRun(src, name string, id uuid.UUID, inputs m
 
 func (e *evaluationService) transform(output []byte, extraEnv []string) ([]byte, error) {
 	for _, transformer := range e.Transformers {
-		cmd := exec.Command(transformer)
-		cmd.Env = append(os.Environ(), extraEnv...) //nolint:gocritic // false positive
-
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			return nil, err
-		}
-		if _, err := io.Copy(stdin, bytes.NewReader(output)); err != nil {
-			return nil, err
-		}
-		if err := stdin.Close(); err != nil {
-			return nil, err
-		}
-
 		e.logger.Debug().
-			Strs("command", cmd.Args).
 			Strs("environment", extraEnv).
 			Str("transformer", transformer).
 			Msg("Running transformer")
 
-		if transformedOutput, err := cmd.Output(); err != nil {
-			message := "Failed to transform"
-
+		if transformedOutput, stderr, err := e.sandbox.run(e.ctx, transformer, nil, e.sandbox.environ(extraEnv), output); err != nil {
 			var errExit *exec.ExitError
-			if errors.As(err, &errExit) {
-				message += fmt.Sprintf("\nStdout: %s\nStderr: %s", transformedOutput, errExit.Stderr)
-				err = EvaluationError{err: errors.WithMessage(err, message)}
+			var errTimeout TimeoutError
+			switch {
+			case errors.As(err, &errTimeout):
+				err = newEvaluationError(errors.WithMessagef(err, "Transformer %q did not finish in time", transformer))
+			case errors.As(err, &errExit):
+				message := fmt.Sprintf("Failed to transform\nStdout: %s\nStderr: %s", transformedOutput, stderr)
+				err = newEvaluationError(errors.WithMessage(err, message))
 			}
 
 			return nil, err
@@ -262,11 +598,12 @@ func (e *evaluationService) transform(output []byte, extraEnv []string) ([]byte,
 	return output, nil
 }
 
-func (e *evaluationService) ListActions(src string) ([]string, error) {
-	output, err := e.evaluate(src, []string{"list"}, nil)
+func (e *evaluationService) ListActions(src, revision string) ([]string, error) {
+	evaluation, err := e.evaluate(src, revision, []string{"list"}, nil, true)
 	if err != nil {
 		return nil, err
 	}
+	output := evaluation.output
 
 	var names []string
 	if err := json.Unmarshal(output, &names); err != nil {
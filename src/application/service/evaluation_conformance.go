@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Checks that the evaluator given in the source's fragment
+// speaks the current version of the evaluator protocol
+// by listing and evaluating the actions in the source.
+// Returns the name of each check with the error it failed with, if any.
+func CheckEvaluatorConformance(ctx context.Context, src string, logger *zerolog.Logger) (checks []string, errs []error) {
+	check := func(name string, err error) {
+		checks = append(checks, name)
+		errs = append(errs, err)
+	}
+
+	_, evaluator, err := parseSource(src)
+	if err != nil {
+		check("parse source", err)
+		return
+	}
+	if evaluator == "" {
+		check("parse source", errors.New("The source must name the evaluator in its fragment like `#nix`"))
+		return
+	}
+
+	e := NewEvaluationService(nil, nil, nil, Sandbox{Timeout: 5 * time.Minute, Env: DefaultSandboxEnv}, logger).WithContext(ctx).(*evaluationService)
+
+	protocol, err := e.protocol(ctx, evaluator)
+	if err == nil && protocol != EvaluatorProtocol {
+		err = fmt.Errorf("Speaks protocol version %d instead of %d", protocol, EvaluatorProtocol)
+	}
+	check("capabilities", err)
+	if err != nil {
+		return
+	}
+
+	names, err := e.ListActions(src, "")
+	check("list", err)
+
+	for _, name := range names {
+		def, err := e.EvaluateAction(src, "", name, uuid.New())
+		if err == nil {
+			for inputName, input := range def.Inputs {
+				if input.Match == "" {
+					err = fmt.Errorf("Input %q has no match", inputName)
+					break
+				}
+			}
+		}
+		check(fmt.Sprintf("eval meta inputs of %q", name), err)
+	}
+
+	missing := "cicero-conformance-" + uuid.NewString()
+	_, err = e.EvaluateAction(src, "", missing, uuid.New())
+	var evalErr EvaluationError
+	switch {
+	case err == nil:
+		err = fmt.Errorf("Evaluating the missing action %q succeeded", missing)
+	case !errors.As(err, &evalErr):
+		err = errors.WithMessage(err, "Evaluating a missing action failed with something else than an evaluation error")
+	case evalErr.unstructured:
+		err = errors.WithMessage(evalErr, "Evaluating a missing action failed without an error object on stdout")
+	case evalErr.Retryable:
+		err = errors.New("Evaluating a missing action failed with a retryable error")
+	default:
+		err = nil
+	}
+	check("eval a missing action", err)
+
+	return
+}
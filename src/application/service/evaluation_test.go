@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+// Puts an evaluator with the given shell script on PATH.
+func fakeEvaluator(t *testing.T, name, script string) {
+	bin := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(bin, "cicero-evaluator-"+name), []byte("#!/bin/sh\n"+script), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("CICERO_CACHE_DIR", t.TempDir())
+}
+
+// Speaks protocol version 1 and has one action named "ping".
+const conformingEvaluator = `
+case "$1" in
+	capabilities ) echo '{"protocols": [0, 1, 2]}' ;;
+	list ) echo '["ping"]' ;;
+	eval )
+		if [ "$CICERO_ACTION_NAME" = ping ]; then
+			echo '{"meta": {}, "inputs": {"start": {"match": "start: true"}}}'
+		else
+			echo '{"message": "no action named '"$CICERO_ACTION_NAME"'", "position": {"file": "actions.cue", "line": 3, "column": 5}, "retryable": false}'
+			echo "protocol $CICERO_EVALUATOR_PROTOCOL" >&2
+			exit 1
+		fi
+		;;
+	* ) exit 1 ;;
+esac
+`
+
+func TestShouldAbortEvaluatorWhenContextIsDone(t *testing.T) {
+	fakeEvaluator(t, "sleep", `
+case "$1" in
+	capabilities ) echo '{"protocols": [1]}' ;;
+	* ) exec sleep 60 ;;
+esac
+`)
+
+	logger := zerolog.Nop()
+	evaluationService := NewEvaluationService(nil, nil, nil, Sandbox{Env: DefaultSandboxEnv}, &logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := evaluationService.WithContext(ctx).ListActions(t.TempDir()+"#sleep", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "signal: killed")
+	}
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestShouldReadStructuredEvaluatorErrors(t *testing.T) {
+	fakeEvaluator(t, "fake", conformingEvaluator)
+
+	logger := zerolog.Nop()
+	_, err := NewEvaluationService(nil, nil, nil, Sandbox{Env: DefaultSandboxEnv}, &logger).EvaluateAction(t.TempDir()+"#fake", "", "pong", uuid.New())
+
+	var evalErr EvaluationError
+	if assert.ErrorAs(t, err, &evalErr) {
+		assert.Equal(t, "fake", evalErr.Evaluator)
+		assert.Equal(t, "no action named pong", evalErr.Message)
+		assert.Equal(t, &SourcePosition{File: "actions.cue", Line: 3, Column: 5}, evalErr.Position)
+		assert.False(t, evalErr.Retryable)
+		assert.False(t, evalErr.unstructured)
+		assert.Contains(t, err.Error(), "actions.cue:3:5: no action named pong")
+	}
+}
+
+func TestShouldSpeakProtocol0WithoutCapabilities(t *testing.T) {
+	fakeEvaluator(t, "legacy", `
+case "$1" in
+	list ) echo "protocol ${CICERO_EVALUATOR_PROTOCOL:-none}"; exit 1 ;;
+	* ) exit 1 ;;
+esac
+`)
+
+	logger := zerolog.Nop()
+	_, err := NewEvaluationService(nil, nil, nil, Sandbox{Env: DefaultSandboxEnv}, &logger).ListActions(t.TempDir()+"#legacy", "")
+
+	var evalErr EvaluationError
+	if assert.ErrorAs(t, err, &evalErr) {
+		assert.True(t, evalErr.unstructured)
+		assert.Contains(t, evalErr.Message, "Stdout: protocol none")
+	}
+}
+
+func TestShouldCheckEvaluatorConformance(t *testing.T) {
+	fakeEvaluator(t, "fake", conformingEvaluator)
+
+	logger := zerolog.Nop()
+	checks, errs := CheckEvaluatorConformance(context.Background(), t.TempDir()+"#fake", &logger)
+	assert.Equal(t, []string{"capabilities", "list", `eval meta inputs of "ping"`, "eval a missing action"}, checks)
+	for i, err := range errs {
+		assert.NoError(t, err, checks[i])
+	}
+
+	fakeEvaluator(t, "unstructured", `
+case "$1" in
+	capabilities ) echo '{"protocols": [1]}' ;;
+	list ) echo '[]' ;;
+	* ) echo 'something went wrong'; exit 1 ;;
+esac
+`)
+
+	checks, errs = CheckEvaluatorConformance(context.Background(), t.TempDir()+"#unstructured", &logger)
+	assert.Equal(t, []string{"capabilities", "list", "eval a missing action"}, checks)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Error(t, errs[2])
+
+	_, errs = CheckEvaluatorConformance(context.Background(), t.TempDir(), &logger)
+	assert.Error(t, errs[0])
+}
+
+type memoryEvaluationCacheRepository struct {
+	outputs    map[string][]byte
+	evaluators map[string]string
+}
+
+func (self *memoryEvaluationCacheRepository) WithQuerier(config.PgxIface) repository.EvaluationCacheRepository {
+	return self
+}
+
+func (self *memoryEvaluationCacheRepository) Get(key []byte) ([]byte, string, error) {
+	if output, found := self.outputs[string(key)]; found {
+		return output, self.evaluators[string(key)], nil
+	}
+	return nil, "", pgx.ErrNoRows
+}
+
+func (self *memoryEvaluationCacheRepository) Prune(time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (self *memoryEvaluationCacheRepository) Save(key []byte, source, revision, evaluator string, output []byte) error {
+	self.outputs[string(key)] = output
+	self.evaluators[string(key)] = evaluator
+	return nil
+}
+
+func TestShouldCacheEvaluatorOutputBySourceRevision(t *testing.T) {
+	invocations := filepath.Join(t.TempDir(), "invocations")
+	fakeEvaluator(t, "counting", `
+case "$1" in
+	capabilities ) echo '{"protocols": [1]}' ;;
+	* )
+		echo >> `+invocations+`
+		echo '["ping"]'
+		;;
+esac
+`)
+	countInvocations := func() int {
+		content, err := os.ReadFile(invocations)
+		assert.NoError(t, err)
+		return len(content)
+	}
+
+	src := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "actions.cue"), []byte("a"), 0o644))
+
+	logger := zerolog.Nop()
+	evaluationService := NewEvaluationService(nil, nil, nil, Sandbox{Env: DefaultSandboxEnv}, &logger).(*evaluationService)
+	evaluationService.evaluationCacheRepository = &memoryEvaluationCacheRepository{
+		outputs:    map[string][]byte{},
+		evaluators: map[string]string{},
+	}
+
+	for i := 0; i < 2; i++ {
+		names, err := evaluationService.ListActions(src+"#counting", "")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ping"}, names)
+		assert.Equal(t, 1, countInvocations())
+	}
+
+	_, err := evaluationService.EvaluateAction(src+"#counting", "", "ping", uuid.New())
+	assert.Error(t, err, "the fake evaluator does not print an action definition")
+	assert.Equal(t, 2, countInvocations())
+
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "actions.cue"), []byte("b"), 0o644))
+	_, err = evaluationService.ListActions(src+"#counting", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, countInvocations())
+}
+
+func TestShouldRecordHowRunWasEvaluated(t *testing.T) {
+	fakeEvaluator(t, "broken", `exit 1`)
+	fakeEvaluator(t, "decision", `
+case "$1" in
+	capabilities ) echo '{"protocols": [1]}' ;;
+	eval ) echo '{"output": {"success": {"ok": true}}, "job": null}' ;;
+esac
+`)
+
+	logger := zerolog.Nop()
+	evaluationService := NewEvaluationService(nil, []string{"broken", "decision"}, nil, Sandbox{Env: DefaultSandboxEnv}, &logger).(*evaluationService)
+	cache := &memoryEvaluationCacheRepository{
+		outputs:    map[string][]byte{},
+		evaluators: map[string]string{},
+	}
+	evaluationService.evaluationCacheRepository = cache
+
+	inputs := map[string]interface{}{"start": map[string]interface{}{"value": "now"}}
+	src := t.TempDir()
+
+	def, err := evaluationService.EvaluateRun(src, "", "decide", uuid.New(), inputs)
+	if assert.NoError(t, err) {
+		assert.True(t, def.IsDecision())
+		assert.Equal(t, "decision", def.Evaluator)
+		assert.True(t, strings.HasPrefix(def.Revision, "sha256:"), def.Revision)
+		assert.Equal(t, inputs, def.Inputs)
+	}
+
+	// Inputs differ for every Run so their outputs are not worth caching.
+	assert.Empty(t, cache.outputs)
+}
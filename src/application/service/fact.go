@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 
+	"github.com/input-output-hk/cicero/src/application"
 	"github.com/input-output-hk/cicero/src/config"
 	"github.com/input-output-hk/cicero/src/domain"
 	"github.com/input-output-hk/cicero/src/domain/repository"
@@ -17,6 +18,8 @@ import (
 
 type FactService interface {
 	WithQuerier(config.PgxIface) FactService
+	WithActor(*domain.Actor) FactService
+	WithContext(context.Context) FactService
 
 	GetById(uuid.UUID) (domain.Fact, error)
 	GetByRunId(uuid.UUID) ([]*domain.Fact, error)
@@ -31,6 +34,13 @@ type factService struct {
 	factRepository repository.FactRepository
 	actionService  ActionService
 	db             config.PgxIface
+
+	auditEventRepository repository.AuditEventRepository
+	actor                *domain.Actor
+
+	// Spans are started as children of the one in this context
+	// and work in progress is aborted when it is done.
+	ctx context.Context
 }
 
 func NewFactService(db config.PgxIface, actionService ActionService, logger *zerolog.Logger) FactService {
@@ -39,6 +49,9 @@ func NewFactService(db config.PgxIface, actionService ActionService, logger *zer
 		actionService:  actionService,
 		factRepository: persistence.NewFactRepository(db),
 		db:             db,
+
+		auditEventRepository: persistence.NewAuditEventRepository(db),
+		ctx:                  context.Background(),
 	}
 }
 
@@ -48,9 +61,28 @@ func (self *factService) WithQuerier(querier config.PgxIface) FactService {
 		factRepository: self.factRepository.WithQuerier(querier),
 		actionService:  self.actionService.WithQuerier(querier),
 		db:             querier,
+
+		auditEventRepository: self.auditEventRepository.WithQuerier(querier),
+		actor:                self.actor,
+		ctx:                  self.ctx,
 	}
 }
 
+// Returns a copy that records the given actor in the audit log.
+func (self *factService) WithActor(actor *domain.Actor) FactService {
+	clone := *self
+	clone.actor = actor
+	return &clone
+}
+
+// Returns a copy that continues the trace in the given context
+// and aborts work in progress when it is done.
+func (self *factService) WithContext(ctx context.Context) FactService {
+	clone := *self
+	clone.ctx = ctx
+	return &clone
+}
+
 func (self *factService) GetById(id uuid.UUID) (fact domain.Fact, err error) {
 	self.logger.Debug().Str("id", id.String()).Msg("Getting Fact by ID")
 	fact, err = self.factRepository.GetById(id)
@@ -72,15 +104,39 @@ func (self *factService) GetBinaryById(tx pgx.Tx, id uuid.UUID) (binary io.ReadS
 	return
 }
 
-func (self *factService) Save(fact *domain.Fact, binary io.Reader) error {
-	return self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+func (self *factService) Save(fact *domain.Fact, binary io.Reader) (err error) {
+	ctx, span := application.StartSpan(self.ctx, "FactService.Save", application.SpanKindInternal)
+	defer func() { span.End(err) }()
+
+	ctx, applyMetrics := application.WithPendingMetrics(ctx)
+	defer func() {
+		if err == nil {
+			applyMetrics()
+		}
+	}()
+
+	return self.db.BeginFunc(ctx, func(tx pgx.Tx) error {
 		self.logger.Debug().Msg("Saving new Fact")
 		if err := self.factRepository.WithQuerier(tx).Save(fact, binary); err != nil {
 			return errors.WithMessagef(err, "Could not insert Fact")
 		}
 		self.logger.Debug().Str("id", fact.ID.String()).Msg("Created Fact")
+		span.SetAttribute("cicero.fact.id", fact.ID.String())
+
+		source := "run"
+		if fact.RunId == nil {
+			source = "external"
+		}
+		application.UpdateMetrics(ctx, application.MetricFactsPublished.WithLabelValues(source).Inc)
+
+		// Facts published by Runs are already attributed to them.
+		if fact.RunId == nil {
+			if err := audit(self.auditEventRepository.WithQuerier(tx), self.actor, domain.AuditFactCreate, fact.ID, nil, fact); err != nil {
+				return err
+			}
+		}
 
-		return self.actionService.WithQuerier(tx).InvokeCurrentActive()
+		return self.actionService.WithQuerier(tx).WithContext(ctx).InvokeCurrentActive()
 	})
 }
 
@@ -1,7 +1,7 @@
 package service
 
 import (
-	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 	nomad "github.com/hashicorp/nomad/api"
@@ -17,40 +17,49 @@ import (
 type NomadEventService interface {
 	WithQuerier(config.PgxIface) NomadEventService
 
-	Save(*nomad.Event) error
+	Save(*nomad.Event) (bool, error)
 	GetLastNomadEvent() (uint64, error)
 	GetEventAllocByNomadJobId(id uuid.UUID) (map[string]domain.AllocWrapper, error)
+	GetAllocationsByNomadJobId(id uuid.UUID) ([]*nomad.Allocation, error)
+	SaveAllocation(uuid.UUID, *nomad.Allocation) error
+	Prune(time.Time) (int64, error)
 }
 
 type nomadEventService struct {
-	logger               zerolog.Logger
-	nomadEventRepository repository.NomadEventRepository
-	runService           RunService
+	logger                  zerolog.Logger
+	nomadEventRepository    repository.NomadEventRepository
+	runAllocationRepository repository.RunAllocationRepository
+	runService              RunService
 }
 
 func NewNomadEventService(db config.PgxIface, runService RunService, logger *zerolog.Logger) NomadEventService {
 	return &nomadEventService{
-		logger:               logger.With().Str("component", "NomadEventService").Logger(),
-		nomadEventRepository: persistence.NewNomadEventRepository(db),
-		runService:           runService,
+		logger:                  logger.With().Str("component", "NomadEventService").Logger(),
+		nomadEventRepository:    persistence.NewNomadEventRepository(db),
+		runAllocationRepository: persistence.NewRunAllocationRepository(db),
+		runService:              runService,
 	}
 }
 
 func (n *nomadEventService) WithQuerier(querier config.PgxIface) NomadEventService {
 	return &nomadEventService{
-		logger:               n.logger,
-		nomadEventRepository: n.nomadEventRepository.WithQuerier(querier),
-		runService:           n.runService.WithQuerier(querier),
+		logger:                  n.logger,
+		nomadEventRepository:    n.nomadEventRepository.WithQuerier(querier),
+		runAllocationRepository: n.runAllocationRepository.WithQuerier(querier),
+		runService:              n.runService.WithQuerier(querier),
 	}
 }
 
-func (n *nomadEventService) Save(event *nomad.Event) error {
+func (n *nomadEventService) Save(event *nomad.Event) (bool, error) {
 	n.logger.Debug().Msgf("Saving new NomadEvent %d", event.Index)
-	if err := n.nomadEventRepository.Save(event); err != nil {
-		return errors.WithMessagef(err, "Could not insert NomadEvent")
+	if created, err := n.nomadEventRepository.Save(event); err != nil {
+		return false, errors.WithMessagef(err, "Could not insert NomadEvent")
+	} else if !created {
+		n.logger.Debug().Msgf("NomadEvent %d already exists", event.Index)
+		return false, nil
 	}
 	n.logger.Debug().Msgf("Created NomadEvent %d", event.Index)
-	return nil
+	return true, nil
 }
 
 func (n *nomadEventService) GetLastNomadEvent() (uint64, error) {
@@ -58,30 +67,49 @@ func (n *nomadEventService) GetLastNomadEvent() (uint64, error) {
 	return n.nomadEventRepository.GetLastNomadEvent()
 }
 
+func (n *nomadEventService) Prune(before time.Time) (int64, error) {
+	n.logger.Debug().Time("before", before).Msg("Pruning Nomad Events")
+	deleted, err := n.nomadEventRepository.Prune(before)
+	if err != nil {
+		return deleted, errors.WithMessagef(err, "Could not delete Nomad Events created before %s", before)
+	}
+	n.logger.Debug().Int64("deleted", deleted).Msg("Pruned Nomad Events")
+	return deleted, nil
+}
+
+func (n *nomadEventService) SaveAllocation(runId uuid.UUID, alloc *nomad.Allocation) error {
+	n.logger.Debug().Str("run-id", runId.String()).Str("alloc-id", alloc.ID).Msg("Saving Run Allocation")
+	if err := n.runAllocationRepository.Save(runId, alloc); err != nil {
+		return errors.WithMessagef(err, "Could not save Allocation %q of Run %q", alloc.ID, runId)
+	}
+	return nil
+}
+
+func (n *nomadEventService) GetAllocationsByNomadJobId(nomadJobId uuid.UUID) (allocs []*nomad.Allocation, err error) {
+	n.logger.Debug().Str("nomad-job-id", nomadJobId.String()).Msg("Getting allocations by Nomad Job ID")
+	allocs, err = n.runAllocationRepository.GetByRunId(nomadJobId)
+	err = errors.WithMessagef(err, "Could not select allocations of Nomad Job %q", nomadJobId)
+	return
+}
+
 func (n *nomadEventService) GetEventAllocByNomadJobId(nomadJobId uuid.UUID) (map[string]domain.AllocWrapper, error) {
 	allocs := map[string]domain.AllocWrapper{}
 	n.logger.Debug().Msgf("Getting EventAlloc by Nomad Job ID: %q", nomadJobId)
-	results, err := n.nomadEventRepository.GetEventAllocByNomadJobId(nomadJobId)
+	results, err := n.runAllocationRepository.GetByRunId(nomadJobId)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, result := range results {
-		if result["alloc"] == nil {
-			continue
-		}
-
-		alloc := &nomad.Allocation{}
-		err = json.Unmarshal([]byte(result["alloc"].(string)), alloc)
-		if err != nil {
-			return nil, err
-		}
+	if len(results) == 0 {
+		return allocs, nil
+	}
 
-		run, err := n.runService.GetByNomadJobId(nomadJobId)
-		if err != nil {
-			return nil, err
-		}
+	run, err := n.runService.GetByNomadJobId(nomadJobId)
+	if err != nil {
+		return nil, err
+	}
 
+	for _, alloc := range results {
 		logs := map[string]*domain.LokiOutput{}
 
 		for taskName := range alloc.TaskResources {
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+	"github.com/input-output-hk/cicero/src/infrastructure/persistence"
+)
+
+const (
+	// How many notifications to claim at once.
+	notificationBatch = 10
+	// How long to wait for a sink.
+	notificationTimeout = 30 * time.Second
+	// How long others wait before attempting a claimed notification,
+	// in case the instance that claimed it died while delivering.
+	notificationLease = notificationBatch * 2 * notificationTimeout
+	// Delay before the first retry, doubled with every attempt.
+	notificationBackoff    = 30 * time.Second
+	notificationMaxBackoff = time.Hour
+)
+
+type NotificationService interface {
+	WithQuerier(config.PgxIface) NotificationService
+	WithActor(*domain.Actor) NotificationService
+
+	GetById(uuid.UUID) (domain.Notification, error)
+	Get(domain.NotificationState, *repository.Page) ([]*domain.Notification, error)
+	GetAttempts(uuid.UUID) ([]*domain.NotificationAttempt, error)
+	// Queues notifications according to the rules that match the Run
+	// and commit statuses for the commits its input facts are about.
+	// Should be called in the same transaction that starts or ends the Run.
+	Notify(domain.Run, domain.RunStatus) error
+	// Makes one attempt to deliver those that are due.
+	// Returns how many were attempted.
+	Deliver(context.Context) (int, error)
+	// Queues a notification again, usually one that failed.
+	Replay(uuid.UUID) error
+}
+
+type notificationService struct {
+	logger                 zerolog.Logger
+	notificationRepository repository.NotificationRepository
+	actionRepository       repository.ActionRepository
+	runRepository          repository.RunRepository
+	factRepository         repository.FactRepository
+	db                     config.PgxIface
+	sinks                  map[string]application.NotificationSink
+	rules                  []domain.NotificationRule
+	webUrl                 string
+	maxAttempts            int
+
+	auditEventRepository repository.AuditEventRepository
+	actor                *domain.Actor
+}
+
+// The rules apply to all Actions in addition to those in their meta.
+// The web URL is used to link to Runs and may be empty.
+func NewNotificationService(db config.PgxIface, sinks map[string]application.NotificationSink, rules []domain.NotificationRule, webUrl string, maxAttempts int, logger *zerolog.Logger) NotificationService {
+	return &notificationService{
+		logger:                 logger.With().Str("component", "NotificationService").Logger(),
+		notificationRepository: persistence.NewNotificationRepository(db),
+		actionRepository:       persistence.NewActionRepository(db),
+		runRepository:          persistence.NewRunRepository(db),
+		factRepository:         persistence.NewFactRepository(db),
+		db:                     db,
+		sinks:                  sinks,
+		rules:                  rules,
+		webUrl:                 webUrl,
+		maxAttempts:            maxAttempts,
+
+		auditEventRepository: persistence.NewAuditEventRepository(db),
+	}
+}
+
+func (self *notificationService) WithQuerier(querier config.PgxIface) NotificationService {
+	return &notificationService{
+		logger:                 self.logger,
+		notificationRepository: self.notificationRepository.WithQuerier(querier),
+		actionRepository:       self.actionRepository.WithQuerier(querier),
+		runRepository:          self.runRepository.WithQuerier(querier),
+		factRepository:         self.factRepository.WithQuerier(querier),
+		db:                     querier,
+		sinks:                  self.sinks,
+		rules:                  self.rules,
+		webUrl:                 self.webUrl,
+		maxAttempts:            self.maxAttempts,
+
+		auditEventRepository: self.auditEventRepository.WithQuerier(querier),
+		actor:                self.actor,
+	}
+}
+
+// Returns a copy that records the given actor in the audit log.
+func (self *notificationService) WithActor(actor *domain.Actor) NotificationService {
+	clone := *self
+	clone.actor = actor
+	return &clone
+}
+
+func (self *notificationService) GetById(id uuid.UUID) (notification domain.Notification, err error) {
+	self.logger.Debug().Str("id", id.String()).Msg("Getting Notification by ID")
+	notification, err = self.notificationRepository.GetById(id)
+	err = errors.WithMessagef(err, "Could not select existing Notification with ID %q", id)
+	return
+}
+
+func (self *notificationService) Get(state domain.NotificationState, page *repository.Page) (notifications []*domain.Notification, err error) {
+	self.logger.Debug().Str("state", string(state)).Int("offset", page.Offset).Int("limit", page.Limit).Msg("Getting Notifications")
+	notifications, err = self.notificationRepository.Get(state, page)
+	err = errors.WithMessagef(err, "Could not select Notifications with offset %d and limit %d", page.Offset, page.Limit)
+	return
+}
+
+func (self *notificationService) GetAttempts(id uuid.UUID) (attempts []*domain.NotificationAttempt, err error) {
+	self.logger.Debug().Str("id", id.String()).Msg("Getting attempts of Notification")
+	attempts, err = self.notificationRepository.GetAttempts(id)
+	err = errors.WithMessagef(err, "Could not select attempts of Notification with ID %q", id)
+	return
+}
+
+func (self *notificationService) Notify(run domain.Run, status domain.RunStatus) error {
+	action, err := self.actionRepository.GetById(run.ActionId)
+	if err != nil {
+		return errors.WithMessagef(err, "Could not select Action with ID %q", run.ActionId)
+	}
+
+	rules := self.rules
+	if actionRules, err := action.NotificationRules(); err != nil {
+		// A mistake in the Action should not keep its Runs from ending.
+		self.logger.Warn().Err(err).Str("action", action.Name).Msg("Ignoring invalid notification rules")
+	} else {
+		rules = append(actionRules, rules...)
+	}
+
+	payload := domain.NotificationPayload{
+		RunId:      run.NomadJobID,
+		ActionId:   action.ID,
+		ActionName: action.Name,
+		Status:     status,
+		CreatedAt:  run.CreatedAt,
+		FinishedAt: run.FinishedAt,
+	}
+	if self.webUrl != "" {
+		payload.Url = self.webUrl + "/run/" + url.PathEscape(run.NomadJobID.String())
+	}
+
+	for _, rule := range rules {
+		if matches, err := rule.Matches(action.Name, status); err != nil {
+			self.logger.Warn().Err(err).Str("action", action.Name).Msg("Ignoring invalid notification rule")
+			continue
+		} else if !matches {
+			continue
+		}
+
+		if _, ok := self.sinks[rule.Sink]; !ok {
+			self.logger.Warn().Str("action", action.Name).Str("sink", rule.Sink).Msg("Ignoring notification rule for unknown sink")
+			continue
+		}
+
+		notification := domain.Notification{
+			RunId:   run.NomadJobID,
+			Sink:    rule.Sink,
+			Target:  rule.Target,
+			Payload: payload,
+		}
+		if err := self.notificationRepository.Save(&notification); err != nil {
+			return errors.WithMessagef(err, "Could not insert Notification for Run with ID %q", run.NomadJobID)
+		}
+		self.logger.Debug().Str("id", notification.ID.String()).Str("sink", rule.Sink).Msg("Queued Notification")
+	}
+
+	return self.reportCommitStatus(run, payload)
+}
+
+func (self *notificationService) reportCommitStatus(run domain.Run, payload domain.NotificationPayload) error {
+	sink, ok := self.sinks[domain.CommitStatusSink].(application.CommitStatusSink)
+	if !ok {
+		return nil
+	}
+
+	inputFactIds, err := self.runRepository.GetInputFactIdsByNomadJobId(run.NomadJobID)
+	if err != nil {
+		return errors.WithMessagef(err, "Could not select input facts of Run with ID %q", run.NomadJobID)
+	}
+
+	targets := map[string]struct{}{}
+	for _, ids := range inputFactIds {
+		for _, id := range ids {
+			fact, err := self.factRepository.GetById(id)
+			if err != nil {
+				return errors.WithMessagef(err, "Could not select Fact with ID %q", id)
+			}
+			for _, target := range domain.FindCommitStatusUrls(fact.Value) {
+				if sink.Accepts(target) {
+					targets[target] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for target := range targets {
+		// Keep an older status from overwriting this one if it is delivered later.
+		if err := self.notificationRepository.Supersede(run.NomadJobID, domain.CommitStatusSink, target); err != nil {
+			return errors.WithMessagef(err, "Could not supersede commit statuses of Run with ID %q", run.NomadJobID)
+		}
+
+		notification := domain.Notification{
+			RunId:   run.NomadJobID,
+			Sink:    domain.CommitStatusSink,
+			Target:  target,
+			Payload: payload,
+		}
+		if err := self.notificationRepository.Save(&notification); err != nil {
+			return errors.WithMessagef(err, "Could not insert commit status for Run with ID %q", run.NomadJobID)
+		}
+		self.logger.Debug().Str("id", notification.ID.String()).Str("target", target).Msg("Queued commit status")
+	}
+
+	return nil
+}
+
+func (self *notificationService) Deliver(ctx context.Context) (attempted int, err error) {
+	// Claiming commits right away so that no locks are held while sending.
+	notifications, err := self.notificationRepository.Claim(notificationBatch, notificationLease)
+	if err != nil {
+		return 0, errors.WithMessage(err, "Could not claim due Notifications")
+	}
+
+	for _, notification := range notifications {
+		attempt := domain.NotificationAttempt{NotificationId: notification.ID}
+		if err := self.send(ctx, notification); err != nil {
+			msg := err.Error()
+			attempt.Error = &msg
+		}
+
+		if err := self.recordAttempt(&attempt); err != nil {
+			return attempted, err
+		}
+
+		attempted++
+	}
+
+	return
+}
+
+func (self *notificationService) recordAttempt(attempt *domain.NotificationAttempt) error {
+	// Use a fresh context so that a delivery is recorded even when stopping.
+	return self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		notificationRepository := self.notificationRepository.WithQuerier(tx)
+
+		if err := notificationRepository.SaveAttempt(attempt); err != nil {
+			return errors.WithMessagef(err, "Could not insert attempt of Notification with ID %q", attempt.NotificationId)
+		}
+
+		// It may have been superseded or replayed while it was being delivered.
+		notification, err := notificationRepository.GetById(attempt.NotificationId)
+		if err != nil {
+			return errors.WithMessagef(err, "Could not select existing Notification with ID %q", attempt.NotificationId)
+		}
+		if notification.State != domain.NotificationPending {
+			return nil
+		}
+
+		notification.Attempts++
+		switch {
+		case attempt.Error == nil:
+			notification.State = domain.NotificationDelivered
+			notification.DeliveredAt = &attempt.CreatedAt
+			self.logger.Debug().Str("id", notification.ID.String()).Msg("Delivered Notification")
+		case notification.Attempts >= self.maxAttempts:
+			notification.State = domain.NotificationFailed
+			self.logger.Warn().Str("id", notification.ID.String()).Str("error", *attempt.Error).Msg("Giving up on Notification")
+		default:
+			backoff := notificationBackoff << (notification.Attempts - 1)
+			if backoff > notificationMaxBackoff || backoff <= 0 {
+				backoff = notificationMaxBackoff
+			}
+			notification.NextAttemptAt = attempt.CreatedAt.Add(backoff)
+			self.logger.Debug().Str("id", notification.ID.String()).Str("error", *attempt.Error).Time("next-attempt", notification.NextAttemptAt).Msg("Could not deliver Notification")
+		}
+
+		if err := notificationRepository.Update(&notification); err != nil {
+			return errors.WithMessagef(err, "Could not update Notification with ID %q", notification.ID)
+		}
+
+		return nil
+	})
+}
+
+func (self *notificationService) send(ctx context.Context, notification *domain.Notification) error {
+	sink, ok := self.sinks[notification.Sink]
+	if !ok {
+		return errors.Errorf("Sink %q is not configured", notification.Sink)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	return sink.Send(ctx, notification.Target, notification.Payload)
+}
+
+func (self *notificationService) Replay(id uuid.UUID) error {
+	self.logger.Debug().Str("id", id.String()).Msg("Replaying Notification")
+	return self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		notificationRepository := self.notificationRepository.WithQuerier(tx)
+
+		notification, err := notificationRepository.GetById(id)
+		if err != nil {
+			return errors.WithMessagef(err, "Could not select existing Notification with ID %q", id)
+		}
+		before := notification
+
+		notification.State = domain.NotificationPending
+		notification.Attempts = 0
+		notification.NextAttemptAt = time.Now().UTC()
+		notification.DeliveredAt = nil
+		if err := notificationRepository.Update(&notification); err != nil {
+			return errors.WithMessagef(err, "Could not update Notification with ID %q", id)
+		}
+
+		return audit(self.auditEventRepository.WithQuerier(tx), self.actor, domain.AuditNotificationReplay, id, before, notification)
+	})
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+type failingNotificationSink struct{}
+
+func (failingNotificationSink) Send(context.Context, string, domain.NotificationPayload) error {
+	return errors.New("unreachable")
+}
+
+func TestShouldRetryNotificationWithBackoff(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	now := time.Now().UTC()
+
+	notification := domain.Notification{
+		ID:            uuid.New(),
+		CreatedAt:     now,
+		RunId:         uuid.New(),
+		Sink:          "webhook",
+		Target:        "http://127.0.0.1:1",
+		State:         domain.NotificationPending,
+		Attempts:      1,
+		NextAttemptAt: now,
+	}
+
+	// given
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+	columns := []string{"id", "created_at", "run_id", "sink", "target", "payload", "state", "attempts", "next_attempt_at", "delivered_at"}
+	row := []interface{}{notification.ID, notification.CreatedAt, notification.RunId, notification.Sink, notification.Target, notification.Payload, notification.State, notification.Attempts, notification.NextAttemptAt, notification.DeliveredAt}
+	// claimed outside of the transaction that records the attempt
+	mock.ExpectQuery("UPDATE notification SET next_attempt_at").
+		WithArgs(domain.NotificationPending, notificationBatch, notificationLease).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(row...))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notification_attempt").
+		WithArgs(notification.ID, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New(), now))
+	mock.ExpectQuery("SELECT (.+) FROM notification WHERE id").
+		WithArgs(notification.ID).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(row...))
+	// the second attempt failed so the next one is due after twice the backoff
+	mock.ExpectExec("UPDATE notification").
+		WithArgs(notification.ID, domain.NotificationPending, 2, now.Add(2*notificationBackoff), notification.DeliveredAt).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+
+	notificationService := NewNotificationService(mock, map[string]application.NotificationSink{"webhook": failingNotificationSink{}}, nil, "", 3, &logger)
+
+	// when
+	attempted, err := notificationService.Deliver(context.Background())
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attempted)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/grafana/loki/pkg/loghttp"
-	nomad "github.com/hashicorp/nomad/api"
 	"github.com/jackc/pgx/v4"
 	"github.com/pkg/errors"
 	prometheus "github.com/prometheus/client_golang/api"
@@ -25,38 +25,70 @@ import (
 
 type RunService interface {
 	WithQuerier(config.PgxIface) RunService
+	WithActor(*domain.Actor) RunService
 
 	GetByNomadJobId(uuid.UUID) (domain.Run, error)
 	GetInputFactIdsByNomadJobId(uuid.UUID) (repository.RunInputFactIds, error)
 	GetOutputByNomadJobId(uuid.UUID) (domain.RunOutput, error)
+	GetPlanByNomadJobId(uuid.UUID) (domain.RunPlan, error)
+	GetDefinitionByNomadJobId(uuid.UUID) (domain.RunDefinition, error)
 	GetByActionId(uuid.UUID, *repository.Page) ([]*domain.Run, error)
 	GetLatestByActionId(uuid.UUID) (domain.Run, error)
 	GetAll(*repository.Page) ([]*domain.Run, error)
 	GetByInputFactIds([]*uuid.UUID, bool, *repository.Page) ([]*domain.Run, error)
+	// Returns Runs that are still running after their deadline.
+	GetPastDeadline() ([]*domain.Run, error)
 	Save(*domain.Run, map[string]interface{}, *domain.RunOutput) error
+	SavePlan(uuid.UUID, *domain.RunPlan) error
+	// Keeps what exactly the Run ran so that it can be reproduced.
+	// The job must not contain the Run's token.
+	SaveDefinition(uuid.UUID, *domain.RunDefinition) error
 	Update(*domain.Run) error
-	End(*domain.Run) error
-	Cancel(*domain.Run) error
+	End(*domain.Run) (bool, error)
+	// Returns a token that may only publish facts for the given Run.
+	// It is revoked when the Run ends.
+	CreateToken(uuid.UUID) (string, error)
+	AuthenticateToken(string) (uuid.UUID, error)
+	// Stops the Run so that it ends as canceled.
+	// Returns false if it has already ended or is already being stopped
+	// in which case its job is still canceled.
+	Cancel(*domain.Run) (bool, error)
+	// Stops the Run so that it ends as timed out.
+	// Returns false without doing anything if it has already ended or is already being stopped.
+	TimeOut(*domain.Run) (bool, error)
 	JobLogs(id uuid.UUID, start time.Time, end *time.Time) (*domain.LokiOutput, error)
 	RunLogs(allocId, taskGroup, taskName string, start time.Time, end *time.Time) (*domain.LokiOutput, error)
 }
 
 type runService struct {
-	logger              zerolog.Logger
-	runRepository       repository.RunRepository
-	runOutputRepository repository.RunOutputRepository
-	prometheus          prometheus.Client
-	nomadClient         application.NomadClient
-	db                  config.PgxIface
+	logger                  zerolog.Logger
+	runRepository           repository.RunRepository
+	runOutputRepository     repository.RunOutputRepository
+	runPlanRepository       repository.RunPlanRepository
+	runDefinitionRepository repository.RunDefinitionRepository
+	runTokenRepository      repository.RunTokenRepository
+	tokenTtl                time.Duration
+	prometheus              prometheus.Client
+	executor                application.Executor
+	db                      config.PgxIface
+
+	auditEventRepository repository.AuditEventRepository
+	actor                *domain.Actor
 }
 
-func NewRunService(db config.PgxIface, prometheusAddr string, nomadClient application.NomadClient, logger *zerolog.Logger) RunService {
+func NewRunService(db config.PgxIface, prometheusAddr string, executor application.Executor, tokenTtl time.Duration, logger *zerolog.Logger) RunService {
 	impl := runService{
-		logger:              logger.With().Str("component", "RunService").Logger(),
-		runRepository:       persistence.NewRunRepository(db),
-		runOutputRepository: persistence.NewRunOutputRepository(db),
-		nomadClient:         nomadClient,
-		db:                  db,
+		logger:                  logger.With().Str("component", "RunService").Logger(),
+		runRepository:           persistence.NewRunRepository(db),
+		runOutputRepository:     persistence.NewRunOutputRepository(db),
+		runPlanRepository:       persistence.NewRunPlanRepository(db),
+		runDefinitionRepository: persistence.NewRunDefinitionRepository(db),
+		runTokenRepository:      persistence.NewRunTokenRepository(db),
+		tokenTtl:                tokenTtl,
+		executor:                executor,
+		db:                      db,
+
+		auditEventRepository: persistence.NewAuditEventRepository(db),
 	}
 
 	if prom, err := prometheus.NewClient(prometheus.Config{
@@ -73,15 +105,29 @@ func NewRunService(db config.PgxIface, prometheusAddr string, nomadClient applic
 
 func (self *runService) WithQuerier(querier config.PgxIface) RunService {
 	return &runService{
-		logger:              self.logger,
-		runRepository:       self.runRepository.WithQuerier(querier),
-		runOutputRepository: self.runOutputRepository.WithQuerier(querier),
-		prometheus:          self.prometheus,
-		nomadClient:         self.nomadClient,
-		db:                  querier,
+		logger:                  self.logger,
+		runRepository:           self.runRepository.WithQuerier(querier),
+		runOutputRepository:     self.runOutputRepository.WithQuerier(querier),
+		runPlanRepository:       self.runPlanRepository.WithQuerier(querier),
+		runDefinitionRepository: self.runDefinitionRepository.WithQuerier(querier),
+		runTokenRepository:      self.runTokenRepository.WithQuerier(querier),
+		tokenTtl:                self.tokenTtl,
+		prometheus:              self.prometheus,
+		executor:                self.executor,
+		db:                      querier,
+
+		auditEventRepository: self.auditEventRepository.WithQuerier(querier),
+		actor:                self.actor,
 	}
 }
 
+// Returns a copy that records the given actor in the audit log.
+func (self *runService) WithActor(actor *domain.Actor) RunService {
+	clone := *self
+	clone.actor = actor
+	return &clone
+}
+
 func (self *runService) GetByNomadJobId(id uuid.UUID) (run domain.Run, err error) {
 	self.logger.Debug().Str("nomad-job-id", id.String()).Msg("Getting Run by Nomad Job ID")
 	run, err = self.runRepository.GetByNomadJobId(id)
@@ -103,6 +149,20 @@ func (self *runService) GetOutputByNomadJobId(id uuid.UUID) (output domain.RunOu
 	return
 }
 
+func (self *runService) GetPlanByNomadJobId(id uuid.UUID) (plan domain.RunPlan, err error) {
+	self.logger.Debug().Str("nomad-job-id", id.String()).Msg("Getting Run Plan by Nomad Job ID")
+	plan, err = self.runPlanRepository.GetByRunId(id)
+	err = errors.WithMessagef(err, "Could not select existing Run Plan by Nomad Job ID %q", id)
+	return
+}
+
+func (self *runService) GetDefinitionByNomadJobId(id uuid.UUID) (def domain.RunDefinition, err error) {
+	self.logger.Debug().Str("nomad-job-id", id.String()).Msg("Getting Run Definition by Nomad Job ID")
+	def, err = self.runDefinitionRepository.GetByRunId(id)
+	err = errors.WithMessagef(err, "Could not select existing Run Definition by Nomad Job ID %q", id)
+	return
+}
+
 func (self *runService) GetByActionId(id uuid.UUID, page *repository.Page) (runs []*domain.Run, err error) {
 	self.logger.Debug().Str("id", id.String()).Int("offset", page.Offset).Int("limit", page.Limit).Msgf("Getting Run by Action ID")
 	runs, err = self.runRepository.GetByActionId(id, page)
@@ -131,6 +191,13 @@ func (self *runService) GetByInputFactIds(factIds []*uuid.UUID, recursive bool,
 	return
 }
 
+func (self *runService) GetPastDeadline() (runs []*domain.Run, err error) {
+	self.logger.Debug().Msg("Getting Runs past their deadline")
+	runs, err = self.runRepository.GetPastDeadline(time.Now().UTC())
+	err = errors.WithMessage(err, "Could not select Runs past their deadline")
+	return
+}
+
 func (self *runService) Save(run *domain.Run, inputs map[string]interface{}, output *domain.RunOutput) error {
 	self.logger.Debug().Msg("Saving new Run")
 	if err := self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
@@ -148,6 +215,24 @@ func (self *runService) Save(run *domain.Run, inputs map[string]interface{}, out
 	return nil
 }
 
+func (self *runService) SavePlan(id uuid.UUID, plan *domain.RunPlan) error {
+	self.logger.Debug().Str("id", id.String()).Msg("Saving Run Plan")
+	if err := self.runPlanRepository.Save(id, plan); err != nil {
+		return errors.WithMessagef(err, "Could not insert Run Plan for Run with ID %q", id)
+	}
+	self.logger.Debug().Str("id", id.String()).Msg("Created Run Plan")
+	return nil
+}
+
+func (self *runService) SaveDefinition(id uuid.UUID, def *domain.RunDefinition) error {
+	self.logger.Debug().Str("id", id.String()).Msg("Saving Run Definition")
+	if err := self.runDefinitionRepository.Save(id, def); err != nil {
+		return errors.WithMessagef(err, "Could not insert Run Definition for Run with ID %q", id)
+	}
+	self.logger.Debug().Str("id", id.String()).Msg("Created Run Definition")
+	return nil
+}
+
 func (self *runService) Update(run *domain.Run) error {
 	self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Updating Run")
 	if err := self.runRepository.Update(run); err != nil {
@@ -157,34 +242,106 @@ func (self *runService) Update(run *domain.Run) error {
 	return nil
 }
 
-func (self *runService) End(run *domain.Run) error {
+// Returns false without doing anything if the Run has already ended.
+func (self *runService) End(run *domain.Run) (ended bool, err error) {
 	self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Ending Run")
-	if err := self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
-		if err := self.runRepository.WithQuerier(tx).Update(run); err != nil {
+	if err = self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		if ended, err = self.runRepository.WithQuerier(tx).End(run); err != nil {
 			return errors.WithMessagef(err, "Could not update Run with ID %q", run.NomadJobID)
+		} else if !ended {
+			return nil
 		}
 		if err := self.runOutputRepository.WithQuerier(tx).Delete(run.NomadJobID); err != nil {
 			return errors.WithMessagef(err, "Could not update Run Output with ID %q", run.NomadJobID)
 		}
+		if err := self.runTokenRepository.WithQuerier(tx).Delete(run.NomadJobID); err != nil {
+			return errors.WithMessagef(err, "Could not revoke token of Run with ID %q", run.NomadJobID)
+		}
 		return nil
 	}); err != nil {
-		return err
+		return
 	}
-	self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Ended Run")
-	return nil
+	if ended {
+		self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Ended Run")
+	} else {
+		self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Run had already ended")
+	}
+	return
 }
 
-func (self *runService) Cancel(run *domain.Run) error {
+func (self *runService) CreateToken(id uuid.UUID) (string, error) {
+	self.logger.Debug().Str("id", id.String()).Msg("Creating Run token")
+	str, err := generateToken(RunTokenPrefix)
+	if err != nil {
+		return "", errors.WithMessage(err, "Could not generate Run token")
+	}
+	if err := self.runTokenRepository.Save(id, hashToken(str), self.tokenTtl); err != nil {
+		return "", errors.WithMessagef(err, "Could not insert token for Run with ID %q", id)
+	}
+	return str, nil
+}
+
+func (self *runService) AuthenticateToken(str string) (id uuid.UUID, err error) {
+	if !strings.HasPrefix(str, RunTokenPrefix) {
+		err = errors.New("Malformed Run token")
+		return
+	}
+	id, err = self.runTokenRepository.GetRunIdByHash(hashToken(str))
+	err = errors.WithMessage(err, "Could not find Run token, it may have expired or been revoked")
+	return
+}
+
+func (self *runService) Cancel(run *domain.Run) (stopped bool, err error) {
 	self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Stopping Run")
-	// Nomad does not know whether the job simply ran to finish
-	// or was stopped manually. Delete output to avoid publishing it.
-	if err := self.runOutputRepository.Delete(run.NomadJobID); err != nil {
-		return err
-	} else if _, _, err := self.nomadClient.JobsDeregister(run.NomadJobID.String(), false, &nomad.WriteOptions{}); err != nil {
-		return errors.WithMessagef(err, "Failed to deregister job %q", run.NomadJobID)
+	canceled := *run
+	if err = self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		// The executor does not tell whether the job simply ran to finish
+		// or was stopped manually so record the outcome beforehand.
+		outcome := domain.RunOutcomeCanceled
+		canceled.Outcome = &outcome
+		if stopped, err = self.runRepository.WithQuerier(tx).Stop(&canceled); err != nil {
+			return errors.WithMessagef(err, "Could not update Run with ID %q", run.NomadJobID)
+		} else if !stopped {
+			return nil
+		} else if err := audit(self.auditEventRepository.WithQuerier(tx), self.actor, domain.AuditRunCancel, run.NomadJobID, run, &canceled); err != nil {
+			return err
+		} else if err := self.executor.Cancel(run.NomadJobID.String()); err != nil {
+			return errors.WithMessagef(err, "Failed to cancel job %q", run.NomadJobID)
+		}
+		return nil
+	}); err != nil {
+		stopped = false
+		return
 	}
-	self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Stopped Run")
-	return nil
+	if stopped {
+		*run = canceled
+		self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Stopped Run")
+	} else {
+		self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Run had already ended")
+	}
+	return
+}
+
+func (self *runService) TimeOut(run *domain.Run) (stopped bool, err error) {
+	self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Timing out Run")
+	if err = self.db.BeginFunc(context.Background(), func(tx pgx.Tx) error {
+		outcome := domain.RunOutcomeTimedOut
+		run.Outcome = &outcome
+		if stopped, err = self.runRepository.WithQuerier(tx).Stop(run); err != nil {
+			return errors.WithMessagef(err, "Could not update Run with ID %q", run.NomadJobID)
+		} else if !stopped {
+			return nil
+		} else if err := self.executor.Cancel(run.NomadJobID.String()); err != nil {
+			return errors.WithMessagef(err, "Failed to cancel job %q", run.NomadJobID)
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+	if stopped {
+		self.logger.Debug().Str("id", run.NomadJobID.String()).Msg("Timed out Run")
+	}
+	return
 }
 
 func (self *runService) JobLogs(nomadJobID uuid.UUID, start time.Time, end *time.Time) (*domain.LokiOutput, error) {
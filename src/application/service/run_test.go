@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/application/mocks"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldCancelRun(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	run := domain.Run{
+		NomadJobID: uuid.New(),
+		ActionId:   uuid.New(),
+	}
+
+	// given
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+	before := run
+	canceled := domain.RunOutcomeCanceled
+	after := run
+	after.Outcome = &canceled
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE run SET outcome").WithArgs(run.NomadJobID, &canceled).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("INSERT INTO audit_event").
+		WithArgs(domain.AuditRunCancel, run.NomadJobID, "test", "operator", pgxmock.AnyArg(), &before, &after).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New(), time.Now()))
+	mock.ExpectCommit()
+
+	nomadClient := mocks.NewNomadClient()
+	runService := NewRunService(mock, "http://127.0.0.1:3100", application.NewNomadExecutor(nomadClient, &logger), time.Hour, &logger)
+
+	// when
+	stopped, err := runService.WithActor(&domain.Actor{Identity: domain.Identity{Name: "test", Role: domain.RoleOperator}}).Cancel(&run)
+
+	// then
+	assert.Nil(t, err)
+	assert.True(t, stopped)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{run.NomadJobID.String()}, nomadClient.Deregistered())
+	assert.Equal(t, after, run)
+}
+
+func TestShouldNotAuditCancelOfEndedRun(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	run := domain.Run{
+		NomadJobID: uuid.New(),
+		ActionId:   uuid.New(),
+	}
+
+	// given
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+	canceled := domain.RunOutcomeCanceled
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE run SET outcome").WithArgs(run.NomadJobID, &canceled).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectCommit()
+
+	nomadClient := mocks.NewNomadClient()
+	runService := NewRunService(mock, "http://127.0.0.1:3100", application.NewNomadExecutor(nomadClient, &logger), time.Hour, &logger)
+
+	// when
+	stopped, err := runService.WithActor(&domain.Actor{Identity: domain.Identity{Name: "test", Role: domain.RoleOperator}}).Cancel(&run)
+
+	// then
+	assert.Nil(t, err)
+	assert.False(t, stopped)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Empty(t, nomadClient.Deregistered())
+	assert.Nil(t, run.Outcome)
+}
+
+func TestShouldTimeOutRun(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	timedOut := domain.RunOutcomeTimedOut
+
+	for _, stopped := range []bool{true, false} {
+		run := domain.Run{
+			NomadJobID: uuid.New(),
+			ActionId:   uuid.New(),
+		}
+
+		// given
+		mock, err := pgxmock.NewConn()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer mock.Close(context.Background())
+		rowsAffected := int64(0)
+		if stopped {
+			rowsAffected = 1
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE run SET outcome").WithArgs(run.NomadJobID, &timedOut).WillReturnResult(pgxmock.NewResult("UPDATE", rowsAffected))
+		mock.ExpectCommit()
+
+		nomadClient := mocks.NewNomadClient()
+		runService := NewRunService(mock, "http://127.0.0.1:3100", application.NewNomadExecutor(nomadClient, &logger), time.Hour, &logger)
+
+		// when
+		timedOutRun, err := runService.TimeOut(&run)
+
+		// then
+		assert.Nil(t, err)
+		assert.Equal(t, stopped, timedOutRun)
+		assert.Nil(t, mock.ExpectationsWereMet())
+		if stopped {
+			assert.Equal(t, []string{run.NomadJobID.String()}, nomadClient.Deregistered())
+		} else {
+			assert.Empty(t, nomadClient.Deregistered(), "a Run that already ended must not be stopped again")
+		}
+	}
+}
+
+func TestShouldRevokeRunTokenOnEnd(t *testing.T) {
+	t.Parallel()
+	logger := zerolog.Nop()
+	finishedAt := time.Now()
+	run := domain.Run{
+		NomadJobID: uuid.New(),
+		ActionId:   uuid.New(),
+		FinishedAt: &finishedAt,
+	}
+
+	// given
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE run SET finished_at").WithArgs(run.NomadJobID, run.FinishedAt, run.Outcome).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("DELETE FROM run_output").WithArgs(run.NomadJobID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DELETE FROM run_token").WithArgs(run.NomadJobID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+
+	runService := NewRunService(mock, "http://127.0.0.1:3100", application.NewNomadExecutor(mocks.NewNomadClient(), &logger), time.Hour, &logger)
+
+	// when
+	ended, err := runService.End(&run)
+
+	// then
+	assert.Nil(t, err)
+	assert.True(t, ended)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/input-output-hk/cicero/src/application"
+)
+
+// Names of the environment variables that evaluators and transformers get by default.
+var DefaultSandboxEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "TMPDIR", "TZ", "LANG", "LC_*", "LOCALE_ARCHIVE",
+	"SSL_CERT_FILE", "SSL_CERT_DIR", "NIX_SSL_CERT_FILE", "GIT_SSL_CAINFO", "CURL_CA_BUNDLE",
+	"XDG_*", "NIX_*", "CICERO_EVALUATOR_*",
+}
+
+// How long to wait for the output after the process exited.
+// A child process that left the process group, like a daemon,
+// may keep it open and would otherwise block forever.
+// Does the same as `exec.Cmd.WaitDelay`, which Go 1.17 does not have.
+var sandboxWaitDelay = 2 * time.Second
+
+// Limits what evaluators and transformers can do.
+type Sandbox struct {
+	// How long a single invocation may take, 0 for no limit.
+	Timeout time.Duration
+	// Names of the environment variables that are passed on from Cicero's environment.
+	// A trailing * matches any suffix.
+	Env []string
+	// Memory limit in bytes, 0 for no limit. Enforced by the cgroup if there is one,
+	// otherwise it limits the data segment of each process with setrlimit(2).
+	MaxMemory uint64
+	// CPU time limit of each process, 0 for no limit.
+	MaxCpuTime time.Duration
+	// Directory of a cgroup v2 that Cicero may create child cgroups in.
+	// Each invocation runs in its own child cgroup.
+	Cgroup string
+}
+
+// Returns the environment variables of Cicero that are allowed
+// followed by the given ones.
+func (self Sandbox) environ(extra []string) []string {
+	return append(application.AllowedEnviron(self.Env), extra...)
+}
+
+// Runs the program with the given environment and input in the sandbox.
+// The whole process group is killed when the context is done
+// so that no child process keeps the output open.
+// Returns a TimeoutError if the invocation took too long.
+func (self Sandbox) run(ctx context.Context, name string, args, env []string, stdin []byte) (stdout, stderr []byte, err error) {
+	if self.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, self.Timeout)
+		defer cancel()
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cgroup string
+	if self.Cgroup != "" {
+		cgroup = filepath.Join(self.Cgroup, "cicero-"+uuid.NewString())
+		if err := os.Mkdir(cgroup, 0o755); err != nil {
+			return nil, nil, err
+		}
+		defer os.Remove(cgroup)
+
+		if self.MaxMemory > 0 {
+			if err := os.WriteFile(filepath.Join(cgroup, "memory.max"), []byte(fmt.Sprint(self.MaxMemory)), 0o644); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	cmd := exec.Command(path, args...)
+	if wrapper := self.wrapper(cgroup); wrapper != "" {
+		cmd = exec.Command("/bin/sh", append([]string{"-c", wrapper, "sh", path}, args...)...)
+	}
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	// Pipes are read by us instead of by exec's goroutines
+	// so that we can stop reading once the process exited.
+	stdoutRead, stdoutWrite, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stdoutRead.Close()
+	stderrRead, stderrWrite, err := os.Pipe()
+	if err != nil {
+		stdoutWrite.Close()
+		return nil, nil, err
+	}
+	defer stderrRead.Close()
+	cmd.Stdout = stdoutWrite
+	cmd.Stderr = stderrWrite
+
+	err = cmd.Start()
+	// The process has its own copies now.
+	stdoutWrite.Close()
+	stderrWrite.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	var reading sync.WaitGroup
+	for _, pipe := range []struct {
+		read *os.File
+		buf  *bytes.Buffer
+	}{{stdoutRead, &stdoutBuf}, {stderrRead, &stderrBuf}} {
+		reading.Add(1)
+		go func(read *os.File, buf *bytes.Buffer) {
+			defer reading.Done()
+			_, _ = io.Copy(buf, read)
+		}(pipe.read, pipe.buf)
+	}
+	readDone := make(chan struct{})
+	go func() {
+		reading.Wait()
+		close(readDone)
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}()
+
+	err = cmd.Wait()
+
+	select {
+	case <-readDone:
+	case <-time.After(sandboxWaitDelay):
+		// Closing unblocks the reads.
+		stdoutRead.Close()
+		stderrRead.Close()
+		<-readDone
+	}
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && self.Timeout > 0 {
+		err = TimeoutError{Timeout: self.Timeout, err: err}
+	}
+
+	return stdoutBuf.Bytes(), stderrBuf.Bytes(), err
+}
+
+// A shell script that applies the limits and runs its arguments,
+// or nothing if there are no limits.
+func (self Sandbox) wrapper(cgroup string) string {
+	script := []string{}
+	if cgroup != "" {
+		script = append(script, `echo $$ > `+shellQuote(filepath.Join(cgroup, "cgroup.procs")))
+	} else if self.MaxMemory > 0 {
+		script = append(script, fmt.Sprintf("ulimit -d %d", self.MaxMemory/1024))
+	}
+	if self.MaxCpuTime > 0 {
+		seconds := int64(self.MaxCpuTime.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		script = append(script, fmt.Sprintf("ulimit -t %d", seconds))
+	}
+	if len(script) == 0 {
+		return ""
+	}
+	return "set -e; " + strings.Join(script, "; ") + `; exec "$@"`
+}
+
+func shellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+// An invocation in the Sandbox took longer than its timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+	err     error
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("Timed out after %s: %s", e.Timeout, e.err)
+}
+
+func (e TimeoutError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	getter "github.com/hashicorp/go-getter/v2"
+	"github.com/pkg/errors"
+)
+
+// Keeps downloaded sources in a directory per revision
+// so that they do not change while they are evaluated.
+// Directories are locked with flock(2) so that
+// several processes can share the cache.
+type sourceCache struct {
+	dir string
+}
+
+// Where the source is kept at the given revision.
+func (self sourceCache) path(fetchUrl, revision string) string {
+	hash := sha256.Sum256([]byte(fetchUrl + "\x00" + revision))
+	return filepath.Join(self.dir, hex.EncodeToString(hash[:]))
+}
+
+// Downloads the source and returns the revision it is at.
+func (self sourceCache) resolve(ctx context.Context, fetchUrl string) (string, error) {
+	return self.download(ctx, fetchUrl, "")
+}
+
+// Returns the directory of the source at the given revision,
+// downloading it again if it has been pruned.
+// The directory is not pruned before release is called.
+func (self sourceCache) get(ctx context.Context, fetchUrl, revision string) (dir string, release func(), err error) {
+	dir = self.path(fetchUrl, revision)
+
+	for attempt := 0; ; attempt++ {
+		unlock, err := lock(dir, syscall.LOCK_SH)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if _, err := os.Stat(dir); err == nil {
+			now := time.Now()
+			if err := os.Chtimes(dir, now, now); err != nil {
+				unlock()
+				return "", nil, err
+			}
+			return dir, unlock, nil
+		} else if !os.IsNotExist(err) || attempt > 0 {
+			unlock()
+			return "", nil, err
+		}
+		unlock()
+
+		if _, err := self.download(ctx, fetchUrl, revision); err != nil {
+			return "", nil, err
+		}
+	}
+}
+
+// Downloads the source into a new directory
+// that is moved into place once its revision is known.
+// If a revision is wanted and the source is a git repository at another commit
+// it is checked out at the wanted one, which must still be available.
+func (self sourceCache) download(ctx context.Context, fetchUrl, wantRevision string) (string, error) {
+	if err := os.MkdirAll(self.dir, 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.MkdirTemp(self.dir, ".download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	dst := filepath.Join(tmp, "source")
+	if _, err := getter.DefaultClient.Get(ctx, &getter.Request{
+		Src:     fetchUrl,
+		Dst:     dst,
+		GetMode: getter.ModeAny,
+		Copy:    true,
+	}); err != nil {
+		return "", err
+	}
+
+	// Local directories are always symlinked by go-getter.
+	if info, err := os.Lstat(dst); err != nil {
+		return "", err
+	} else if info.Mode()&fs.ModeSymlink != 0 {
+		copied := filepath.Join(tmp, "copy")
+		if err := copyTree(dst, copied); err != nil {
+			return "", errors.WithMessage(err, "Could not copy local source")
+		}
+		dst = copied
+	}
+
+	revision, err := sourceRevision(ctx, dst)
+	if err != nil {
+		return "", err
+	}
+
+	if wantRevision != "" && revision != wantRevision {
+		if commit := strings.TrimPrefix(wantRevision, "git:"); commit != wantRevision {
+			if output, err := exec.CommandContext(ctx, "git", "-C", dst, "checkout", "--quiet", "--detach", commit).CombinedOutput(); err != nil {
+				return "", errors.WithMessagef(err, "Could not check out %s: %s", commit, output)
+			}
+			if revision, err = sourceRevision(ctx, dst); err != nil {
+				return "", err
+			}
+		}
+		if revision != wantRevision {
+			return "", fmt.Errorf("Source %q is no longer available at revision %s but at %s", fetchUrl, wantRevision, revision)
+		}
+	}
+
+	dir := self.path(fetchUrl, revision)
+
+	unlock, err := lock(dir, syscall.LOCK_EX)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if err := os.Rename(dst, dir); err != nil {
+		// Someone else downloaded the same revision meanwhile.
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return "", err
+		}
+	}
+
+	return revision, nil
+}
+
+// Deletes the sources that were not used since the given time
+// as well as downloads left over by crashes.
+func (self sourceCache) prune(before time.Time) (deleted int, err error) {
+	entries, err := os.ReadDir(self.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return deleted, err
+		}
+		if info.ModTime().After(before) {
+			continue
+		}
+
+		dir := filepath.Join(self.dir, entry.Name())
+
+		if strings.HasPrefix(entry.Name(), ".download-") {
+			if err := os.RemoveAll(dir); err != nil {
+				return deleted, err
+			}
+			continue
+		}
+
+		// Skip sources that are in use.
+		unlock, err := lock(dir, syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			continue
+		} else if err != nil {
+			return deleted, err
+		}
+
+		// Whoever waits for the lock file meanwhile finds it replaced
+		// once we unlock and takes the lock on a new one instead.
+		err = os.RemoveAll(dir)
+		if err == nil {
+			err = os.Remove(dir + ".lock")
+		}
+		unlock()
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// Locks the lock file that belongs to the directory.
+// Retries if the file was replaced while waiting for the lock
+// so that the lock is never taken on a file that prune unlinked.
+func lock(dir string, how int) (unlock func(), err error) {
+	path := dir + ".lock"
+
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := syscall.Flock(int(file.Fd()), how); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		locked, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(locked, current) {
+			return func() {
+				// Closing the file releases the lock.
+				file.Close()
+			}, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			file.Close()
+			return nil, err
+		}
+
+		file.Close()
+	}
+}
+
+func copyTree(src, dst string) error {
+	root, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			in, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, in); err != nil {
+				out.Close()
+				return err
+			}
+			return out.Close()
+		default:
+			// Sockets, devices and the like are not part of a source.
+			return nil
+		}
+	})
+}
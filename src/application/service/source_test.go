@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSource(t *testing.T, src, content string) {
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "actions.cue"), []byte(content), 0o644))
+}
+
+func readSource(t *testing.T, dir string) string {
+	content, err := os.ReadFile(filepath.Join(dir, "actions.cue"))
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestShouldPinLocalSourceRevision(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	sources := sourceCache{dir: t.TempDir()}
+
+	src := t.TempDir()
+	writeSource(t, src, "a")
+
+	revision, err := sources.resolve(ctx, src)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(revision, "sha256:"), revision)
+
+	writeSource(t, src, "b")
+
+	dir, release, err := sources.get(ctx, src, revision)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a", readSource(t, dir))
+		release()
+	}
+
+	newRevision, err := sources.resolve(ctx, src)
+	assert.NoError(t, err)
+	assert.NotEqual(t, revision, newRevision)
+}
+
+func TestShouldPruneUnusedSources(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	sources := sourceCache{dir: t.TempDir()}
+
+	src := t.TempDir()
+	writeSource(t, src, "a")
+
+	revision, err := sources.resolve(ctx, src)
+	assert.NoError(t, err)
+
+	deleted, err := sources.prune(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted, "the source was just used")
+
+	_, release, err := sources.get(ctx, src, revision)
+	assert.NoError(t, err)
+
+	deleted, err = sources.prune(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted, "the source is in use")
+
+	release()
+
+	deleted, err = sources.prune(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	entries, err := os.ReadDir(sources.dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	writeSource(t, src, "b")
+	_, _, err = sources.get(ctx, src, revision)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is no longer available at revision "+revision)
+	}
+
+	writeSource(t, src, "a")
+	dir, release, err := sources.get(ctx, src, revision)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a", readSource(t, dir))
+		release()
+	}
+}
+
+func TestShouldCheckOutPinnedCommitAgain(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found on PATH")
+	}
+
+	ctx := context.Background()
+	sources := sourceCache{dir: t.TempDir()}
+
+	src := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", src, "-c", "user.name=test", "-c", "user.email=test@localhost"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+	git("init", "--quiet")
+	writeSource(t, src, "a")
+	git("add", "actions.cue")
+	git("commit", "--quiet", "--message", "a")
+
+	revision, err := sources.resolve(ctx, src)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(revision, "git:"), revision)
+
+	writeSource(t, src, "b")
+	git("commit", "--quiet", "--all", "--message", "b")
+
+	deleted, err := sources.prune(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	dir, release, err := sources.get(ctx, src, revision)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a", readSource(t, dir))
+		release()
+	}
+}
+
+func TestShouldNotLockUnlinkedLockFile(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "source")
+
+	// given
+	unlockPrune, err := lock(dir, syscall.LOCK_EX)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	locked := make(chan func())
+	go func() {
+		unlock, err := lock(dir, syscall.LOCK_SH)
+		assert.NoError(t, err)
+		locked <- unlock
+	}()
+
+	// Let it open the lock file and wait for it.
+	time.Sleep(50 * time.Millisecond)
+
+	// when
+	assert.NoError(t, os.Remove(dir+".lock"))
+	unlockPrune()
+	unlock := <-locked
+	defer unlock()
+
+	// then
+	_, err = lock(dir, syscall.LOCK_EX|syscall.LOCK_NB)
+	assert.ErrorIs(t, err, syscall.EWOULDBLOCK, "the shared lock must be held on the current lock file")
+}
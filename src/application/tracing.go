@@ -0,0 +1,313 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Environment variable that holds the W3C trace context
+// in evaluators and jobs so that they can continue the trace.
+const TraceparentEnv = "TRACEPARENT"
+
+// Records spans and sends them to an OpenTelemetry collector
+// using OTLP over HTTP with the JSON encoding.
+type Tracer struct {
+	logger   zerolog.Logger
+	endpoint string
+	service  string
+	client   *http.Client
+	spans    chan *Span
+}
+
+const (
+	tracerQueue    = 4096
+	tracerBatch    = 512
+	tracerInterval = 5 * time.Second
+)
+
+var (
+	tracer     *Tracer
+	tracerLock sync.RWMutex
+)
+
+// Sets the Tracer that `StartSpan()` records to.
+// Without one spans are not recorded but trace contexts are still propagated.
+func SetTracer(t *Tracer) {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	tracer = t
+}
+
+func getTracer() *Tracer {
+	tracerLock.RLock()
+	defer tracerLock.RUnlock()
+	return tracer
+}
+
+// The endpoint is the base URL of the collector like `http://127.0.0.1:4318`.
+func NewTracer(endpoint, service string, logger *zerolog.Logger) *Tracer {
+	return &Tracer{
+		logger:   logger.With().Str("component", "Tracer").Logger(),
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service:  service,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan *Span, tracerQueue),
+	}
+}
+
+// Exports spans in batches until the context is done.
+func (self *Tracer) Start(ctx context.Context) error {
+	self.logger.Info().Str("endpoint", self.endpoint).Msg("Starting")
+
+	ticker := time.NewTicker(tracerInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, tracerBatch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := self.export(batch); err != nil {
+			self.logger.Err(err).Int("spans", len(batch)).Msg("Could not export spans")
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Take what is left without blocking.
+			for {
+				select {
+				case span := <-self.spans:
+					batch = append(batch, span)
+					if len(batch) == tracerBatch {
+						flush()
+					}
+				default:
+					flush()
+					return nil
+				}
+			}
+		case span := <-self.spans:
+			batch = append(batch, span)
+			if len(batch) == tracerBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (self *Tracer) record(span *Span) {
+	select {
+	case self.spans <- span:
+	default:
+		self.logger.Warn().Str("name", span.name).Msg("Dropping span because the queue is full")
+	}
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	result := make([]otlpAttribute, 0, len(attributes))
+	for k, v := range attributes {
+		a := otlpAttribute{Key: k}
+		a.Value.StringValue = v
+		result = append(result, a)
+	}
+	return result
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+func (self *Tracer) export(spans []*Span) error {
+	otlpSpans := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		s := otlpSpan{
+			TraceId:           hex.EncodeToString(span.context.traceId[:]),
+			SpanId:            hex.EncodeToString(span.context.spanId[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attributes),
+		}
+		if span.parentSpanId != ([8]byte{}) {
+			s.ParentSpanId = hex.EncodeToString(span.parentSpanId[:])
+		}
+		if span.err != nil {
+			s.Status.Code = 2 // STATUS_CODE_ERROR
+			s.Status.Message = span.err.Error()
+		}
+		otlpSpans[i] = s
+	}
+
+	type scopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	scope := scopeSpans{Spans: otlpSpans}
+	scope.Scope.Name = "cicero"
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]string{"service.name": self.service}),
+			},
+			"scopeSpans": []scopeSpans{scope},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := self.client.Post(self.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", self.endpoint, res.Status)
+	}
+	return nil
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+	SpanKindConsumer SpanKind = 5
+)
+
+type spanContext struct {
+	traceId [16]byte
+	spanId  [8]byte
+}
+
+type spanContextKey struct{}
+
+// A unit of work in a trace. The nil Span does nothing.
+type Span struct {
+	tracer       *Tracer
+	context      spanContext
+	parentSpanId [8]byte
+	name         string
+	kind         SpanKind
+	start, end   time.Time
+	attributes   map[string]string
+	err          error
+}
+
+// Starts a span as child of the one in the context, if any.
+// The returned context carries the new span.
+// Must be ended with `Span.End()`.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent, hasParent := ctx.Value(spanContextKey{}).(spanContext)
+
+	span := &Span{
+		tracer:     getTracer(),
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+
+	if hasParent {
+		span.context.traceId = parent.traceId
+		span.parentSpanId = parent.spanId
+	} else if _, err := rand.Read(span.context.traceId[:]); err != nil {
+		return ctx, nil
+	}
+	if _, err := rand.Read(span.context.spanId[:]); err != nil {
+		return ctx, nil
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span.context), span
+}
+
+func (self *Span) SetAttribute(key, value string) {
+	if self != nil {
+		self.attributes[key] = value
+	}
+}
+
+// Records the span with the error, if any, as its status.
+func (self *Span) End(err error) {
+	if self == nil {
+		return
+	}
+	self.end = time.Now()
+	self.err = err
+	if self.tracer != nil {
+		self.tracer.record(self)
+	}
+}
+
+// Returns the trace context in the W3C `traceparent` format
+// or an empty string if there is no span in the context.
+func Traceparent(ctx context.Context) string {
+	sc, ok := ctx.Value(spanContextKey{}).(spanContext)
+	if !ok {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(sc.traceId[:]) + "-" + hex.EncodeToString(sc.spanId[:]) + "-01"
+}
+
+// Continues the trace given in the W3C `traceparent` format.
+// Returns the context unchanged if it is invalid.
+func WithTraceparent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return ctx
+	}
+	// hex.Decode panics if the destination is too short.
+	if len(parts[1]) != 2*len(spanContext{}.traceId) || len(parts[2]) != 2*len(spanContext{}.spanId) {
+		return ctx
+	}
+
+	sc := spanContext{}
+	if n, err := hex.Decode(sc.traceId[:], []byte(parts[1])); err != nil || n != len(sc.traceId) || sc.traceId == ([16]byte{}) {
+		return ctx
+	}
+	if n, err := hex.Decode(sc.spanId[:], []byte(parts[2])); err != nil || n != len(sc.spanId) || sc.spanId == ([8]byte{}) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
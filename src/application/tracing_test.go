@@ -0,0 +1,95 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+)
+
+func TestShouldPropagateTraceparent(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", application.Traceparent(context.Background()))
+
+	traceparent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	ctx := application.WithTraceparent(context.Background(), traceparent)
+	assert.Equal(t, traceparent, application.Traceparent(ctx))
+
+	ctx, span := application.StartSpan(ctx, "child", application.SpanKindInternal)
+	defer span.End(nil)
+	child := application.Traceparent(ctx)
+	assert.Regexp(t, `^00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01$`, child)
+	assert.NotEqual(t, traceparent, child)
+
+	for _, invalid := range []string{
+		"",
+		"garbage",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-xyz-b7ad6b7169203331-01",
+		// too long for the IDs
+		"00-0af7651916cd43dd8448eb211c80319c00-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b716920333100-01",
+	} {
+		assert.Equal(t, "", application.Traceparent(application.WithTraceparent(context.Background(), invalid)), invalid)
+	}
+}
+
+func TestShouldExportSpans(t *testing.T) {
+	var received struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceId      string `json:"traceId"`
+					SpanId       string `json:"spanId"`
+					ParentSpanId string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Status       struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/v1/traces", req.URL.Path)
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	logger := zerolog.Nop()
+	tracer := application.NewTracer(server.URL, "cicero", &logger)
+	application.SetTracer(tracer)
+	defer application.SetTracer(nil)
+
+	ctx, parent := application.StartSpan(context.Background(), "parent", application.SpanKindServer)
+	_, child := application.StartSpan(ctx, "child", application.SpanKindInternal)
+	child.End(errors.New("oops"))
+	parent.End(nil)
+
+	// Flushes what is queued when done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, tracer.Start(ctx))
+
+	if assert.Len(t, received.ResourceSpans, 1) && assert.Len(t, received.ResourceSpans[0].ScopeSpans, 1) {
+		spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+		if assert.Len(t, spans, 2) {
+			assert.Equal(t, "child", spans[0].Name)
+			assert.Equal(t, "parent", spans[1].Name)
+			assert.Equal(t, spans[1].TraceId, spans[0].TraceId)
+			assert.Equal(t, spans[1].SpanId, spans[0].ParentSpanId)
+			assert.Equal(t, "", spans[1].ParentSpanId)
+			assert.Equal(t, 2, spans[0].Status.Code)
+			assert.Equal(t, "oops", spans[0].Status.Message)
+		}
+	}
+}
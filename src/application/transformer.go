@@ -0,0 +1,194 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/pkg/errors"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Rewrites the Nomad job of a Run before it is planned and submitted.
+type Transformer interface {
+	Transform(job *nomad.Job, ctx TransformContext) error
+}
+
+// What transformers know about the Run.
+type TransformContext struct {
+	// Environment variables that Cicero gives to the tasks of the Run,
+	// like its ID and the token to publish facts with.
+	Env map[string]string
+}
+
+// Creates the built-in transformer of the configured type.
+func NewTransformer(config domain.TransformerConfig) (Transformer, error) {
+	var transformer Transformer
+	switch config.Type {
+	case "nomad-defaults":
+		transformer = &nomadDefaultsTransformer{}
+	case "cicero-env":
+		transformer = &ciceroEnvTransformer{}
+	case "resource-limits":
+		transformer = &resourceLimitsTransformer{}
+	case "image-mirror":
+		transformer = &imageMirrorTransformer{}
+	default:
+		return nil, fmt.Errorf("Unknown transformer type %q", config.Type)
+	}
+
+	if len(config.Options) > 0 {
+		if err := json.Unmarshal(config.Options, transformer); err != nil {
+			return nil, errors.WithMessagef(err, "Invalid options of transformer %q", config.Name)
+		}
+	}
+
+	if mirror, ok := transformer.(*imageMirrorTransformer); ok && mirror.Mirror == "" {
+		return nil, fmt.Errorf("Transformer %q needs a mirror", config.Name)
+	}
+
+	return transformer, nil
+}
+
+// Fills in what the job leaves out.
+type nomadDefaultsTransformer struct {
+	// Used if the job does not give any.
+	Datacenters []string `json:"datacenters"`
+	// Added to the job unless it has them already.
+	Constraints []*nomad.Constraint `json:"constraints"`
+}
+
+func (self *nomadDefaultsTransformer) Transform(job *nomad.Job, _ TransformContext) error {
+	if len(job.Datacenters) == 0 {
+		job.Datacenters = append([]string{}, self.Datacenters...)
+	}
+
+constraints:
+	for _, constraint := range self.Constraints {
+		for _, existing := range job.Constraints {
+			if *existing == *constraint {
+				continue constraints
+			}
+		}
+		copied := *constraint
+		job.Constraints = append(job.Constraints, &copied)
+	}
+
+	return nil
+}
+
+// Sets Cicero's environment variables in all tasks.
+type ciceroEnvTransformer struct{}
+
+func (self *ciceroEnvTransformer) Transform(job *nomad.Job, ctx TransformContext) error {
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			if task.Env == nil {
+				task.Env = map[string]string{}
+			}
+			for k, v := range ctx.Env {
+				task.Env[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// Rejects tasks that ask for more resources than allowed.
+type resourceLimitsTransformer struct {
+	// MHz per task, 0 for no limit.
+	Cpu int `json:"cpu"`
+	// MB per task, 0 for no limit.
+	Memory int `json:"memory"`
+	// MB per task that it may use beyond its reserved memory, 0 for no limit.
+	MemoryMax int `json:"memory_max"`
+}
+
+func (self *resourceLimitsTransformer) Transform(job *nomad.Job, _ TransformContext) error {
+	invalid := InvalidJobError{}
+
+	check := func(group, task, resource string, value *int, limit int) {
+		if limit > 0 && value != nil && *value > limit {
+			invalid.Errors = append(invalid.Errors, fmt.Sprintf("Task %q in group %q asks for %d %s but at most %d are allowed", task, group, *value, resource, limit))
+		}
+	}
+
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			if task.Resources == nil {
+				continue
+			}
+			check(*group.Name, task.Name, "MHz CPU", task.Resources.CPU, self.Cpu)
+			check(*group.Name, task.Name, "MB memory", task.Resources.MemoryMB, self.Memory)
+			check(*group.Name, task.Name, "MB maximum memory", task.Resources.MemoryMaxMB, self.MemoryMax)
+		}
+	}
+
+	if len(invalid.Errors) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// Pulls the images of docker and podman tasks from a mirror.
+type imageMirrorTransformer struct {
+	// Host and optional path prefix of the mirror like `mirror.example.com/docker`.
+	Mirror string `json:"mirror"`
+	// Registries that are mirrored, all if empty.
+	Registries []string `json:"registries"`
+}
+
+func (self *imageMirrorTransformer) Transform(job *nomad.Job, _ TransformContext) error {
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			if task.Driver != "docker" && task.Driver != "podman" {
+				continue
+			}
+			if image, ok := task.Config["image"].(string); ok {
+				task.Config["image"] = self.rewrite(image)
+			}
+		}
+	}
+	return nil
+}
+
+func (self *imageMirrorTransformer) rewrite(image string) string {
+	// Podman also takes references with a transport.
+	const transport = "docker://"
+	if strings.HasPrefix(image, transport) {
+		return transport + self.rewrite(strings.TrimPrefix(image, transport))
+	}
+
+	registry, path := splitImage(image)
+
+	if len(self.Registries) > 0 {
+		mirrored := false
+		for _, r := range self.Registries {
+			if r == registry {
+				mirrored = true
+				break
+			}
+		}
+		if !mirrored {
+			return image
+		}
+	}
+
+	return strings.TrimSuffix(self.Mirror, "/") + "/" + path
+}
+
+// Splits an image reference into the registry
+// and the rest the way docker understands it.
+func splitImage(image string) (registry, path string) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+
+	if len(parts) == 1 {
+		return "docker.io", "library/" + image
+	}
+	return "docker.io", image
+}
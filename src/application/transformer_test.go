@@ -0,0 +1,119 @@
+package application_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func newTestJob() *nomad.Job {
+	cpu, memory := 500, 1024
+	return &nomad.Job{
+		Constraints: []*nomad.Constraint{nomad.NewConstraint("${attr.kernel.name}", "=", "linux")},
+		TaskGroups: []*nomad.TaskGroup{{
+			Name: stringPtr("ci"),
+			Tasks: []*nomad.Task{
+				{
+					Name:      "build",
+					Driver:    "docker",
+					Config:    map[string]interface{}{"image": "alpine:3"},
+					Resources: &nomad.Resources{CPU: &cpu, MemoryMB: &memory},
+				},
+				{
+					Name:   "push",
+					Driver: "podman",
+					Config: map[string]interface{}{"image": "docker://ghcr.io/input-output-hk/cicero:latest"},
+					Env:    map[string]string{"FOO": "bar"},
+				},
+				{
+					Name:   "nix",
+					Driver: "exec",
+					Config: map[string]interface{}{"command": "nix"},
+				},
+			},
+		}},
+	}
+}
+
+func stringPtr(str string) *string {
+	return &str
+}
+
+func transform(t *testing.T, job *nomad.Job, typ, options string) error {
+	transformer, err := application.NewTransformer(domain.TransformerConfig{Name: typ, Type: typ, Options: json.RawMessage(options)})
+	if !assert.NoError(t, err) {
+		return err
+	}
+	return transformer.Transform(job, application.TransformContext{Env: map[string]string{"CICERO_RUN_ID": "42"}})
+}
+
+func TestShouldApplyNomadDefaults(t *testing.T) {
+	t.Parallel()
+
+	job := newTestJob()
+	assert.NoError(t, transform(t, job, "nomad-defaults", `{
+		"datacenters": ["dc1"],
+		"constraints": [
+			{"LTarget": "${attr.kernel.name}", "Operand": "=", "RTarget": "linux"},
+			{"LTarget": "${meta.ci}", "Operand": "=", "RTarget": "true"}
+		]
+	}`))
+	assert.Equal(t, []string{"dc1"}, job.Datacenters)
+	assert.Equal(t, []*nomad.Constraint{
+		nomad.NewConstraint("${attr.kernel.name}", "=", "linux"),
+		nomad.NewConstraint("${meta.ci}", "=", "true"),
+	}, job.Constraints)
+
+	job.Datacenters = []string{"dc2"}
+	assert.NoError(t, transform(t, job, "nomad-defaults", `{"datacenters": ["dc1"]}`))
+	assert.Equal(t, []string{"dc2"}, job.Datacenters)
+}
+
+func TestShouldSetCiceroEnv(t *testing.T) {
+	t.Parallel()
+
+	job := newTestJob()
+	assert.NoError(t, transform(t, job, "cicero-env", ""))
+	for _, task := range job.TaskGroups[0].Tasks {
+		assert.Equal(t, "42", task.Env["CICERO_RUN_ID"], task.Name)
+	}
+	assert.Equal(t, "bar", job.TaskGroups[0].Tasks[1].Env["FOO"])
+}
+
+func TestShouldEnforceResourceLimits(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, transform(t, newTestJob(), "resource-limits", `{"cpu": 500, "memory": 2048}`))
+
+	err := transform(t, newTestJob(), "resource-limits", `{"cpu": 100, "memory": 512}`)
+	var invalidErr application.InvalidJobError
+	if assert.ErrorAs(t, err, &invalidErr) {
+		assert.Equal(t, []string{
+			`Task "build" in group "ci" asks for 500 MHz CPU but at most 100 are allowed`,
+			`Task "build" in group "ci" asks for 1024 MB memory but at most 512 are allowed`,
+		}, invalidErr.Errors)
+	}
+}
+
+func TestShouldRewriteImagesToMirror(t *testing.T) {
+	t.Parallel()
+
+	job := newTestJob()
+	assert.NoError(t, transform(t, job, "image-mirror", `{"mirror": "mirror.example.com/"}`))
+	assert.Equal(t, "mirror.example.com/library/alpine:3", job.TaskGroups[0].Tasks[0].Config["image"])
+	assert.Equal(t, "docker://mirror.example.com/input-output-hk/cicero:latest", job.TaskGroups[0].Tasks[1].Config["image"])
+	assert.NotContains(t, job.TaskGroups[0].Tasks[2].Config, "image")
+
+	job = newTestJob()
+	assert.NoError(t, transform(t, job, "image-mirror", `{"mirror": "mirror.example.com", "registries": ["docker.io"]}`))
+	assert.Equal(t, "mirror.example.com/library/alpine:3", job.TaskGroups[0].Tasks[0].Config["image"])
+	assert.Equal(t, "docker://ghcr.io/input-output-hk/cicero:latest", job.TaskGroups[0].Tasks[1].Config["image"])
+
+	_, err := application.NewTransformer(domain.TransformerConfig{Name: "mirror", Type: "image-mirror"})
+	assert.Error(t, err)
+}
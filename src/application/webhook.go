@@ -0,0 +1,150 @@
+package application
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/parser"
+	cuejson "cuelang.org/go/encoding/json"
+	"github.com/pkg/errors"
+)
+
+// Returned if a delivery was not signed with the configured secret.
+var ErrWebhookSignature = errors.New("Invalid webhook signature")
+
+// Turns events delivered by a code forge into facts.
+type Webhook interface {
+	// Checks that the delivery was signed with the secret
+	// and maps its payload to a fact value.
+	// Returns nil if the mapping does not produce a fact for this event.
+	Handle(header http.Header, body []byte) (interface{}, error)
+}
+
+type WebhookConfig struct {
+	// Any of: github, gitlab, gitea
+	Provider string
+	Secret   string
+	// CUE that evaluates to the fact value in its `fact` field.
+	// The `event` and `payload` of the delivery are in scope.
+	// Defaults to `fact: "<provider>-event": payload`.
+	Mapping string
+}
+
+var WebhookProviders = []string{"github", "gitlab", "gitea"}
+
+type webhook struct {
+	verify      func(header http.Header, body []byte) error
+	eventHeader string
+	mapping     string
+}
+
+func NewWebhook(config WebhookConfig) (Webhook, error) {
+	if config.Secret == "" {
+		return nil, fmt.Errorf("No secret given for %s webhook", config.Provider)
+	}
+
+	self := webhook{mapping: config.Mapping}
+	if self.mapping == "" {
+		self.mapping = fmt.Sprintf("fact: %q: payload", config.Provider+"-event")
+	}
+
+	switch config.Provider {
+	case "github":
+		self.eventHeader = "X-GitHub-Event"
+		self.verify = verifyHmacSha256(config.Secret, "X-Hub-Signature-256", "sha256=")
+	case "gitea":
+		self.eventHeader = "X-Gitea-Event"
+		self.verify = verifyHmacSha256(config.Secret, "X-Gitea-Signature", "")
+	case "gitlab":
+		// GitLab does not sign deliveries but sends the secret along.
+		self.eventHeader = "X-Gitlab-Event"
+		self.verify = func(header http.Header, _ []byte) error {
+			if !hmac.Equal([]byte(header.Get("X-Gitlab-Token")), []byte(config.Secret)) {
+				return ErrWebhookSignature
+			}
+			return nil
+		}
+	default:
+		return nil, fmt.Errorf("Unknown webhook provider %q, expected any of %s", config.Provider, strings.Join(WebhookProviders, ", "))
+	}
+
+	// Fail early instead of on the first delivery.
+	if _, err := parser.ParseFile(config.Provider+" webhook mapping", self.mapping); err != nil {
+		return nil, errors.WithMessagef(err, "Invalid mapping for %s webhook", config.Provider)
+	}
+
+	return &self, nil
+}
+
+func verifyHmacSha256(secret, signatureHeader, prefix string) func(http.Header, []byte) error {
+	return func(header http.Header, body []byte) error {
+		signature := header.Get(signatureHeader)
+		if !strings.HasPrefix(signature, prefix) {
+			return ErrWebhookSignature
+		}
+
+		expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+		if err != nil {
+			return ErrWebhookSignature
+		}
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if !hmac.Equal(expected, mac.Sum(nil)) {
+			return ErrWebhookSignature
+		}
+
+		return nil
+	}
+}
+
+func webhookScope(ctx *cue.Context, event, payload cue.Value) cue.Value {
+	return ctx.CompileString("{}").
+		FillPath(cue.ParsePath("event"), event).
+		FillPath(cue.ParsePath("payload"), payload)
+}
+
+func (self *webhook) Handle(header http.Header, body []byte) (interface{}, error) {
+	if err := self.verify(header, body); err != nil {
+		return nil, err
+	}
+
+	ctx := cuecontext.New()
+
+	payloadExpr, err := cuejson.Extract("payload", body)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Could not parse payload")
+	}
+	payload := ctx.BuildExpr(payloadExpr)
+
+	mapped := ctx.CompileString(self.mapping, cue.Scope(webhookScope(ctx, ctx.Encode(header.Get(self.eventHeader)), payload)))
+	if err := mapped.Err(); err != nil {
+		return nil, errors.WithMessage(err, "Could not map payload")
+	}
+
+	fact := mapped.LookupPath(cue.ParsePath("fact"))
+	if !fact.Exists() {
+		return nil, nil
+	}
+
+	if err := fact.Validate(cue.Concrete(true)); err != nil {
+		return nil, errors.WithMessage(err, "Mapping did not produce a concrete fact")
+	}
+
+	// Go through JSON so the value looks like one posted to the API.
+	var value interface{}
+	if factJson, err := fact.MarshalJSON(); err != nil {
+		return nil, errors.WithMessage(err, "Could not encode fact")
+	} else if err := json.Unmarshal(factJson, &value); err != nil {
+		return nil, errors.WithMessage(err, "Could not decode fact")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,111 @@
+package application_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/application"
+)
+
+const webhookPayload = `{"action": "opened", "number": 1}`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestShouldVerifyWebhookSignature(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		provider    string
+		header      string
+		valid       string
+		invalid     string
+		eventHeader string
+	}{
+		{"github", "X-Hub-Signature-256", "sha256=" + sign("secret", webhookPayload), "sha256=" + sign("wrong", webhookPayload), "X-GitHub-Event"},
+		{"gitea", "X-Gitea-Signature", sign("secret", webhookPayload), sign("wrong", webhookPayload), "X-Gitea-Event"},
+		{"gitlab", "X-Gitlab-Token", "secret", "wrong", "X-Gitlab-Event"},
+	} {
+		webhook, err := application.NewWebhook(application.WebhookConfig{Provider: tc.provider, Secret: "secret"})
+		if !assert.NoError(t, err, tc.provider) {
+			continue
+		}
+
+		header := http.Header{}
+		header.Set(tc.eventHeader, "pull_request")
+
+		_, err = webhook.Handle(header, []byte(webhookPayload))
+		assert.ErrorIs(t, err, application.ErrWebhookSignature, tc.provider)
+
+		header.Set(tc.header, tc.invalid)
+		_, err = webhook.Handle(header, []byte(webhookPayload))
+		assert.ErrorIs(t, err, application.ErrWebhookSignature, tc.provider)
+
+		header.Set(tc.header, tc.valid)
+		value, err := webhook.Handle(header, []byte(webhookPayload))
+		assert.NoError(t, err, tc.provider)
+		assert.Equal(t, map[string]interface{}{
+			tc.provider + "-event": map[string]interface{}{"action": "opened", "number": 1.0},
+		}, value, tc.provider)
+	}
+}
+
+func TestShouldMapWebhookPayload(t *testing.T) {
+	t.Parallel()
+
+	webhook, err := application.NewWebhook(application.WebhookConfig{
+		Provider: "gitlab",
+		Secret:   "secret",
+		Mapping: `
+			if event == "Merge Request Hook" {
+				fact: mr: {
+					action: payload.action
+					id:     payload.number
+				}
+			}
+		`,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	header := http.Header{}
+	header.Set("X-Gitlab-Token", "secret")
+
+	header.Set("X-Gitlab-Event", "Merge Request Hook")
+	value, err := webhook.Handle(header, []byte(webhookPayload))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"mr": map[string]interface{}{"action": "opened", "id": 1.0},
+	}, value)
+
+	// other events are ignored
+	header.Set("X-Gitlab-Event", "Push Hook")
+	value, err = webhook.Handle(header, []byte(webhookPayload))
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	_, err = webhook.Handle(header, []byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestShouldRejectInvalidWebhookConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, config := range []application.WebhookConfig{
+		{Provider: "github"},
+		{Provider: "bitbucket", Secret: "secret"},
+		{Provider: "github", Secret: "secret", Mapping: "fact: payload."},
+	} {
+		_, err := application.NewWebhook(config)
+		assert.Error(t, err, "%+v", config)
+	}
+}
@@ -0,0 +1,39 @@
+package cicero
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+
+	"github.com/input-output-hk/cicero/src/application/service"
+)
+
+type CheckEvaluatorCmd struct {
+	Source string `arg:"positional,required" help:"source of actions with the evaluator to check in its fragment like ./actions#nix"`
+}
+
+func (cmd *CheckEvaluatorCmd) Run(logger *zerolog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	checks, errs := service.CheckEvaluatorConformance(ctx, cmd.Source, logger)
+
+	failed := 0
+	for i, check := range checks {
+		if errs[i] == nil {
+			fmt.Printf("ok   %s\n", check)
+		} else {
+			failed++
+			fmt.Printf("FAIL %s: %s\n", check, errs[i])
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Parses mappings of the form `group=role`.
+func ParseGroupRoles(specs []string) (map[string]domain.Role, error) {
+	roles := map[string]domain.Role{}
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("Expected group=role but got %q", spec)
+		}
+
+		var role domain.Role
+		if err := role.FromString(spec[i+1:]); err != nil {
+			return nil, err
+		}
+		roles[spec[:i]] = role
+	}
+	return roles, nil
+}
@@ -31,6 +31,10 @@ func DBConnection() (PgxIface, error) {
 		return nil, errors.New("Environment variable DATABASE_URL not set or empty")
 	}
 
+	return DBConnectionFromUrl(url)
+}
+
+func DBConnectionFromUrl(url string) (*pgxpool.Pool, error) {
 	dbconfig, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parses settings of the form `key=value`.
+func ParseKeyValues(specs []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, spec := range specs {
+		i := strings.Index(spec, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("Expected key=value but got %q", spec)
+		}
+		values[spec[:i]] = spec[i+1:]
+	}
+	return values, nil
+}
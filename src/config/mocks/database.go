@@ -0,0 +1,141 @@
+package mocks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/input-output-hk/cicero/src/config"
+)
+
+// Connects to a fresh database with all migrations applied.
+//
+// Uses the server at CICERO_TEST_DATABASE_URL if set.
+// Otherwise starts a throwaway server if `initdb` and `pg_ctl` are on PATH.
+// Skips the test if neither is possible.
+func BuildDatabase(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+
+	url := config.GetenvStr("CICERO_TEST_DATABASE_URL")
+	if url == "" {
+		url = startPostgres(t)
+	}
+
+	admin, err := config.DBConnectionFromUrl(url)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when connecting to the test database server", err)
+	}
+	t.Cleanup(admin.Close)
+
+	name := "cicero_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if _, err := admin.Exec(ctx, `CREATE DATABASE `+name); err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the test database", err)
+	}
+	if _, err := admin.Exec(ctx, `DO $$ BEGIN
+		IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'cicero_api') THEN
+			CREATE ROLE cicero_api;
+		END IF;
+	END $$`); err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the API role", err)
+	}
+
+	dbUrl, err := neturl.Parse(url)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when parsing the test database URL", err)
+	}
+	dbUrl.Path = "/" + name
+
+	db, err := config.DBConnectionFromUrl(dbUrl.String())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when connecting to the test database", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		_, _ = admin.Exec(ctx, `DROP DATABASE IF EXISTS `+name)
+	})
+
+	if err := migrate(ctx, db); err != nil {
+		t.Fatalf("an error '%s' was not expected when applying migrations", err)
+	}
+
+	return db
+}
+
+func startPostgres(t *testing.T) string {
+	t.Helper()
+
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		t.Skip("Neither CICERO_TEST_DATABASE_URL is set nor initdb found on PATH")
+	}
+	pgCtl, err := exec.LookPath("pg_ctl")
+	if err != nil {
+		t.Skip("Neither CICERO_TEST_DATABASE_URL is set nor pg_ctl found on PATH")
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	if output, err := exec.Command(initdb, "-D", data, "-U", "postgres", "--auth=trust").CombinedOutput(); err != nil {
+		t.Fatalf("initdb failed: %s\n%s", err, output)
+	}
+	if output, err := exec.Command(pgCtl, "start", "-w", "-D", data,
+		"-o", fmt.Sprintf("-k %s -p %d -c listen_addresses=''", dir, port),
+	).CombinedOutput(); err != nil {
+		t.Fatalf("pg_ctl start failed: %s\n%s", err, output)
+	}
+	t.Cleanup(func() {
+		_ = exec.Command(pgCtl, "stop", "-m", "immediate", "-D", data).Run()
+	})
+
+	return fmt.Sprintf("postgres://postgres@/postgres?host=%s&port=%d&sslmode=disable", dir, port)
+}
+
+// Applies the `migrate:up` sections of all migrations in order.
+func migrate(ctx context.Context, db config.PgxIface) error {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "..", "db", "migrations")
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		up := string(content)
+		if i := strings.Index(up, "-- migrate:down"); i >= 0 {
+			up = up[:i]
+		}
+
+		if _, err := db.Exec(ctx, up); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+	}
+
+	return nil
+}
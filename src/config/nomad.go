@@ -1,6 +1,10 @@
 package config
 
-import nomad "github.com/hashicorp/nomad/api"
+import (
+	"strings"
+
+	nomad "github.com/hashicorp/nomad/api"
+)
 
 func NewNomadClient() (client *nomad.Client, err error) {
 	config := nomad.DefaultConfig()
@@ -8,3 +12,17 @@ func NewNomadClient() (client *nomad.Client, err error) {
 	client, err = nomad.NewClient(config)
 	return
 }
+
+// Parses event topics of the form `Topic` or `Topic:FilterKey`.
+// A topic without filter key matches all events of that topic.
+func ParseNomadEventTopics(specs []string) map[nomad.Topic][]string {
+	topics := map[nomad.Topic][]string{}
+	for _, spec := range specs {
+		topic, key := spec, string(nomad.TopicAll)
+		if i := strings.Index(spec, ":"); i >= 0 {
+			topic, key = spec[:i], spec[i+1:]
+		}
+		topics[nomad.Topic(topic)] = append(topics[nomad.Topic(topic)], key)
+	}
+	return topics
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Who did something and how they asked for it.
+// A nil Actor means Cicero did it on its own.
+type Actor struct {
+	Identity
+	Request *AuditRequest
+}
+
+type AuditRequest struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	RemoteAddr string `json:"remote_addr"`
+	// Set if the request came through a proxy.
+	ForwardedFor string `json:"forwarded_for,omitempty"`
+	UserAgent    string `json:"user_agent,omitempty"`
+}
+
+type AuditEventType string
+
+const (
+	AuditActionCreate AuditEventType = "action.create"
+	AuditActionUpdate AuditEventType = "action.update"
+	AuditRunCancel    AuditEventType = "run.cancel"
+	AuditFactCreate   AuditEventType = "fact.create"
+
+	AuditNotificationReplay AuditEventType = "notification.replay"
+)
+
+var AuditEventTypes = []AuditEventType{
+	AuditActionCreate,
+	AuditActionUpdate,
+	AuditRunCancel,
+	AuditFactCreate,
+	AuditNotificationReplay,
+}
+
+// Name of the actor for things Cicero does on its own.
+const AuditSystemActor = "system"
+
+type AuditEvent struct {
+	ID        uuid.UUID      `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Type      AuditEventType `json:"type"`
+	// ID of the Action, Run, Fact or Notification.
+	SubjectId uuid.UUID     `json:"subject_id"`
+	Actor     string        `json:"actor"`
+	ActorRole string        `json:"actor_role"`
+	Request   *AuditRequest `json:"request"`
+	// JSON representation of the subject before and after the change.
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+func NewAuditEvent(actor *Actor, eventType AuditEventType, subjectId uuid.UUID, before, after interface{}) AuditEvent {
+	event := AuditEvent{
+		Type:      eventType,
+		SubjectId: subjectId,
+		Actor:     AuditSystemActor,
+		Before:    before,
+		After:     after,
+	}
+	if actor != nil {
+		event.Actor = actor.Name
+		event.ActorRole = actor.Role.String()
+		event.Request = actor.Request
+	}
+	return event
+}
+
+// Top-level fields that changed, each with the value before and after.
+// Only works on events read back from the database
+// as the subjects are compared in their JSON representation.
+func (self *AuditEvent) Diff() map[string][2]interface{} {
+	before, _ := self.Before.(map[string]interface{})
+	after, _ := self.After.(map[string]interface{})
+
+	diff := map[string][2]interface{}{}
+	for k, v := range before {
+		if !reflect.DeepEqual(v, after[k]) {
+			diff[k] = [2]interface{}{v, after[k]}
+		}
+	}
+	for k, v := range after {
+		if _, ok := before[k]; !ok {
+			diff[k] = [2]interface{}{nil, v}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles are ordered: every role may do everything the roles before it may.
+type Role uint
+
+const (
+	// May read everything.
+	RoleViewer Role = iota
+	// May additionally publish facts.
+	RolePublisher
+	// May additionally create, update and cancel actions and runs
+	// and replay notifications.
+	RoleOperator
+	// May additionally manage API tokens and read the audit log.
+	RoleAdmin
+)
+
+var roleNames = []string{"viewer", "publisher", "operator", "admin"}
+
+func (self Role) String() string {
+	if int(self) < len(roleNames) {
+		return roleNames[self]
+	}
+	return fmt.Sprintf("Role(%d)", self)
+}
+
+func (self *Role) FromString(str string) error {
+	for i, name := range roleNames {
+		if name == str {
+			*self = Role(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown role %q", str)
+}
+
+// Whether this role may do everything the given role may.
+func (self Role) Includes(other Role) bool {
+	return self >= other
+}
+
+func (self *Role) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	return self.FromString(str)
+}
+
+func (self Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(self.String())
+}
+
+func (self *Role) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case string:
+		return self.FromString(src)
+	case []byte:
+		return self.FromString(string(src))
+	default:
+		return fmt.Errorf("Cannot scan %T into Role", src)
+	}
+}
+
+func (self Role) Value() (driver.Value, error) {
+	return self.String(), nil
+}
+
+// Who is making a request.
+type Identity struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	// Set if this is a job that may only publish facts for its Run.
+	Run *uuid.UUID `json:"run,omitempty"`
+}
+
+// A static token for API clients.
+// Only a hash of the token itself is stored.
+type ApiToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Role       Role       `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func (self ApiToken) Identity() Identity {
+	return Identity{Name: "token:" + self.Name, Role: self.Role}
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Name of the NotificationSink that reports commit statuses to Git forges.
+const CommitStatusSink = "commit-status"
+
+var shaRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Finds the API URLs to report commit statuses to in a fact value.
+// These are either given as `statuses_url` like in GitHub's pull request events
+// or derived from the `url` of a `repository` next to a commit SHA
+// like in the events of GitHub and Gitea.
+func FindCommitStatusUrls(value interface{}) []string {
+	urls := map[string]struct{}{}
+	findCommitStatusUrls(value, urls)
+
+	sorted := make([]string, 0, len(urls))
+	for url := range urls {
+		sorted = append(sorted, url)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func findCommitStatusUrls(value interface{}, urls map[string]struct{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		// Repositories have a template like `…/statuses/{sha}` here.
+		if url, ok := value["statuses_url"].(string); ok && !strings.Contains(url, "{") {
+			urls[url] = struct{}{}
+		}
+
+		if repository, ok := value["repository"].(map[string]interface{}); ok {
+			if url, ok := repository["url"].(string); ok && url != "" {
+				for _, sha := range []interface{}{
+					lookup(value, "pull_request", "head", "sha"),
+					lookup(value, "after"),
+					lookup(value, "sha"),
+				} {
+					if sha, ok := sha.(string); ok && shaRegexp.MatchString(sha) && strings.Trim(sha, "0") != "" {
+						urls[strings.TrimSuffix(url, "/")+"/statuses/"+sha] = struct{}{}
+						break
+					}
+				}
+			}
+		}
+
+		for _, v := range value {
+			findCommitStatusUrls(v, urls)
+		}
+	case []interface{}:
+		for _, v := range value {
+			findCommitStatusUrls(v, urls)
+		}
+	}
+}
+
+func lookup(value interface{}, path ...string) interface{} {
+	for _, key := range path {
+		if m, ok := value.(map[string]interface{}); ok {
+			value = m[key]
+		} else {
+			return nil
+		}
+	}
+	return value
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldFindCommitStatusUrls(t *testing.T) {
+	t.Parallel()
+
+	sha := "0123456789abcdef0123456789abcdef01234567"
+
+	for _, tc := range []struct {
+		name     string
+		value    interface{}
+		expected []string
+	}{
+		{"github pull request", map[string]interface{}{
+			"github-event": map[string]interface{}{
+				"repository": map[string]interface{}{
+					"url":          "https://api.github.com/repos/o/r",
+					"statuses_url": "https://api.github.com/repos/o/r/statuses/{sha}",
+				},
+				"pull_request": map[string]interface{}{
+					"statuses_url": "https://api.github.com/repos/o/r/statuses/" + sha,
+					"head":         map[string]interface{}{"sha": sha},
+				},
+			},
+		}, []string{"https://api.github.com/repos/o/r/statuses/" + sha}},
+		{"gitea push", map[string]interface{}{
+			"gitea-event": map[string]interface{}{
+				"repository": map[string]interface{}{"url": "https://gitea.example.com/api/v1/repos/o/r"},
+				"after":      sha,
+			},
+		}, []string{"https://gitea.example.com/api/v1/repos/o/r/statuses/" + sha}},
+		{"deleted branch", map[string]interface{}{
+			"repository": map[string]interface{}{"url": "https://api.github.com/repos/o/r"},
+			"after":      "0000000000000000000000000000000000000000",
+		}, []string{}},
+		{"downstream fact", map[string]interface{}{
+			"r/ci": map[string]interface{}{"start": map[string]interface{}{
+				"sha":          sha,
+				"statuses_url": "https://api.github.com/repos/o/r/statuses/" + sha,
+			}},
+		}, []string{"https://api.github.com/repos/o/r/statuses/" + sha}},
+		{"nothing", []interface{}{"foo", 1.0}, []string{}},
+	} {
+		assert.Equal(t, tc.expected, FindCommitStatusUrls(tc.value), tc.name)
+	}
+}
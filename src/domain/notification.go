@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RunStatus string
+
+const (
+	// The Run was just submitted.
+	RunPending   RunStatus = "pending"
+	RunSucceeded RunStatus = "success"
+	RunFailed    RunStatus = "failure"
+)
+
+// Where to send a Notification and when.
+// Declared in the `notify` list of an Action's meta
+// or in the server configuration.
+type NotificationRule struct {
+	// Any of: webhook, chat, email
+	Sink string `json:"sink"`
+	// URL for the webhook and chat sinks, address for the email sink.
+	Target string `json:"target"`
+	// Only notify of Runs in these states. Defaults to success and failure.
+	On []RunStatus `json:"on,omitempty"`
+	// Regular expression that the Action name must match.
+	// Only makes sense in the server configuration.
+	Action string `json:"action,omitempty"`
+}
+
+func (self NotificationRule) Matches(actionName string, status RunStatus) (bool, error) {
+	if self.Action != "" {
+		if matched, err := regexp.MatchString(self.Action, actionName); err != nil || !matched {
+			return false, err
+		}
+	}
+
+	on := self.On
+	if len(on) == 0 {
+		on = []RunStatus{RunSucceeded, RunFailed}
+	}
+	for _, on := range on {
+		if on == status {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Reads the `notify` list from the Action's meta.
+func (self ActionDefinition) NotificationRules() (rules []NotificationRule, err error) {
+	notify, ok := self.Meta["notify"]
+	if !ok {
+		return
+	}
+
+	// The meta is schemaless so go through JSON.
+	if notifyJson, err := json.Marshal(notify); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(notifyJson, &rules); err != nil {
+		return nil, fmt.Errorf("meta.notify must be a list of notification rules: %w", err)
+	}
+	return
+}
+
+// What is sent about a Run that started or ended.
+type NotificationPayload struct {
+	RunId      uuid.UUID  `json:"run_id"`
+	ActionId   uuid.UUID  `json:"action_id"`
+	ActionName string     `json:"action_name"`
+	Status     RunStatus  `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	// Link to the Run in the web UI, if its public URL is known.
+	Url string `json:"url,omitempty"`
+}
+
+// Short human readable summary.
+func (self NotificationPayload) Text() string {
+	var text string
+	if self.FinishedAt == nil {
+		text = fmt.Sprintf("Run %s of %s started", self.RunId, self.ActionName)
+	} else {
+		verb := "succeeded"
+		if self.Status == RunFailed {
+			verb = "failed"
+		}
+		text = fmt.Sprintf("Run %s of %s %s after %s", self.RunId, self.ActionName, verb, self.FinishedAt.Sub(self.CreatedAt).Round(time.Second))
+	}
+	if self.Url != "" {
+		text += ": " + self.Url
+	}
+	return text
+}
+
+type NotificationState string
+
+const (
+	NotificationPending   NotificationState = "pending"
+	NotificationDelivered NotificationState = "delivered"
+	// Given up after too many attempts.
+	NotificationFailed NotificationState = "failed"
+	// Not delivered because a newer one made it obsolete.
+	NotificationSuperseded NotificationState = "superseded"
+)
+
+type Notification struct {
+	ID            uuid.UUID           `json:"id"`
+	CreatedAt     time.Time           `json:"created_at"`
+	RunId         uuid.UUID           `json:"run_id"`
+	Sink          string              `json:"sink"`
+	Target        string              `json:"target"`
+	Payload       NotificationPayload `json:"payload"`
+	State         NotificationState   `json:"state"`
+	Attempts      int                 `json:"attempts"`
+	NextAttemptAt time.Time           `json:"next_attempt_at"`
+	DeliveredAt   *time.Time          `json:"delivered_at"`
+}
+
+type NotificationAttempt struct {
+	ID             uuid.UUID `json:"id"`
+	NotificationId uuid.UUID `json:"notification_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	// Nil if the attempt succeeded.
+	Error *string `json:"error"`
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+type ApiTokenRepository interface {
+	WithQuerier(config.PgxIface) ApiTokenRepository
+
+	GetAll() ([]*domain.ApiToken, error)
+	GetByHash([]byte) (domain.ApiToken, error)
+	Save(*domain.ApiToken, []byte) error
+	Touch(uuid.UUID) error
+	Delete(uuid.UUID) error
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+// Zero values match everything.
+type AuditEventFilter struct {
+	Type      domain.AuditEventType
+	SubjectId *uuid.UUID
+	Actor     string
+	Since     *time.Time
+	Until     *time.Time
+}
+
+type AuditEventRepository interface {
+	WithQuerier(config.PgxIface) AuditEventRepository
+
+	Get(AuditEventFilter, *Page) ([]*domain.AuditEvent, error)
+	Save(*domain.AuditEvent) error
+}
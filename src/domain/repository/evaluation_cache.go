@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/input-output-hk/cicero/src/config"
+)
+
+type EvaluationCacheRepository interface {
+	WithQuerier(config.PgxIface) EvaluationCacheRepository
+
+	// Returns the output and the evaluator that printed it.
+	Get(key []byte) (output []byte, evaluator string, err error)
+	Save(key []byte, source, revision, evaluator string, output []byte) error
+	// Deletes outputs that were cached before the given time.
+	Prune(before time.Time) (int64, error)
+}
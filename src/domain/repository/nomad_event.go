@@ -1,7 +1,8 @@
 package repository
 
 import (
-	"github.com/google/uuid"
+	"time"
+
 	nomad "github.com/hashicorp/nomad/api"
 	"github.com/input-output-hk/cicero/src/config"
 )
@@ -9,7 +10,7 @@ import (
 type NomadEventRepository interface {
 	WithQuerier(config.PgxIface) NomadEventRepository
 
-	Save(*nomad.Event) error
+	Save(*nomad.Event) (bool, error)
 	GetLastNomadEvent() (uint64, error)
-	GetEventAllocByNomadJobId(uuid.UUID) ([]map[string]interface{}, error)
+	Prune(time.Time) (int64, error)
 }
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+type NotificationRepository interface {
+	WithQuerier(config.PgxIface) NotificationRepository
+
+	GetById(uuid.UUID) (domain.Notification, error)
+	// Returns all if the state is empty.
+	Get(domain.NotificationState, *Page) ([]*domain.Notification, error)
+	// Pending ones whose next attempt is due.
+	// Their next attempt is postponed by the lease
+	// so that no other instance attempts them while they are being delivered.
+	Claim(limit int, lease time.Duration) ([]*domain.Notification, error)
+	GetAttempts(uuid.UUID) ([]*domain.NotificationAttempt, error)
+	Save(*domain.Notification) error
+	Update(*domain.Notification) error
+	SaveAttempt(*domain.NotificationAttempt) error
+	// Marks pending ones of the Run for the same sink and target as superseded.
+	Supersede(runId uuid.UUID, sink, target string) error
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 
 	"github.com/input-output-hk/cicero/src/config"
@@ -16,8 +18,11 @@ type RunRepository interface {
 	GetInputFactIdsByNomadJobId(uuid.UUID) (RunInputFactIds, error)
 	GetAll(*Page) ([]*domain.Run, error)
 	GetByInputFactIds([]*uuid.UUID, bool, *Page) ([]*domain.Run, error)
+	GetPastDeadline(time.Time) ([]*domain.Run, error)
 	Save(*domain.Run, map[string]interface{}) error
 	Update(*domain.Run) error
+	End(*domain.Run) (bool, error)
+	Stop(*domain.Run) (bool, error)
 }
 
 type RunInputFactIds map[string][]uuid.UUID
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	nomad "github.com/hashicorp/nomad/api"
+
+	"github.com/input-output-hk/cicero/src/config"
+)
+
+type RunAllocationRepository interface {
+	WithQuerier(config.PgxIface) RunAllocationRepository
+
+	GetByRunId(uuid.UUID) ([]*nomad.Allocation, error)
+	Save(uuid.UUID, *nomad.Allocation) error
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+type RunDefinitionRepository interface {
+	WithQuerier(config.PgxIface) RunDefinitionRepository
+
+	GetByRunId(uuid.UUID) (domain.RunDefinition, error)
+	Save(uuid.UUID, *domain.RunDefinition) error
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+type RunPlanRepository interface {
+	WithQuerier(config.PgxIface) RunPlanRepository
+
+	GetByRunId(uuid.UUID) (domain.RunPlan, error)
+	Save(uuid.UUID, *domain.RunPlan) error
+}
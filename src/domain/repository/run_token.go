@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+)
+
+type RunTokenRepository interface {
+	WithQuerier(config.PgxIface) RunTokenRepository
+
+	// Only finds tokens that have not expired.
+	GetRunIdByHash([]byte) (uuid.UUID, error)
+	Save(uuid.UUID, []byte, time.Duration) error
+	Delete(uuid.UUID) error
+}
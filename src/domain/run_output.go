@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RunOutcome string
+
+const (
+	RunOutcomeSuccess RunOutcome = "success"
+	RunOutcomeFailure RunOutcome = "failure"
+	// Stopped through the API.
+	RunOutcomeCanceled RunOutcome = "canceled"
+	// Stopped because it was still running at its deadline.
+	RunOutcomeTimedOut RunOutcome = "timed_out"
+	// Nomad lost the allocation, for example because its node went down.
+	RunOutcomeLost RunOutcome = "lost"
+)
+
+// Only successful Runs are notified of as such.
+func (self RunOutcome) Status() RunStatus {
+	if self == RunOutcomeSuccess {
+		return RunSucceeded
+	}
+	return RunFailed
+}
+
+// Chooses what to publish for the outcome of a Run
+// that was ended by an allocation of the given task group
+// and tells whether it is a template.
+// Returns nil if nothing is to be published.
+func (self RunOutput) Select(outcome RunOutcome, group string) (*interface{}, bool) {
+	outputs := []RunGroupOutput{{
+		Failure:  self.Failure,
+		Success:  self.Success,
+		Canceled: self.Canceled,
+		TimedOut: self.TimedOut,
+		Lost:     self.Lost,
+		Template: self.Template,
+	}}
+	if groupOutput, ok := self.Groups[group]; ok {
+		outputs = append([]RunGroupOutput{groupOutput}, outputs...)
+	}
+
+	for _, output := range outputs {
+		if value := output.byOutcome(outcome); value != nil {
+			return value, output.Template
+		}
+	}
+
+	switch outcome {
+	case RunOutcomeTimedOut, RunOutcomeLost:
+		for _, output := range outputs {
+			if output.Failure != nil {
+				return output.Failure, output.Template
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func (self RunGroupOutput) byOutcome(outcome RunOutcome) *interface{} {
+	switch outcome {
+	case RunOutcomeSuccess:
+		return self.Success
+	case RunOutcomeCanceled:
+		return self.Canceled
+	case RunOutcomeTimedOut:
+		return self.TimedOut
+	case RunOutcomeLost:
+		return self.Lost
+	default:
+		return self.Failure
+	}
+}
+
+// Reads the duration after which Runs are stopped from the Action's meta.
+// Returns 0 if it has no `timeout`.
+func (self ActionDefinition) RunTimeout() (time.Duration, error) {
+	timeout, ok := self.Meta["timeout"]
+	if !ok {
+		return 0, nil
+	}
+
+	str, ok := timeout.(string)
+	if !ok {
+		return 0, fmt.Errorf("meta.timeout must be a duration like \"1h30m\"")
+	}
+
+	duration, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("meta.timeout must be a duration like \"1h30m\": %w", err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("meta.timeout must not be negative")
+	}
+	return duration, nil
+}
+
+// What strings in a RunOutput that is a template can reference, like `{{.RunId}}`.
+type RunOutputContext struct {
+	RunId      uuid.UUID
+	ActionId   uuid.UUID
+	ActionName string
+	Outcome    RunOutcome
+	// Name of the task group whose allocation ended the Run, if any.
+	Group      string
+	CreatedAt  time.Time
+	FinishedAt time.Time
+	// Highest exit code of the tasks, nil if none of them terminated.
+	ExitCode *int
+}
+
+// A template that consists of nothing but a field.
+var runOutputFieldTemplate = regexp.MustCompile(`^\{\{\s*\.(\w+)\s*\}\}$`)
+
+// Renders the templates in the strings of the value.
+// A string that only references a field is replaced by its value
+// so that `"{{.ExitCode}}"` becomes a number.
+// Strings that are not valid templates are left as they are.
+func (self RunOutputContext) Render(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			rendered[k] = self.Render(v)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(value))
+		for i, v := range value {
+			rendered[i] = self.Render(v)
+		}
+		return rendered
+	case string:
+		return self.renderString(value)
+	default:
+		return value
+	}
+}
+
+func (self RunOutputContext) renderString(str string) interface{} {
+	if !strings.Contains(str, "{{") {
+		return str
+	}
+
+	if match := runOutputFieldTemplate.FindStringSubmatch(str); match != nil {
+		if field := reflect.ValueOf(self).FieldByName(match[1]); field.IsValid() {
+			// Go through JSON to get the same types as the rest of the fact.
+			var value interface{}
+			if fieldJson, err := json.Marshal(field.Interface()); err == nil && json.Unmarshal(fieldJson, &value) == nil {
+				return value
+			}
+		}
+		return str
+	}
+
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(str)
+	if err != nil {
+		return str
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, self); err != nil {
+		return str
+	}
+	return rendered.String()
+}
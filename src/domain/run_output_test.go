@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldSelectRunOutput(t *testing.T) {
+	t.Parallel()
+
+	value := func(v interface{}) *interface{} { return &v }
+
+	output := RunOutput{
+		Success:  value("success"),
+		Failure:  value("failure"),
+		TimedOut: value("timed out"),
+		Groups: map[string]RunGroupOutput{
+			"deploy": {Success: value("deployed"), Failure: value("not deployed")},
+		},
+		Template: true,
+	}
+
+	for _, tc := range []struct {
+		outcome  RunOutcome
+		group    string
+		expected interface{}
+		template bool
+	}{
+		{RunOutcomeSuccess, "build", "success", true},
+		{RunOutcomeFailure, "build", "failure", true},
+		{RunOutcomeCanceled, "build", nil, false},
+		{RunOutcomeTimedOut, "build", "timed out", true},
+		{RunOutcomeLost, "build", "failure", true},
+		{RunOutcomeSuccess, "deploy", "deployed", false},
+		{RunOutcomeTimedOut, "deploy", "timed out", true},
+		{RunOutcomeLost, "deploy", "not deployed", false},
+	} {
+		selected, template := output.Select(tc.outcome, tc.group)
+		if tc.expected == nil {
+			assert.Nil(t, selected, "%s in %s", tc.outcome, tc.group)
+		} else if assert.NotNil(t, selected, "%s in %s", tc.outcome, tc.group) {
+			assert.Equal(t, tc.expected, *selected, "%s in %s", tc.outcome, tc.group)
+		}
+		assert.Equal(t, tc.template, template, "%s in %s", tc.outcome, tc.group)
+	}
+
+	assert.Equal(t, RunSucceeded, RunOutcomeSuccess.Status())
+	assert.Equal(t, RunFailed, RunOutcomeLost.Status())
+}
+
+func TestShouldRenderRunOutput(t *testing.T) {
+	t.Parallel()
+
+	exitCode := 2
+	ctx := RunOutputContext{
+		RunId:      uuid.MustParse("b7a9d0ef-1e36-4c0c-9b2c-2a54ac2dc8e5"),
+		ActionName: "ci",
+		Outcome:    RunOutcomeFailure,
+		FinishedAt: time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC),
+		ExitCode:   &exitCode,
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"ci": map[string]interface{}{
+			"run":      "b7a9d0ef-1e36-4c0c-9b2c-2a54ac2dc8e5",
+			"code":     float64(2),
+			"message":  "ci failure with 2 at 2022-03-04",
+			"list":     []interface{}{"failure", true},
+			"invalid":  "{{.Nope}}",
+			"unclosed": "{{.RunId",
+			"plain":    "no template",
+		},
+	}, ctx.Render(map[string]interface{}{
+		"ci": map[string]interface{}{
+			"run":      "{{.RunId}}",
+			"code":     "{{ .ExitCode }}",
+			"message":  `{{.ActionName}} {{.Outcome}} with {{.ExitCode}} at {{.FinishedAt.Format "2006-01-02"}}`,
+			"list":     []interface{}{"{{.Outcome}}", true},
+			"invalid":  "{{.Nope}}",
+			"unclosed": "{{.RunId",
+			"plain":    "no template",
+		},
+	}))
+
+	assert.Nil(t, RunOutputContext{}.Render("{{.ExitCode}}"))
+}
+
+func TestShouldReadRunTimeout(t *testing.T) {
+	t.Parallel()
+
+	timeout, err := ActionDefinition{}.RunTimeout()
+	assert.NoError(t, err)
+	assert.Zero(t, timeout)
+
+	timeout, err = ActionDefinition{Meta: map[string]interface{}{"timeout": "1h30m"}}.RunTimeout()
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, timeout)
+
+	_, err = ActionDefinition{Meta: map[string]interface{}{"timeout": 60}}.RunTimeout()
+	assert.Error(t, err)
+
+	_, err = ActionDefinition{Meta: map[string]interface{}{"timeout": "-1h"}}.RunTimeout()
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	cueformat "cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/jsonschema"
+)
+
+// CUE that describes what facts look like.
+// Unmarshals from a string of CUE or an object of JSON Schema,
+// which is converted to CUE so that both can be used the same way.
+type InputSchema string
+
+// Compiles the schema in the given context
+// so that it can be unified with values from it.
+func (self InputSchema) ValueIn(ctx *cue.Context) cue.Value {
+	if self == "" {
+		return ctx.CompileString("_")
+	}
+	return ctx.CompileString(string(self))
+}
+
+func (self InputSchema) Value() cue.Value {
+	return self.ValueIn(cuecontext.New())
+}
+
+func (self *InputSchema) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		schema := InputSchema(str)
+		if err := schema.Value().Err(); err != nil {
+			return err
+		}
+		*self = schema
+		return nil
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("Schema must be a string of CUE or an object of JSON Schema")
+	}
+
+	file, err := jsonschema.Extract(cuecontext.New().CompileBytes(data), &jsonschema.Config{})
+	if err != nil {
+		return fmt.Errorf("Invalid JSON Schema: %w", err)
+	}
+
+	syntax, err := cueformat.Node(file, cueformat.Simplify())
+	if err != nil {
+		return err
+	}
+
+	*self = InputSchema(syntax)
+	return nil
+}
+
+func (self InputSchema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(self))
+}
+
+// The match constrained by the schema, if any.
+// Describes the facts that may be published for this input.
+// Which facts are used as the input is decided by the match alone.
+func (self *InputDefinition) MatchWithSchema() cue.Value {
+	match := self.Match.WithoutInputs()
+	if self.Schema == "" {
+		return match
+	}
+	return match.Unify(self.Schema.ValueIn(match.Context()))
+}
+
+// Makes up a fact that is likely to be used as this input.
+func (self InputDefinition) Example() interface{} {
+	return SynthesizeValue(self.MatchWithSchema())
+}
+
+// Makes up a value that is likely to be an instance of the given one
+// by choosing its default, its concrete value or the zero value of its type.
+func SynthesizeValue(value cue.Value) interface{} {
+	if def, ok := value.Default(); ok {
+		value = def
+	}
+
+	kind := value.IncompleteKind()
+
+	if value.IsConcrete() && kind != cue.StructKind && kind != cue.ListKind {
+		var decoded interface{}
+		if err := value.Decode(&decoded); err == nil {
+			return decoded
+		}
+	}
+
+	switch {
+	case kind&cue.StructKind != 0:
+		obj := map[string]interface{}{}
+		if iter, err := value.Fields(); err == nil {
+			for iter.Next() {
+				obj[iter.Label()] = SynthesizeValue(iter.Value())
+			}
+		}
+		return obj
+	case kind&cue.ListKind != 0:
+		return []interface{}{}
+	case kind&(cue.StringKind|cue.BytesKind) != 0:
+		return ""
+	case kind&cue.NumberKind != 0:
+		return 0
+	case kind&cue.BoolKind != 0:
+		return false
+	default:
+		return nil
+	}
+}
+
+// Declares what facts look like that are published through the API.
+type FactSchema struct {
+	Name string `json:"name"`
+	// Selects the facts the schema applies to; all if empty.
+	Match  InputDefinitionMatch `json:"match"`
+	Schema InputSchema          `json:"schema"`
+}
+
+// Returns an error if the value is selected by the match
+// but is not a concrete instance of the schema.
+func (self FactSchema) Check(value interface{}) error {
+	ctx := cuecontext.New()
+
+	valueCue, err := EncodeFactValue(ctx, value)
+	if err != nil {
+		return err
+	}
+
+	if self.Match != "" {
+		match := self.Match.WithoutInputs()
+		if matchValue, err := EncodeFactValue(match.Context(), value); err != nil {
+			return err
+		} else if match.Subsume(matchValue, cue.Final()) != nil {
+			return nil
+		}
+	}
+
+	if err := self.Schema.ValueIn(ctx).Unify(valueCue).Validate(cue.Concrete(true)); err != nil {
+		return fmt.Errorf("Fact does not conform to schema %q: %w", self.Name, err)
+	}
+
+	return nil
+}
+
+// Encodes the value of a fact as if it was parsed from JSON.
+// Unlike (*cue.Context).Encode this makes whole numbers
+// that were decoded into float64 satisfy `int`.
+func EncodeFactValue(ctx *cue.Context, value interface{}) (cue.Value, error) {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	valueCue := ctx.CompileBytes(valueJson)
+	return valueCue, valueCue.Err()
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldUnmarshalInputSchema(t *testing.T) {
+	t.Parallel()
+
+	var input InputDefinition
+	if !assert.NoError(t, json.Unmarshal([]byte(`{
+		"match": "ref: =~\"^refs/\"",
+		"schema": {
+			"type": "object",
+			"required": ["ref", "count"],
+			"properties": {
+				"ref": {"type": "string"},
+				"count": {"type": "integer", "default": 3},
+				"draft": {"type": "boolean"}
+			}
+		}
+	}`), &input)) {
+		return
+	}
+
+	assert.Contains(t, string(input.Schema), `count:  int | *3`)
+	assert.Contains(t, string(input.Schema), `draft?: bool`)
+	assert.Equal(t, map[string]interface{}{"ref": "", "count": 3}, input.Example())
+
+	// The converted schema survives a round trip as CUE.
+	inputJson, err := json.Marshal(input)
+	assert.NoError(t, err)
+	var roundTrip InputDefinition
+	assert.NoError(t, json.Unmarshal(inputJson, &roundTrip))
+	assert.Equal(t, input.Schema, roundTrip.Schema)
+
+	// Definitions are embedded like this by the CUE evaluator.
+	assert.NoError(t, json.Unmarshal([]byte(`{"match": "_", "schema": "{\n\t_#def\n\t_#def: {\n\t\tping: string\n\t}\n}"}`), &input))
+	assert.Equal(t, map[string]interface{}{"ping": ""}, input.Example())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"match": "_", "schema": "foo: "}`), &input))
+	assert.Error(t, json.Unmarshal([]byte(`{"match": "_", "schema": 1}`), &input))
+}
+
+func TestShouldCheckFactSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := FactSchema{
+		Name:   "push",
+		Match:  `push: _`,
+		Schema: `push: {ref: string, commits: int}`,
+	}
+
+	var value interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"push": {"ref": "main", "commits": 2}}`), &value))
+	assert.NoError(t, schema.Check(value))
+
+	assert.NoError(t, schema.Check(map[string]interface{}{"other": 1}), "not selected by the match")
+
+	assert.EqualError(t,
+		schema.Check(map[string]interface{}{"push": map[string]interface{}{"ref": "main"}}),
+		`Fact does not conform to schema "push": push.commits: incomplete value int`,
+	)
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Type of the transformers given with `--transform` that are run
+// on the evaluator output and not configured declaratively.
+const ExternalTransformer = "external"
+
+// A built-in transformer that rewrites the Nomad job of a Run.
+// Declared in the server configuration under a name
+// that Actions select in the `transform` list of their meta.
+type TransformerConfig struct {
+	Name string `json:"name"`
+	// Any of: nomad-defaults, cicero-env, resource-limits, image-mirror
+	Type string `json:"type"`
+	// Applied to Actions that do not select any transformers.
+	Default bool `json:"default,omitempty"`
+	// Applied to all Actions, after those they select.
+	Enforce bool `json:"enforce,omitempty"`
+	// Depend on the type.
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// Reads the names of the transformers from the `transform` list in the Action's meta.
+// Returns nil if the Action does not select any.
+func (self ActionDefinition) TransformerNames() (names []string, err error) {
+	transform, ok := self.Meta["transform"]
+	if !ok {
+		return
+	}
+
+	// The meta is schemaless so go through JSON.
+	if transformJson, err := json.Marshal(transform); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(transformJson, &names); err != nil {
+		return nil, fmt.Errorf("meta.transform must be a list of transformer names: %w", err)
+	}
+	if names == nil {
+		names = []string{}
+	}
+	return
+}
+
+// Chooses the transformers for the Action's Runs in the order they are applied.
+func TransformerChain(configs []TransformerConfig, action ActionDefinition) ([]TransformerConfig, error) {
+	names, err := action.TransformerNames()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]TransformerConfig{}
+	for _, config := range configs {
+		byName[config.Name] = config
+	}
+
+	chain := []TransformerConfig{}
+	selected := map[string]bool{}
+	// External transformers always run first as they see the evaluator output.
+	for _, config := range configs {
+		if config.Type == ExternalTransformer {
+			chain = append(chain, config)
+			selected[config.Name] = true
+		}
+	}
+	for _, name := range names {
+		if config, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("meta.transform selects unknown transformer %q", name)
+		} else if !selected[name] {
+			chain = append(chain, config)
+			selected[name] = true
+		}
+	}
+	for _, config := range configs {
+		if selected[config.Name] {
+			continue
+		}
+		if config.Enforce || (names == nil && config.Default) {
+			chain = append(chain, config)
+		}
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldChooseTransformerChain(t *testing.T) {
+	t.Parallel()
+
+	configs := []TransformerConfig{
+		{Name: "./transform.sh", Type: ExternalTransformer, Enforce: true},
+		{Name: "defaults", Type: "nomad-defaults", Default: true},
+		{Name: "mirror", Type: "image-mirror"},
+		{Name: "env", Type: "cicero-env", Enforce: true},
+		{Name: "limits", Type: "resource-limits", Enforce: true},
+	}
+
+	names := func(chain []TransformerConfig) (names []string) {
+		for _, config := range chain {
+			names = append(names, config.Name)
+		}
+		return
+	}
+
+	for _, tc := range []struct {
+		name     string
+		meta     map[string]interface{}
+		expected []string
+	}{
+		{"nothing selected", nil, []string{"./transform.sh", "defaults", "env", "limits"}},
+		{"selected", map[string]interface{}{"transform": []interface{}{"mirror", "limits"}}, []string{"./transform.sh", "mirror", "limits", "env"}},
+		{"none selected", map[string]interface{}{"transform": []interface{}{}}, []string{"./transform.sh", "env", "limits"}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			chain, err := TransformerChain(configs, ActionDefinition{Meta: tc.meta})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, names(chain))
+		})
+	}
+
+	_, err := TransformerChain(configs, ActionDefinition{Meta: map[string]interface{}{"transform": []interface{}{"unknown"}}})
+	assert.EqualError(t, err, `meta.transform selects unknown transformer "unknown"`)
+
+	_, err = TransformerChain(configs, ActionDefinition{Meta: map[string]interface{}{"transform": "mirror"}})
+	assert.Error(t, err)
+}
@@ -119,6 +119,7 @@ type InputDefinition struct {
 	Not      bool                  `json:"not"`
 	Optional bool                  `json:"optional"`
 	Match    InputDefinitionMatch  `json:"match"`
+	Schema   InputSchema           `json:"schema,omitempty"`
 }
 
 type ActionDefinition struct {
@@ -126,20 +127,110 @@ type ActionDefinition struct {
 	Inputs map[string]InputDefinition `json:"inputs"`
 }
 
+// Facts published when a Run ends, chosen by its outcome.
 type RunOutput struct {
 	Failure *interface{} `json:"failure"`
 	Success *interface{} `json:"success"`
+	// Nothing is published for canceled Runs unless given.
+	Canceled *interface{} `json:"canceled,omitempty"`
+	// The failure is published for timed out and lost Runs unless given.
+	TimedOut *interface{} `json:"timed_out,omitempty"`
+	Lost     *interface{} `json:"lost,omitempty"`
+	// Outputs by task group that take precedence
+	// if an allocation of that group ended the Run.
+	Groups map[string]RunGroupOutput `json:"groups,omitempty"`
+	// Whether strings in the outputs above are Go templates, see RunOutputContext.
+	// Those of groups are only if their group says so.
+	Template bool `json:"template,omitempty"`
+}
+
+// Like RunOutput but without groups of its own.
+type RunGroupOutput struct {
+	Failure  *interface{} `json:"failure,omitempty"`
+	Success  *interface{} `json:"success,omitempty"`
+	Canceled *interface{} `json:"canceled,omitempty"`
+	TimedOut *interface{} `json:"timed_out,omitempty"`
+	Lost     *interface{} `json:"lost,omitempty"`
+	Template bool         `json:"template,omitempty"`
 }
 
 type RunDefinition struct {
 	Output RunOutput  `json:"output"`
 	Job    *nomad.Job `json:"job"`
+	// Not printed by the evaluator but recorded by Cicero
+	// so that the Run can be reproduced.
+	Evaluator string                 `json:"evaluator"`
+	Revision  string                 `json:"revision"`
+	Inputs    map[string]interface{} `json:"inputs"`
 }
 
 func (s *RunDefinition) IsDecision() bool {
 	return s.Job == nil
 }
 
+// The result of asking Nomad to plan a Run's job.
+type RunPlan struct {
+	// Left out of the API documentation because the schema generator
+	// does not terminate on recursive types like `nomad.ObjectDiff`.
+	Diff           *nomad.JobDiff                     `json:"diff" jsonschema:"-"`
+	FailedTGAllocs map[string]*nomad.AllocationMetric `json:"failed_tg_allocs"`
+	Warnings       string                             `json:"warnings"`
+}
+
+// Whether Nomad was able to place all task groups.
+func (s *RunPlan) IsPlaceable() bool {
+	return len(s.FailedTGAllocs) == 0
+}
+
+// The result of a dry run of an Action against the current facts.
+type ActionPlan struct {
+	Runnable bool                   `json:"runnable"`
+	Inputs   map[string]interface{} `json:"inputs"`
+	Output   *RunOutput             `json:"output"`
+	Job      *nomad.Job             `json:"job"`
+	Plan     *RunPlan               `json:"plan"`
+}
+
+// The result of checking the Actions in a source without creating them.
+type SourceValidation struct {
+	Source   string             `json:"source"`
+	Revision string             `json:"revision"`
+	Valid    bool               `json:"valid"`
+	Actions  []ActionValidation `json:"actions"`
+}
+
+type ActionValidation struct {
+	Name string `json:"name"`
+	// Whether there are no errors, warnings are fine.
+	Valid    bool                `json:"valid"`
+	Problems []ValidationProblem `json:"problems"`
+}
+
+type ValidationSeverity string
+
+const (
+	ValidationError   ValidationSeverity = "error"
+	ValidationWarning ValidationSeverity = "warning"
+)
+
+type ValidationProblem struct {
+	Severity ValidationSeverity `json:"severity"`
+	// Name of the input the problem is about, if any.
+	Input   string `json:"input,omitempty"`
+	Message string `json:"message"`
+}
+
+func (self *ActionValidation) Add(severity ValidationSeverity, input, format string, args ...interface{}) {
+	self.Problems = append(self.Problems, ValidationProblem{
+		Severity: severity,
+		Input:    input,
+		Message:  fmt.Sprintf(format, args...),
+	})
+	if severity == ValidationError {
+		self.Valid = false
+	}
+}
+
 type Fact struct {
 	ID         uuid.UUID   `json:"id"`
 	RunId      *uuid.UUID  `json:"run_id,omitempty"`
@@ -150,9 +241,12 @@ type Fact struct {
 }
 
 type Action struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Source    string    `json:"source"`
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Source string    `json:"source"`
+	// What the source was resolved to when the action was created.
+	// Empty for actions that are evaluated at whatever the source is at.
+	Revision  string    `json:"revision"`
 	CreatedAt time.Time `json:"created_at"`
 	Active    bool      `json:"active"`
 	ActionDefinition
@@ -163,4 +257,10 @@ type Run struct {
 	ActionId   uuid.UUID  `json:"action_id"`
 	CreatedAt  time.Time  `json:"created_at"`
 	FinishedAt *time.Time `json:"finished_at"`
+	// Transformers that were applied to the Run's job in this order.
+	Transformers []TransformerConfig `json:"transformers"`
+	// When the Run is stopped if it has not ended by then.
+	Deadline *time.Time `json:"deadline"`
+	// How the Run ended. Set before it ended if it was stopped.
+	Outcome *RunOutcome `json:"outcome"`
 }
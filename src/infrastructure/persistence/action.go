@@ -80,14 +80,14 @@ func (a *actionRepository) Save(action *domain.Action) error {
 	} else {
 		var sql string
 		if action.ID == (uuid.UUID{}) {
-			sql = `INSERT INTO action (    name, source, inputs) VALUES (    $2, $3, $4) RETURNING id, created_at`
+			sql = `INSERT INTO action (    name, source, revision, inputs) VALUES (    $2, $3, $4, $5) RETURNING id, created_at`
 		} else {
-			sql = `INSERT INTO action (id, name, source, inputs) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+			sql = `INSERT INTO action (id, name, source, revision, inputs) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
 		}
 		return a.DB.QueryRow(
 			context.Background(),
 			sql,
-			action.ID, action.Name, action.Source, inputs,
+			action.ID, action.Name, action.Source, action.Revision, inputs,
 		).Scan(&action.ID, &action.CreatedAt)
 	}
 }
@@ -60,9 +60,10 @@ func TestShouldSaveAction(t *testing.T) {
 	actionInputs := make(map[string]domain.InputDefinition)
 	actionInputs["inputs"] = inputs
 	action := domain.Action{
-		ID:     actionId,
-		Name:   "Name",
-		Source: "Source",
+		ID:       actionId,
+		Name:     "Name",
+		Source:   "Source",
+		Revision: "git:0123456789abcdef0123456789abcdef01234567",
 		ActionDefinition: domain.ActionDefinition{
 			Meta:   map[string]interface{}{},
 			Inputs: actionInputs,
@@ -76,7 +77,7 @@ func TestShouldSaveAction(t *testing.T) {
 	}
 	mock, _ := mocks.BuildTransaction(context.Background(), t)
 	rows := mock.NewRows([]string{"id", "created_at"}).AddRow(actionId, dateTime)
-	mock.ExpectQuery("INSERT INTO action").WithArgs(action.ID, action.Name, action.Source, marshalInputs).WillReturnRows(rows)
+	mock.ExpectQuery("INSERT INTO action").WithArgs(action.ID, action.Name, action.Source, action.Revision, marshalInputs).WillReturnRows(rows)
 	mock.ExpectCommit()
 	repository := NewActionRepository(mock)
 
@@ -0,0 +1,72 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type apiTokenRepository struct {
+	DB config.PgxIface
+}
+
+func NewApiTokenRepository(db config.PgxIface) repository.ApiTokenRepository {
+	return apiTokenRepository{db}
+}
+
+func (a apiTokenRepository) WithQuerier(querier config.PgxIface) repository.ApiTokenRepository {
+	return apiTokenRepository{querier}
+}
+
+func (a apiTokenRepository) GetAll() (tokens []*domain.ApiToken, err error) {
+	err = pgxscan.Select(
+		context.Background(), a.DB, &tokens,
+		`SELECT id, name, role, created_at, last_used_at FROM api_token ORDER BY name`,
+	)
+	return
+}
+
+func (a apiTokenRepository) GetByHash(hash []byte) (token domain.ApiToken, err error) {
+	err = pgxscan.Get(
+		context.Background(), a.DB, &token,
+		`SELECT id, name, role, created_at, last_used_at FROM api_token WHERE hash = $1`,
+		hash,
+	)
+	return
+}
+
+func (a apiTokenRepository) Save(token *domain.ApiToken, hash []byte) error {
+	return a.DB.QueryRow(
+		context.Background(),
+		`INSERT INTO api_token (name, hash, role) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		token.Name, hash, token.Role,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (a apiTokenRepository) Touch(id uuid.UUID) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`UPDATE api_token SET last_used_at = STATEMENT_TIMESTAMP() WHERE id = $1`,
+		id,
+	)
+	return
+}
+
+func (a apiTokenRepository) Delete(id uuid.UUID) error {
+	if tag, err := a.DB.Exec(
+		context.Background(),
+		`DELETE FROM api_token WHERE id = $1`,
+		id,
+	); err != nil {
+		return err
+	} else if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
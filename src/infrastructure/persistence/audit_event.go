@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type auditEventRepository struct {
+	DB config.PgxIface
+}
+
+func NewAuditEventRepository(db config.PgxIface) repository.AuditEventRepository {
+	return auditEventRepository{db}
+}
+
+func (a auditEventRepository) WithQuerier(querier config.PgxIface) repository.AuditEventRepository {
+	return auditEventRepository{querier}
+}
+
+func (a auditEventRepository) Get(filter repository.AuditEventFilter, page *repository.Page) ([]*domain.AuditEvent, error) {
+	var conds []string
+	var args []interface{}
+	where := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, cond+` $`+strconv.Itoa(len(args)))
+	}
+
+	if filter.Type != "" {
+		where(`type =`, filter.Type)
+	}
+	if filter.SubjectId != nil {
+		where(`subject_id =`, filter.SubjectId)
+	}
+	if filter.Actor != "" {
+		where(`actor =`, filter.Actor)
+	}
+	if filter.Since != nil {
+		where(`created_at >=`, filter.Since)
+	}
+	if filter.Until != nil {
+		where(`created_at <`, filter.Until)
+	}
+
+	from := `audit_event`
+	if len(conds) > 0 {
+		from += ` WHERE ` + strings.Join(conds, ` AND `)
+	}
+
+	events := make([]*domain.AuditEvent, page.Limit)
+	return events, fetchPage(
+		a.DB, page, &events,
+		`*`, from, `created_at DESC`,
+		args...,
+	)
+}
+
+func (a auditEventRepository) Save(event *domain.AuditEvent) error {
+	return a.DB.QueryRow(
+		context.Background(),
+		`INSERT INTO audit_event (type, subject_id, actor, actor_role, request, before, after) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		event.Type, event.SubjectId, event.Actor, event.ActorRole, event.Request, event.Before, event.After,
+	).Scan(&event.ID, &event.CreatedAt)
+}
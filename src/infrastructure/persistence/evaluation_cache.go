@@ -0,0 +1,49 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type evaluationCacheRepository struct {
+	DB config.PgxIface
+}
+
+func NewEvaluationCacheRepository(db config.PgxIface) repository.EvaluationCacheRepository {
+	return evaluationCacheRepository{db}
+}
+
+func (a evaluationCacheRepository) WithQuerier(querier config.PgxIface) repository.EvaluationCacheRepository {
+	return evaluationCacheRepository{querier}
+}
+
+func (a evaluationCacheRepository) Get(key []byte) (output []byte, evaluator string, err error) {
+	err = a.DB.QueryRow(
+		context.Background(),
+		`SELECT output, evaluator FROM evaluation_cache WHERE key = $1`,
+		key,
+	).Scan(&output, &evaluator)
+	return
+}
+
+func (a evaluationCacheRepository) Save(key []byte, source, revision, evaluator string, output []byte) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`INSERT INTO evaluation_cache (key, source, revision, evaluator, output) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key) DO UPDATE SET evaluator = excluded.evaluator, output = excluded.output, created_at = STATEMENT_TIMESTAMP()`,
+		key, source, revision, evaluator, output,
+	)
+	return
+}
+
+func (a evaluationCacheRepository) Prune(before time.Time) (int64, error) {
+	tag, err := a.DB.Exec(
+		context.Background(),
+		`DELETE FROM evaluation_cache WHERE created_at < $1`,
+		before,
+	)
+	return tag.RowsAffected(), err
+}
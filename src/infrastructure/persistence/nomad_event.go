@@ -2,9 +2,9 @@ package persistence
 
 import (
 	"context"
+	"time"
 
 	"github.com/georgysavva/scany/pgxscan"
-	"github.com/google/uuid"
 	nomad "github.com/hashicorp/nomad/api"
 
 	"github.com/input-output-hk/cicero/src/config"
@@ -23,13 +23,15 @@ func (n nomadEventRepository) WithQuerier(querier config.PgxIface) repository.No
 	return nomadEventRepository{querier}
 }
 
-func (n nomadEventRepository) Save(event *nomad.Event) (err error) {
-	_, err = n.DB.Exec(
+// Returns false if the event was already saved.
+func (n nomadEventRepository) Save(event *nomad.Event) (bool, error) {
+	tag, err := n.DB.Exec(
 		context.Background(),
-		`INSERT INTO nomad_event (topic, "type", "key", filter_keys, "index", payload) VALUES ($1, $2, $3, $4, $5, $6)`,
+		`INSERT INTO nomad_event (topic, "type", "key", filter_keys, "index", payload) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT ("index", topic, "type", "key") DO NOTHING`,
 		event.Topic, event.Type, event.Key, event.FilterKeys, event.Index, event.Payload,
 	)
-	return
+	return tag.RowsAffected() > 0, err
 }
 
 func (n nomadEventRepository) GetLastNomadEvent() (index uint64, err error) {
@@ -40,14 +42,13 @@ func (n nomadEventRepository) GetLastNomadEvent() (index uint64, err error) {
 	return
 }
 
-func (n nomadEventRepository) GetEventAllocByNomadJobId(id uuid.UUID) (results []map[string]interface{}, err error) {
-	err = pgxscan.Select(context.Background(), n.DB, &results, `
-		SELECT "index", payload->>'Allocation' AS alloc
-		FROM nomad_event
-		WHERE payload#>>'{Allocation,JobID}' = $1
-			AND topic = 'Allocation'
-			AND type = 'AllocationUpdated'
-		ORDER BY "index" ASC
-	`, id)
-	return
+// Deletes events created before the given time.
+// Always keeps the latest event so we know where to resume the stream.
+func (n nomadEventRepository) Prune(before time.Time) (int64, error) {
+	tag, err := n.DB.Exec(
+		context.Background(),
+		`DELETE FROM nomad_event WHERE created_at < $1 AND "index" < (SELECT MAX("index") FROM nomad_event)`,
+		before,
+	)
+	return tag.RowsAffected(), err
 }
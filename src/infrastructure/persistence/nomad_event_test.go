@@ -0,0 +1,31 @@
+package persistence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldPruneNomadEvents(t *testing.T) {
+	t.Parallel()
+	before := time.Now().UTC()
+
+	// given
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+	mock.ExpectExec("DELETE FROM nomad_event").WithArgs(before).WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	repository := NewNomadEventRepository(mock)
+
+	// when
+	deleted, err := repository.Prune(before)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), deleted)
+}
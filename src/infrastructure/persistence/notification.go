@@ -0,0 +1,110 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type notificationRepository struct {
+	DB config.PgxIface
+}
+
+func NewNotificationRepository(db config.PgxIface) repository.NotificationRepository {
+	return notificationRepository{db}
+}
+
+func (a notificationRepository) WithQuerier(querier config.PgxIface) repository.NotificationRepository {
+	return notificationRepository{querier}
+}
+
+func (a notificationRepository) GetById(id uuid.UUID) (notification domain.Notification, err error) {
+	err = pgxscan.Get(
+		context.Background(), a.DB, &notification,
+		`SELECT * FROM notification WHERE id = $1`,
+		id,
+	)
+	return
+}
+
+func (a notificationRepository) Get(state domain.NotificationState, page *repository.Page) ([]*domain.Notification, error) {
+	notifications := make([]*domain.Notification, page.Limit)
+	if state == "" {
+		return notifications, fetchPage(
+			a.DB, page, &notifications,
+			`*`, `notification`, `created_at DESC`,
+		)
+	}
+	return notifications, fetchPage(
+		a.DB, page, &notifications,
+		`*`, `notification WHERE state = $1`, `created_at DESC`,
+		state,
+	)
+}
+
+func (a notificationRepository) Claim(limit int, lease time.Duration) (notifications []*domain.Notification, err error) {
+	err = pgxscan.Select(
+		context.Background(), a.DB, &notifications,
+		`UPDATE notification SET next_attempt_at = STATEMENT_TIMESTAMP() + $3::interval
+		WHERE id IN (
+			SELECT id FROM notification
+			WHERE state = $1 AND next_attempt_at <= STATEMENT_TIMESTAMP()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		domain.NotificationPending, limit, lease,
+	)
+	return
+}
+
+func (a notificationRepository) GetAttempts(id uuid.UUID) (attempts []*domain.NotificationAttempt, err error) {
+	err = pgxscan.Select(
+		context.Background(), a.DB, &attempts,
+		`SELECT * FROM notification_attempt WHERE notification_id = $1 ORDER BY created_at`,
+		id,
+	)
+	return
+}
+
+func (a notificationRepository) Save(notification *domain.Notification) error {
+	return a.DB.QueryRow(
+		context.Background(),
+		`INSERT INTO notification (run_id, sink, target, payload) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, state, attempts, next_attempt_at`,
+		notification.RunId, notification.Sink, notification.Target, notification.Payload,
+	).Scan(&notification.ID, &notification.CreatedAt, &notification.State, &notification.Attempts, &notification.NextAttemptAt)
+}
+
+func (a notificationRepository) Update(notification *domain.Notification) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`UPDATE notification SET state = $2, attempts = $3, next_attempt_at = $4, delivered_at = $5 WHERE id = $1`,
+		notification.ID, notification.State, notification.Attempts, notification.NextAttemptAt, notification.DeliveredAt,
+	)
+	return
+}
+
+func (a notificationRepository) Supersede(runId uuid.UUID, sink, target string) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`UPDATE notification SET state = $4 WHERE run_id = $1 AND sink = $2 AND target = $3 AND state = $5`,
+		runId, sink, target, domain.NotificationSuperseded, domain.NotificationPending,
+	)
+	return
+}
+
+func (a notificationRepository) SaveAttempt(attempt *domain.NotificationAttempt) error {
+	return a.DB.QueryRow(
+		context.Background(),
+		`INSERT INTO notification_attempt (notification_id, error) VALUES ($1, $2) RETURNING id, created_at`,
+		attempt.NotificationId, attempt.Error,
+	).Scan(&attempt.ID, &attempt.CreatedAt)
+}
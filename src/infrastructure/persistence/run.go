@@ -3,6 +3,7 @@ package persistence
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/google/uuid"
@@ -138,11 +139,16 @@ func (a *runRepository) GetByInputFactIds(factIds []*uuid.UUID, recursive bool,
 func (a *runRepository) Save(run *domain.Run, inputs map[string]interface{}) error {
 	ctx := context.Background()
 
+	transformers := run.Transformers
+	if transformers == nil {
+		transformers = []domain.TransformerConfig{}
+	}
+
 	if err := a.DB.BeginFunc(ctx, func(tx pgx.Tx) error {
 		if err := tx.QueryRow(
 			ctx,
-			`INSERT INTO run (action_id) VALUES ($1) RETURNING nomad_job_id, created_at`,
-			run.ActionId,
+			`INSERT INTO run (action_id, transformers, deadline) VALUES ($1, $2, $3) RETURNING nomad_job_id, created_at`,
+			run.ActionId, transformers, run.Deadline,
 		).Scan(&run.NomadJobID, &run.CreatedAt); err != nil {
 			return err
 		}
@@ -191,8 +197,42 @@ func (a *runRepository) Save(run *domain.Run, inputs map[string]interface{}) err
 func (a *runRepository) Update(run *domain.Run) (err error) {
 	_, err = a.DB.Exec(
 		context.Background(),
-		`UPDATE run SET finished_at = $2 WHERE nomad_job_id = $1`,
-		run.NomadJobID, run.FinishedAt,
+		`UPDATE run SET finished_at = $2, outcome = $3 WHERE nomad_job_id = $1`,
+		run.NomadJobID, run.FinishedAt, run.Outcome,
+	)
+	return
+}
+
+// Sets the finish time and outcome unless the Run has already ended.
+// Returns false if it had.
+func (a *runRepository) End(run *domain.Run) (bool, error) {
+	tag, err := a.DB.Exec(
+		context.Background(),
+		`UPDATE run SET finished_at = $2, outcome = $3 WHERE nomad_job_id = $1 AND finished_at IS NULL`,
+		run.NomadJobID, run.FinishedAt, run.Outcome,
+	)
+	return tag.RowsAffected() > 0, err
+}
+
+// Sets the outcome of a Run that is being stopped
+// unless it has already ended or is already being stopped.
+// Returns false if it had or was.
+func (a *runRepository) Stop(run *domain.Run) (bool, error) {
+	tag, err := a.DB.Exec(
+		context.Background(),
+		`UPDATE run SET outcome = $2 WHERE nomad_job_id = $1 AND finished_at IS NULL AND outcome IS NULL`,
+		run.NomadJobID, run.Outcome,
+	)
+	return tag.RowsAffected() > 0, err
+}
+
+// Returns Runs that are still running after their deadline
+// and are not already being stopped.
+func (a *runRepository) GetPastDeadline(now time.Time) (runs []*domain.Run, err error) {
+	err = pgxscan.Select(
+		context.Background(), a.DB, &runs,
+		`SELECT * FROM run WHERE deadline < $1 AND finished_at IS NULL AND outcome IS NULL`,
+		now,
 	)
 	return
 }
@@ -0,0 +1,61 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	nomad "github.com/hashicorp/nomad/api"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type runAllocationRepository struct {
+	DB config.PgxIface
+}
+
+func NewRunAllocationRepository(db config.PgxIface) repository.RunAllocationRepository {
+	return runAllocationRepository{db}
+}
+
+func (a runAllocationRepository) WithQuerier(querier config.PgxIface) repository.RunAllocationRepository {
+	return runAllocationRepository{querier}
+}
+
+func (a runAllocationRepository) GetByRunId(id uuid.UUID) (allocs []*nomad.Allocation, err error) {
+	rows, err := a.DB.Query(
+		context.Background(),
+		`SELECT payload FROM run_allocation WHERE run_id = $1 ORDER BY modify_index ASC`,
+		id,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		alloc := &nomad.Allocation{}
+		if err = rows.Scan(alloc); err != nil {
+			return
+		}
+		allocs = append(allocs, alloc)
+	}
+	err = rows.Err()
+
+	return
+}
+
+// Inserts or updates the allocation unless a newer version is already stored.
+func (a runAllocationRepository) Save(runId uuid.UUID, alloc *nomad.Allocation) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`INSERT INTO run_allocation (id, run_id, modify_index, client_status, payload) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET
+			modify_index = EXCLUDED.modify_index,
+			client_status = EXCLUDED.client_status,
+			payload = EXCLUDED.payload
+		WHERE run_allocation.modify_index <= EXCLUDED.modify_index`,
+		alloc.ID, runId, alloc.ModifyIndex, alloc.ClientStatus, alloc,
+	)
+	return
+}
@@ -0,0 +1,47 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type runDefinitionRepository struct {
+	DB config.PgxIface
+}
+
+func NewRunDefinitionRepository(db config.PgxIface) repository.RunDefinitionRepository {
+	return runDefinitionRepository{db}
+}
+
+func (a runDefinitionRepository) WithQuerier(querier config.PgxIface) repository.RunDefinitionRepository {
+	return runDefinitionRepository{querier}
+}
+
+func (a runDefinitionRepository) GetByRunId(id uuid.UUID) (def domain.RunDefinition, err error) {
+	err = pgxscan.Get(
+		context.Background(), a.DB, &def,
+		`SELECT evaluator, revision, inputs, output, job FROM run_definition WHERE run_id = $1`,
+		id,
+	)
+	return
+}
+
+func (a runDefinitionRepository) Save(runId uuid.UUID, def *domain.RunDefinition) (err error) {
+	inputs := def.Inputs
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+
+	_, err = a.DB.Exec(
+		context.Background(),
+		`INSERT INTO run_definition (run_id, evaluator, revision, inputs, output, job) VALUES ($1, $2, $3, $4, $5, $6)`,
+		runId, def.Evaluator, def.Revision, inputs, def.Output, def.Job,
+	)
+	return
+}
@@ -0,0 +1,42 @@
+package persistence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldSaveRunDefinition(t *testing.T) {
+	t.Parallel()
+	runId := uuid.New()
+	name := "ci"
+	def := domain.RunDefinition{
+		Job:       &nomad.Job{Name: &name},
+		Evaluator: "nix",
+		Revision:  "git:0123456789abcdef0123456789abcdef01234567",
+	}
+
+	// given
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+	mock.ExpectExec("INSERT INTO run_definition").
+		WithArgs(runId, def.Evaluator, def.Revision, map[string]interface{}{}, def.Output, def.Job).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	repository := NewRunDefinitionRepository(mock)
+
+	// when
+	err = repository.Save(runId, &def)
+
+	// then
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
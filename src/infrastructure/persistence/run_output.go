@@ -26,7 +26,7 @@ func (a runOutputRepository) WithQuerier(querier config.PgxIface) repository.Run
 func (a runOutputRepository) GetByRunId(id uuid.UUID) (output domain.RunOutput, err error) {
 	err = pgxscan.Get(
 		context.Background(), a.DB, &output,
-		`SELECT success, failure FROM run_output WHERE run_id = $1`,
+		`SELECT success, failure, canceled, timed_out, lost, groups, template FROM run_output WHERE run_id = $1`,
 		id,
 	)
 	return
@@ -35,8 +35,8 @@ func (a runOutputRepository) GetByRunId(id uuid.UUID) (output domain.RunOutput,
 func (a runOutputRepository) Save(runId uuid.UUID, output *domain.RunOutput) (err error) {
 	_, err = a.DB.Exec(
 		context.Background(),
-		`INSERT INTO run_output (run_id, success, failure) VALUES ($1, $2, $3)`,
-		runId, output.Success, output.Failure,
+		`INSERT INTO run_output (run_id, success, failure, canceled, timed_out, lost, groups, template) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		runId, output.Success, output.Failure, output.Canceled, output.TimedOut, output.Lost, output.Groups, output.Template,
 	)
 	return
 }
@@ -0,0 +1,42 @@
+package persistence
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/input-output-hk/cicero/src/domain"
+)
+
+func TestShouldSaveRunOutput(t *testing.T) {
+	t.Parallel()
+	runId := uuid.New()
+	var success, lost interface{} = map[string]interface{}{"ok": true}, map[string]interface{}{"lost": "{{.RunId}}"}
+	output := domain.RunOutput{
+		Success:  &success,
+		Lost:     &lost,
+		Groups:   map[string]domain.RunGroupOutput{"deploy": {Success: &success}},
+		Template: true,
+	}
+
+	// given
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+	mock.ExpectExec("INSERT INTO run_output").
+		WithArgs(runId, output.Success, output.Failure, output.Canceled, output.TimedOut, output.Lost, output.Groups, true).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	repository := NewRunOutputRepository(mock)
+
+	// when
+	err = repository.Save(runId, &output)
+
+	// then
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
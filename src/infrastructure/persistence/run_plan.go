@@ -0,0 +1,42 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type runPlanRepository struct {
+	DB config.PgxIface
+}
+
+func NewRunPlanRepository(db config.PgxIface) repository.RunPlanRepository {
+	return runPlanRepository{db}
+}
+
+func (a runPlanRepository) WithQuerier(querier config.PgxIface) repository.RunPlanRepository {
+	return runPlanRepository{querier}
+}
+
+func (a runPlanRepository) GetByRunId(id uuid.UUID) (plan domain.RunPlan, err error) {
+	err = pgxscan.Get(
+		context.Background(), a.DB, &plan,
+		`SELECT diff, failed_tg_allocs, warnings FROM run_plan WHERE run_id = $1`,
+		id,
+	)
+	return
+}
+
+func (a runPlanRepository) Save(runId uuid.UUID, plan *domain.RunPlan) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`INSERT INTO run_plan (run_id, diff, failed_tg_allocs, warnings) VALUES ($1, $2, $3, $4)`,
+		runId, plan.Diff, plan.FailedTGAllocs, plan.Warnings,
+	)
+	return
+}
@@ -66,7 +66,7 @@ func TestShouldUpdateRun(t *testing.T) {
 
 	// given
 	mock, _ := mocks.BuildTransaction(context.Background(), t)
-	mock.ExpectExec("UPDATE run").WithArgs(run.NomadJobID, run.FinishedAt).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE run").WithArgs(run.NomadJobID, run.FinishedAt, run.Outcome).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 	mock.ExpectCommit()
 	repository := NewRunRepository(mock)
 
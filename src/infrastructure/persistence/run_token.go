@@ -0,0 +1,51 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+
+	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain/repository"
+)
+
+type runTokenRepository struct {
+	DB config.PgxIface
+}
+
+func NewRunTokenRepository(db config.PgxIface) repository.RunTokenRepository {
+	return runTokenRepository{db}
+}
+
+func (a runTokenRepository) WithQuerier(querier config.PgxIface) repository.RunTokenRepository {
+	return runTokenRepository{querier}
+}
+
+func (a runTokenRepository) GetRunIdByHash(hash []byte) (id uuid.UUID, err error) {
+	err = pgxscan.Get(
+		context.Background(), a.DB, &id,
+		`SELECT run_id FROM run_token WHERE hash = $1 AND expires_at > STATEMENT_TIMESTAMP()`,
+		hash,
+	)
+	return
+}
+
+func (a runTokenRepository) Save(runId uuid.UUID, hash []byte, ttl time.Duration) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`INSERT INTO run_token (run_id, hash, expires_at) VALUES ($1, $2, STATEMENT_TIMESTAMP() + $3::interval)`,
+		runId, hash, ttl,
+	)
+	return
+}
+
+func (a runTokenRepository) Delete(runId uuid.UUID) (err error) {
+	_, err = a.DB.Exec(
+		context.Background(),
+		`DELETE FROM run_token WHERE run_id = $1`,
+		runId,
+	)
+	return
+}
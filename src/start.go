@@ -2,11 +2,23 @@ package cicero
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
 	"time"
 
 	"cirello.io/oversight"
 	nomad "github.com/hashicorp/nomad/api"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 
 	"github.com/input-output-hk/cicero/src/application"
@@ -14,6 +26,7 @@ import (
 	"github.com/input-output-hk/cicero/src/application/component/web"
 	"github.com/input-output-hk/cicero/src/application/service"
 	"github.com/input-output-hk/cicero/src/config"
+	"github.com/input-output-hk/cicero/src/domain"
 )
 
 //go:generate mockery --all --keeptree
@@ -21,11 +34,59 @@ import (
 type StartCmd struct {
 	Components []string `arg:"positional" help:"any of: nomad, web"`
 
-	PrometheusAddr string   `arg:"--prometheus-addr" default:"http://127.0.0.1:3100"`
-	Evaluators     []string `arg:"--evaluators"`
-	Transformers   []string `arg:"--transform"`
+	PrometheusAddr    string        `arg:"--prometheus-addr" default:"http://127.0.0.1:3100"`
+	Evaluators        []string      `arg:"--evaluators"`
+	Transformers      []string      `arg:"--transform" help:"executables that rewrite the evaluator output of every run"`
+	TransformerConfig string        `arg:"--transformers" help:"JSON file with a list of built-in transformers that actions can select"`
+	SourceMaxAge      time.Duration `arg:"--source-max-age" default:"168h" help:"how long to keep downloaded sources of actions that are not evaluated, 0 to keep them forever"`
+	CacheMaxAge       time.Duration `arg:"--evaluation-cache-max-age" default:"168h" help:"how long to keep cached evaluator outputs, 0 to keep them forever"`
 
-	WebListen string `arg:"--web-listen,env:WEB_LISTEN" default:":8080"`
+	EvaluatorTimeout    time.Duration `arg:"--evaluator-timeout" default:"5m" help:"how long an evaluator or transformer may run, 0 for no limit"`
+	EvaluatorEnv        []string      `arg:"--evaluator-env" help:"names of additional environment variables to pass to evaluators and transformers; a trailing * matches any suffix"`
+	EvaluatorMaxMemory  uint64        `arg:"--evaluator-max-memory" help:"memory limit of evaluators and transformers in bytes, 0 for no limit"`
+	EvaluatorMaxCpuTime time.Duration `arg:"--evaluator-max-cpu-time" help:"CPU time limit of each evaluator and transformer process, 0 for no limit"`
+	EvaluatorCgroup     string        `arg:"--evaluator-cgroup" help:"directory of a cgroup v2 to run evaluators and transformers in child cgroups of"`
+
+	Executor         string `arg:"--executor" default:"nomad" help:"any of: nomad, local"`
+	LocalExecutorDir string `arg:"--local-executor-dir" help:"where the local executor creates task directories"`
+
+	ShutdownGrace time.Duration `arg:"--shutdown-grace" default:"30s" help:"how long to wait for work in flight on SIGTERM or SIGINT before aborting it"`
+
+	RunTokenTtl time.Duration `arg:"--run-token-ttl" default:"24h" help:"how long jobs can publish facts with the token they are given"`
+
+	NotificationRules       string   `arg:"--notification-rules" help:"JSON file with a list of notification rules that apply to all actions"`
+	NotificationMaxAttempts int      `arg:"--notification-max-attempts" default:"5" help:"how often to try delivering a notification before giving up"`
+	CommitStatusTokens      []string `arg:"--commit-status-token,env:COMMIT_STATUS_TOKENS" help:"token to report commit statuses to a GitHub or Gitea API like https://api.github.com=token"`
+	SmtpAddr                string   `arg:"--smtp-addr,env:SMTP_ADDR" help:"host:port of the mail server; enables the email notification sink"`
+	SmtpFrom                string   `arg:"--smtp-from,env:SMTP_FROM" default:"cicero@localhost"`
+	SmtpUsername            string   `arg:"--smtp-username,env:SMTP_USERNAME"`
+	SmtpPassword            string   `arg:"--smtp-password,env:SMTP_PASSWORD"`
+
+	OtlpEndpoint    string `arg:"--otlp-endpoint,env:OTEL_EXPORTER_OTLP_ENDPOINT" help:"base URL of an OpenTelemetry collector to send traces to over OTLP/HTTP like http://127.0.0.1:4318"`
+	OtlpServiceName string `arg:"--otlp-service-name,env:OTEL_SERVICE_NAME" default:"cicero"`
+
+	NomadEventTopics    []string      `arg:"--nomad-event-topic" help:"Nomad event topic to listen to, optionally with a filter key like Allocation:key; defaults to Allocation"`
+	NomadEventRetention time.Duration `arg:"--nomad-event-retention" default:"168h" help:"how long to keep raw Nomad events, 0 to keep them forever"`
+
+	WebListen     string `arg:"--web-listen,env:WEB_LISTEN" default:":8080"`
+	WebUrl        string `arg:"--web-url,env:WEB_URL" help:"public URL of the web UI to link to in notifications"`
+	WebAuth       string `arg:"--web-auth,env:WEB_AUTH" help:"how users of the web UI log in, any of: oidc, local; required"`
+	WebLocalRole  string `arg:"--web-local-role" default:"viewer" help:"role everyone gets with the local login"`
+	WebSessionKey string `arg:"--web-session-key,env:WEB_SESSION_KEY" help:"secret to sign sessions with; random if not given, which logs everyone out on restart"`
+
+	WebhookSecrets  []string `arg:"--webhook-secret,env:WEBHOOK_SECRETS" help:"enables POST /api/webhook/{provider} with the given secret like github=secret"`
+	WebhookMappings []string `arg:"--webhook-mapping" help:"CUE file that maps deliveries to facts like github=mapping.cue"`
+
+	FactSchemas string `arg:"--fact-schemas" help:"JSON file with a list of schemas that facts published through the API must conform to"`
+
+	OidcIssuer       string   `arg:"--oidc-issuer,env:OIDC_ISSUER"`
+	OidcClientId     string   `arg:"--oidc-client-id,env:OIDC_CLIENT_ID"`
+	OidcClientSecret string   `arg:"--oidc-client-secret,env:OIDC_CLIENT_SECRET"`
+	OidcCallbackUrl  string   `arg:"--oidc-callback-url,env:OIDC_CALLBACK_URL" help:"public URL of /login/callback"`
+	OidcScopes       []string `arg:"--oidc-scope" help:"defaults to openid, profile and email"`
+	OidcGroupsClaim  string   `arg:"--oidc-groups-claim" default:"groups"`
+	OidcGroupRoles   []string `arg:"--oidc-group-role" help:"role for members of a group like group=role"`
+	OidcDefaultRole  string   `arg:"--oidc-default-role" help:"role for users in none of the groups; if empty they cannot log in"`
 }
 
 func (cmd *StartCmd) Run(logger *zerolog.Logger) error {
@@ -79,17 +140,52 @@ func (cmd *StartCmd) Run(logger *zerolog.Logger) error {
 		}
 	})
 	nomadClientWrapper := once(func() interface{} {
-		return application.NewNomadClient(nomadClient().(*nomad.Client))
+		return application.NewNomadClient(nomadClient().(*nomad.Client), config.ParseNomadEventTopics(cmd.NomadEventTopics))
+	})
+
+	executor := once(func() interface{} {
+		switch cmd.Executor {
+		case "nomad":
+			return application.NewNomadExecutor(nomadClientWrapper().(application.NomadClient), logger)
+		case "local":
+			dir := cmd.LocalExecutorDir
+			if dir == "" {
+				dir = filepath.Join(os.TempDir(), "cicero", "jobs")
+			}
+			return application.NewLocalExecutor(dir, append(service.DefaultSandboxEnv, cmd.EvaluatorEnv...), logger)
+		default:
+			logger.Fatal().Msgf("Unknown executor: %s", cmd.Executor)
+			return nil
+		}
 	})
 
 	runService := once(func() interface{} {
-		return service.NewRunService(db().(config.PgxIface), cmd.PrometheusAddr, nomadClientWrapper().(application.NomadClient), logger)
+		return service.NewRunService(db().(config.PgxIface), cmd.PrometheusAddr, executor().(application.Executor), cmd.RunTokenTtl, logger)
 	})
 	evaluationService := once(func() interface{} {
-		return service.NewEvaluationService(cmd.Evaluators, cmd.Transformers, logger)
+		return service.NewEvaluationService(db().(config.PgxIface), cmd.Evaluators, cmd.Transformers, service.Sandbox{
+			Timeout:    cmd.EvaluatorTimeout,
+			Env:        append(service.DefaultSandboxEnv, cmd.EvaluatorEnv...),
+			MaxMemory:  cmd.EvaluatorMaxMemory,
+			MaxCpuTime: cmd.EvaluatorMaxCpuTime,
+			Cgroup:     cmd.EvaluatorCgroup,
+		}, logger)
+	})
+	notificationService := once(func() interface{} {
+		sinks, rules, err := cmd.newNotificationConfig()
+		if err != nil {
+			logger.Fatal().Err(err).Send()
+			return nil
+		}
+		return service.NewNotificationService(db().(config.PgxIface), sinks, rules, strings.TrimSuffix(cmd.WebUrl, "/"), cmd.NotificationMaxAttempts, logger)
 	})
 	actionService := once(func() interface{} {
-		return service.NewActionService(db().(config.PgxIface), nomadClientWrapper().(application.NomadClient), runService().(service.RunService), evaluationService().(service.EvaluationService), logger)
+		transformers, err := cmd.newTransformerConfig()
+		if err != nil {
+			logger.Fatal().Err(err).Send()
+			return nil
+		}
+		return service.NewActionService(db().(config.PgxIface), executor().(application.Executor), runService().(service.RunService), evaluationService().(service.EvaluationService), notificationService().(service.NotificationService), transformers, logger)
 	})
 	factService := once(func() interface{} {
 		return service.NewFactService(db().(config.PgxIface), actionService().(service.ActionService), logger)
@@ -97,50 +193,375 @@ func (cmd *StartCmd) Run(logger *zerolog.Logger) error {
 	nomadEventService := once(func() interface{} {
 		return service.NewNomadEventService(db().(config.PgxIface), runService().(service.RunService), logger)
 	})
+	auditService := once(func() interface{} {
+		return service.NewAuditService(db().(config.PgxIface), logger)
+	})
+	apiTokenService := once(func() interface{} {
+		return service.NewApiTokenService(db().(config.PgxIface), logger)
+	})
+
+	// Cancelled on SIGTERM or SIGINT to stop taking on new work.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Cancelled once the grace period is over to abort work in flight.
+	workCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+	go func() {
+		select {
+		case <-workCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+
+		logger.Info().Dur("grace", cmd.ShutdownGrace).Msg("Shutting down")
+
+		select {
+		case <-workCtx.Done():
+		case <-time.After(cmd.ShutdownGrace):
+			logger.Warn().Msg("Aborting work in flight")
+			abort()
+		}
+	}()
 
 	supervisor := cmd.newSupervisor(logger)
 
+	// Only served by the web component.
+	healthChecks := []application.HealthCheck{
+		application.NewDbHealthCheck(db().(*pgxpool.Pool)),
+		application.NewEvaluatorsHealthCheck(cmd.Evaluators),
+	}
+
+	if cmd.OtlpEndpoint != "" {
+		tracer := application.NewTracer(cmd.OtlpEndpoint, cmd.OtlpServiceName, logger)
+		application.SetTracer(tracer)
+		if err := supervisor.Add(cmd.child(tracer.Start)); err != nil {
+			return err
+		}
+	}
+
 	if start.nomadEvent {
 		child := component.NomadEventConsumer{
 			Logger:            logger.With().Str("component", "NomadEventConsumer").Logger(),
 			RunService:        runService().(service.RunService),
 			NomadEventService: nomadEventService().(service.NomadEventService),
-			FactService:       factService().(service.FactService),
-			NomadClient:       nomadClientWrapper().(application.NomadClient),
+			ActionService:     actionService().(service.ActionService),
+			Executor:          executor().(application.Executor),
 			Db:                db().(config.PgxIface),
+			StreamHealth:      &component.NomadEventStreamHealth{Grace: time.Minute},
+			WorkContext:       workCtx,
+		}
+		healthChecks = append(healthChecks, child.StreamHealth.HealthCheck())
+
+		election := component.LeaderElection{
+			Logger:   logger.With().Str("component", "LeaderElection").Logger(),
+			Db:       db().(*pgxpool.Pool),
+			Name:     "cicero-nomad-event-consumer",
+			Interval: 5 * time.Second,
+		}
+		if err := supervisor.Add(cmd.child(func(ctx context.Context) error {
+			return election.Run(ctx, child.Start)
+		})); err != nil {
+			return err
+		}
+
+		dispatcher := component.NotificationDispatcher{
+			Logger:              logger.With().Str("component", "NotificationDispatcher").Logger(),
+			NotificationService: notificationService().(service.NotificationService),
+			Interval:            5 * time.Second,
 		}
-		if err := supervisor.Add(child.Start); err != nil {
+		if err := supervisor.Add(cmd.child(dispatcher.Start)); err != nil {
 			return err
 		}
+
+		watcher := component.RunDeadlineWatcher{
+			Logger:        logger.With().Str("component", "RunDeadlineWatcher").Logger(),
+			RunService:    runService().(service.RunService),
+			ActionService: actionService().(service.ActionService),
+			Interval:      10 * time.Second,
+		}
+		if err := supervisor.Add(cmd.child(watcher.Start)); err != nil {
+			return err
+		}
+
+		if cmd.NomadEventRetention > 0 {
+			child := component.NomadEventPruner{
+				Logger:            logger.With().Str("component", "NomadEventPruner").Logger(),
+				NomadEventService: nomadEventService().(service.NomadEventService),
+				Retention:         cmd.NomadEventRetention,
+				Interval:          1 * time.Hour,
+			}
+			if err := supervisor.Add(cmd.child(child.Start)); err != nil {
+				return err
+			}
+		}
 	}
 
 	if start.web {
+		authenticator, err := cmd.newAuthenticator(logger)
+		if err != nil {
+			return err
+		}
+
+		sessionKey := []byte(cmd.WebSessionKey)
+		if len(sessionKey) == 0 {
+			logger.Warn().Msg("No session key given, generating one")
+			sessionKey = make([]byte, 32)
+			if _, err := rand.Read(sessionKey); err != nil {
+				return err
+			}
+		}
+
+		webhooks, err := cmd.newWebhooks()
+		if err != nil {
+			return err
+		}
+
+		factSchemas, err := cmd.newFactSchemas()
+		if err != nil {
+			return err
+		}
+
+		// Only served by the web component.
+		if err := prometheus.Register(application.NewPgxPoolCollector(db().(*pgxpool.Pool))); err != nil {
+			return err
+		}
+
+		healthChecks = append(healthChecks, application.NewLokiHealthCheck(&http.Client{}, cmd.PrometheusAddr))
+
 		child := web.Web{
-			Logger:            logger.With().Str("component", "Web").Logger(),
-			Listen:            cmd.WebListen,
-			RunService:        runService().(service.RunService),
-			ActionService:     actionService().(service.ActionService),
-			FactService:       factService().(service.FactService),
-			NomadEventService: nomadEventService().(service.NomadEventService),
-			EvaluationService: evaluationService().(service.EvaluationService),
-			Db:                db().(config.PgxIface),
+			Logger:              logger.With().Str("component", "Web").Logger(),
+			Listen:              cmd.WebListen,
+			RunService:          runService().(service.RunService),
+			ActionService:       actionService().(service.ActionService),
+			FactService:         factService().(service.FactService),
+			NomadEventService:   nomadEventService().(service.NomadEventService),
+			EvaluationService:   evaluationService().(service.EvaluationService),
+			ApiTokenService:     apiTokenService().(service.ApiTokenService),
+			AuditService:        auditService().(service.AuditService),
+			NotificationService: notificationService().(service.NotificationService),
+			Authenticator:       authenticator,
+			Webhooks:            webhooks,
+			FactSchemas:         factSchemas,
+			SessionKey:          sessionKey,
+			HealthChecks:        healthChecks,
+			Db:                  db().(config.PgxIface),
+			WorkContext:         workCtx,
+			ShutdownTimeout:     cmd.ShutdownGrace + shutdownMargin,
 		}
-		if err := supervisor.Add(child.Start); err != nil {
+		if err := supervisor.Add(cmd.child(child.Start)); err != nil {
 			return err
 		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Both components evaluate actions.
+	if cmd.SourceMaxAge > 0 {
+		child := component.SourcePruner{
+			Logger:            logger.With().Str("component", "SourcePruner").Logger(),
+			EvaluationService: evaluationService().(service.EvaluationService),
+			MaxAge:            cmd.SourceMaxAge,
+			Interval:          1 * time.Hour,
+		}
+		if err := supervisor.Add(cmd.child(child.Start)); err != nil {
+			return err
+		}
+	}
+	if cmd.CacheMaxAge > 0 {
+		child := component.EvaluationCachePruner{
+			Logger:            logger.With().Str("component", "EvaluationCachePruner").Logger(),
+			EvaluationService: evaluationService().(service.EvaluationService),
+			MaxAge:            cmd.CacheMaxAge,
+			Interval:          1 * time.Hour,
+		}
+		if err := supervisor.Add(cmd.child(child.Start)); err != nil {
+			return err
+		}
+	}
 
+	// Returns once all children stopped after a signal.
 	if err := supervisor.Start(ctx); err != nil {
 		return errors.WithMessage(err, "While starting supervisor")
 	}
 
-	<-ctx.Done()
+	logger.Info().Msg("Stopped")
 	return nil
 }
 
+func (cmd *StartCmd) newNotificationConfig() (map[string]application.NotificationSink, []domain.NotificationRule, error) {
+	client := &http.Client{}
+	sinks := map[string]application.NotificationSink{
+		"webhook": application.NewWebhookNotificationSink(client),
+		"chat":    application.NewChatNotificationSink(client),
+	}
+	if len(cmd.CommitStatusTokens) > 0 {
+		if tokens, err := config.ParseKeyValues(cmd.CommitStatusTokens); err != nil {
+			return nil, nil, errors.WithMessage(err, "Invalid --commit-status-token")
+		} else {
+			sinks[domain.CommitStatusSink] = application.NewCommitStatusSink(client, tokens)
+		}
+	}
+	if cmd.SmtpAddr != "" {
+		sinks["email"] = application.NewEmailNotificationSink(application.SmtpConfig{
+			Addr:     cmd.SmtpAddr,
+			From:     cmd.SmtpFrom,
+			Username: cmd.SmtpUsername,
+			Password: cmd.SmtpPassword,
+		})
+	}
+
+	var rules []domain.NotificationRule
+	if cmd.NotificationRules != "" {
+		if rulesJson, err := os.ReadFile(cmd.NotificationRules); err != nil {
+			return nil, nil, errors.WithMessage(err, "Could not read --notification-rules")
+		} else if err := json.Unmarshal(rulesJson, &rules); err != nil {
+			return nil, nil, errors.WithMessage(err, "Invalid --notification-rules")
+		}
+	}
+
+	for _, rule := range rules {
+		if _, ok := sinks[rule.Sink]; !ok {
+			return nil, nil, fmt.Errorf("Notification rule for unknown or unconfigured sink %q", rule.Sink)
+		} else if _, err := regexp.Compile(rule.Action); err != nil {
+			return nil, nil, errors.WithMessage(err, "Invalid action in notification rule")
+		}
+	}
+
+	return sinks, rules, nil
+}
+
+func (cmd *StartCmd) newTransformerConfig() ([]domain.TransformerConfig, error) {
+	var configs []domain.TransformerConfig
+	if cmd.TransformerConfig != "" {
+		if configJson, err := os.ReadFile(cmd.TransformerConfig); err != nil {
+			return nil, errors.WithMessage(err, "Could not read --transformers")
+		} else if err := json.Unmarshal(configJson, &configs); err != nil {
+			return nil, errors.WithMessage(err, "Invalid --transformers")
+		}
+	}
+
+	names := map[string]bool{}
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("Transformer of type %q has no name", config.Type)
+		} else if names[config.Name] {
+			return nil, fmt.Errorf("Transformer %q is declared more than once", config.Name)
+		} else if _, err := application.NewTransformer(config); err != nil {
+			return nil, errors.WithMessage(err, "Invalid --transformers")
+		}
+		names[config.Name] = true
+	}
+
+	external := make([]domain.TransformerConfig, len(cmd.Transformers))
+	for i, executable := range cmd.Transformers {
+		external[i] = domain.TransformerConfig{Name: executable, Type: domain.ExternalTransformer, Enforce: true}
+	}
+
+	return append(external, configs...), nil
+}
+
+func (cmd *StartCmd) newFactSchemas() ([]domain.FactSchema, error) {
+	var schemas []domain.FactSchema
+	if cmd.FactSchemas == "" {
+		return schemas, nil
+	}
+
+	if schemasJson, err := os.ReadFile(cmd.FactSchemas); err != nil {
+		return nil, errors.WithMessage(err, "Could not read --fact-schemas")
+	} else if err := json.Unmarshal(schemasJson, &schemas); err != nil {
+		return nil, errors.WithMessage(err, "Invalid --fact-schemas")
+	}
+
+	names := map[string]bool{}
+	for _, schema := range schemas {
+		if schema.Name == "" {
+			return nil, fmt.Errorf("Fact schema has no name")
+		} else if names[schema.Name] {
+			return nil, fmt.Errorf("Fact schema %q is declared more than once", schema.Name)
+		}
+		names[schema.Name] = true
+	}
+
+	return schemas, nil
+}
+
+func (cmd *StartCmd) newWebhooks() (map[string]application.Webhook, error) {
+	secrets, err := config.ParseKeyValues(cmd.WebhookSecrets)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Invalid --webhook-secret")
+	}
+
+	mappings, err := config.ParseKeyValues(cmd.WebhookMappings)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Invalid --webhook-mapping")
+	}
+
+	webhooks := map[string]application.Webhook{}
+	for provider, secret := range secrets {
+		webhookConfig := application.WebhookConfig{Provider: provider, Secret: secret}
+		if path, ok := mappings[provider]; ok {
+			if mapping, err := os.ReadFile(path); err != nil {
+				return nil, errors.WithMessagef(err, "Could not read mapping for %s webhook", provider)
+			} else {
+				webhookConfig.Mapping = string(mapping)
+			}
+			delete(mappings, provider)
+		}
+
+		if webhook, err := application.NewWebhook(webhookConfig); err != nil {
+			return nil, err
+		} else {
+			webhooks[provider] = webhook
+		}
+	}
+
+	for provider := range mappings {
+		return nil, fmt.Errorf("Mapping given for %s webhook but no secret", provider)
+	}
+
+	return webhooks, nil
+}
+
+func (cmd *StartCmd) newAuthenticator(logger *zerolog.Logger) (application.Authenticator, error) {
+	switch cmd.WebAuth {
+	case "oidc":
+		groupRoles, err := config.ParseGroupRoles(cmd.OidcGroupRoles)
+		if err != nil {
+			return nil, errors.WithMessage(err, "Invalid --oidc-group-role")
+		}
+
+		var defaultRole *domain.Role
+		if cmd.OidcDefaultRole != "" {
+			defaultRole = new(domain.Role)
+			if err := defaultRole.FromString(cmd.OidcDefaultRole); err != nil {
+				return nil, errors.WithMessage(err, "Invalid --oidc-default-role")
+			}
+		}
+
+		return application.NewOidcAuthenticator(application.OidcConfig{
+			Issuer:       cmd.OidcIssuer,
+			ClientId:     cmd.OidcClientId,
+			ClientSecret: cmd.OidcClientSecret,
+			CallbackUrl:  cmd.OidcCallbackUrl,
+			Scopes:       cmd.OidcScopes,
+			GroupsClaim:  cmd.OidcGroupsClaim,
+			GroupRoles:   groupRoles,
+			DefaultRole:  defaultRole,
+		}, logger), nil
+	case "local":
+		var role domain.Role
+		if err := role.FromString(cmd.WebLocalRole); err != nil {
+			return nil, errors.WithMessage(err, "Invalid --web-local-role")
+		}
+		logger.Warn().Str("role", role.String()).Msg("Everyone using the web UI is logged in without asking")
+		return application.NewLocalAuthenticator("/login/callback", domain.Identity{Name: "local", Role: role}), nil
+	case "":
+		// Logging in everyone without asking must be a conscious choice.
+		return nil, errors.New("Choose how users of the web UI log in with --web-auth oidc or --web-auth local")
+	default:
+		return nil, errors.Errorf("Unknown web authentication: %s", cmd.WebAuth)
+	}
+}
+
 func (cmd *StartCmd) newSupervisor(logger *zerolog.Logger) *oversight.Tree {
 	return oversight.New(
 		oversight.WithLogger(&config.SupervisorLogger{Logger: logger}),
@@ -152,6 +573,17 @@ func (cmd *StartCmd) newSupervisor(logger *zerolog.Logger) *oversight.Tree {
 	)
 }
 
+// How long to wait for work that was aborted after the grace period to return.
+const shutdownMargin = 5 * time.Second
+
+// Gives the child time to finish its work when the supervisor stops.
+func (cmd *StartCmd) child(start oversight.ChildProcess) oversight.ChildProcessSpecification {
+	return oversight.ChildProcessSpecification{
+		Start:    start,
+		Shutdown: oversight.Timeout(cmd.ShutdownGrace + 2*shutdownMargin),
+	}
+}
+
 func once(init func() interface{}) func() interface{} {
 	var inst *interface{}
 	return func() interface{} {